@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ブランチの一覧に表示する情報
+type BranchInfo struct {
+	Ref                    // ブランチ（ローカルまたはリモート）
+	Ahead        int       // 上流にないコミットの数
+	Behind       int       // 上流にあってこのブランチにないコミットの数
+	UpstreamGone bool      // 上流が設定されているが存在しない
+	Subject      string    // 先頭のコミットの件名
+	Date         time.Time // 先頭のコミットの日時
+	Merged       bool      // 現在のHEADにマージされているかどうか
+}
+
+// 上流との差を表示用に整形する（上流がなければ空）
+func (b BranchInfo) Tracking() string {
+	switch {
+	case b.Upstream == "":
+		return ""
+	case b.UpstreamGone:
+		return b.Upstream + " gone"
+	case b.Ahead == 0 && b.Behind == 0:
+		return b.Upstream
+	}
+	var counts []string
+	if b.Ahead > 0 {
+		counts = append(counts, fmt.Sprintf("↑%d", b.Ahead))
+	}
+	if b.Behind > 0 {
+		counts = append(counts, fmt.Sprintf("↓%d", b.Behind))
+	}
+	return b.Upstream + " " + strings.Join(counts, " ")
+}
+
+// git for-each-refの%(upstream:track,nobracket)を解析する
+// "ahead 1, behind 2"、"gone"、または空
+func parseUpstreamTrack(track string) (ahead, behind int, gone bool) {
+	if track == "gone" {
+		return 0, 0, true
+	}
+	for _, part := range strings.Split(track, ", ") {
+		if count, ok := strings.CutPrefix(part, "ahead "); ok {
+			fmt.Sscan(count, &ahead)
+		} else if count, ok := strings.CutPrefix(part, "behind "); ok {
+			fmt.Sscan(count, &behind)
+		}
+	}
+	return ahead, behind, false
+}
+
+// ブランチの並べ方
+type branchOrder int
+
+const (
+	branchOrderName branchOrder = iota // 名前順
+	branchOrderDate                    // 新しいコミットの順
+)
+
+func (o branchOrder) String() string {
+	if o == branchOrderDate {
+		return "date"
+	}
+	return "name"
+}
+
+// ローカルブランチ、リモートブランチの順に分け、それぞれを指定した順に並べる
+func sortBranches(branches []BranchInfo, order branchOrder) []BranchInfo {
+	sorted := append([]BranchInfo(nil), branches...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if order == branchOrderDate && !a.Date.Equal(b.Date) {
+			return a.Date.After(b.Date)
+		}
+		return a.Name < b.Name
+	})
+	return sorted
+}
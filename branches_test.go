@@ -0,0 +1,71 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseUpstreamTrack(t *testing.T) {
+	tests := []struct {
+		track         string
+		ahead, behind int
+		gone          bool
+	}{
+		{"", 0, 0, false},
+		{"gone", 0, 0, true},
+		{"ahead 3", 3, 0, false},
+		{"behind 2", 0, 2, false},
+		{"ahead 1, behind 12", 1, 12, false},
+	}
+	for _, tt := range tests {
+		ahead, behind, gone := parseUpstreamTrack(tt.track)
+		if ahead != tt.ahead || behind != tt.behind || gone != tt.gone {
+			t.Errorf("parseUpstreamTrack(%q) = %d, %d, %v; want %d, %d, %v", tt.track, ahead, behind, gone, tt.ahead, tt.behind, tt.gone)
+		}
+	}
+}
+
+func TestBranchTracking(t *testing.T) {
+	tests := []struct {
+		branch BranchInfo
+		want   string
+	}{
+		{BranchInfo{}, ""},
+		{BranchInfo{Ref: Ref{Upstream: "origin/main"}}, "origin/main"},
+		{BranchInfo{Ref: Ref{Upstream: "origin/main"}, Ahead: 2}, "origin/main ↑2"},
+		{BranchInfo{Ref: Ref{Upstream: "origin/main"}, Ahead: 1, Behind: 3}, "origin/main ↑1 ↓3"},
+		{BranchInfo{Ref: Ref{Upstream: "origin/old"}, UpstreamGone: true}, "origin/old gone"},
+	}
+	for _, tt := range tests {
+		if got := tt.branch.Tracking(); got != tt.want {
+			t.Errorf("Tracking(%+v) = %q; want %q", tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestSortBranches(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2024, 1, d, 0, 0, 0, 0, time.UTC) }
+	branches := []BranchInfo{
+		{Ref: Ref{Name: "origin/main", Kind: RefRemoteBranch}, Date: day(3)},
+		{Ref: Ref{Name: "main", Kind: RefLocalBranch}, Date: day(1)},
+		{Ref: Ref{Name: "feature", Kind: RefLocalBranch}, Date: day(2)},
+		{Ref: Ref{Name: "origin/feature", Kind: RefRemoteBranch}, Date: day(1)},
+	}
+	names := func(branches []BranchInfo) []string {
+		var names []string
+		for _, branch := range branches {
+			names = append(names, branch.Name)
+		}
+		return names
+	}
+
+	want := []string{"feature", "main", "origin/feature", "origin/main"}
+	if got := names(sortBranches(branches, branchOrderName)); !reflect.DeepEqual(got, want) {
+		t.Errorf("by name = %v; want %v", got, want)
+	}
+	want = []string{"feature", "main", "origin/main", "origin/feature"}
+	if got := names(sortBranches(branches, branchOrderDate)); !reflect.DeepEqual(got, want) {
+		t.Errorf("by date = %v; want %v", got, want)
+	}
+}
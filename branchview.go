@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// ローカルブランチとリモートブランチを一覧表示するビュー
+type branchView struct {
+	*tview.Flex
+	list   *tview.TextView
+	footer *tview.TextView // 並べ方や操作の結果を表示する2行の領域
+	repo   Repository
+	queue  func(func()) // 別のゴルーチンの結果をUIのゴルーチンで処理する（app.QueueUpdateDraw）
+
+	branches     []BranchInfo // 並べ替えた一覧
+	headBranch   string       // 現在のブランチ（detached HEADなら空）
+	order        branchOrder  // 並べ方
+	current      int          // 選択中の行
+	scrollOffset int          // 先頭に表示している行
+	loaded       bool         // 一度でも読み込んだかどうか
+	generation   int          // 読み込み直すたびに増やし、古い読み込み結果を捨てる
+	message      string       // 操作の結果
+
+	closeFunc  func()                  // ビューを閉じるときに呼ぶ関数
+	selectFunc func(branch BranchInfo) // ブランチの先頭のコミットに移動する関数
+}
+
+// ブランチの一覧を作成
+func newBranchView(repo Repository, queue func(func())) *branchView {
+	v := &branchView{
+		list: tview.NewTextView().
+			SetDynamicColors(true).
+			SetScrollable(true).
+			SetWrap(false),
+		footer: tview.NewTextView().
+			SetDynamicColors(true),
+		repo:  repo,
+		queue: queue,
+	}
+	v.list.SetBorder(true).SetTitle(" Branches ")
+	v.Flex = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(v.list, 0, 1, true).
+		AddItem(v.footer, 2, 0, false)
+	v.list.SetInputCapture(v.handleKey)
+	return v
+}
+
+// ビューを閉じるときに呼ぶ関数を設定
+func (v *branchView) SetCloseFunc(handler func()) *branchView {
+	v.closeFunc = handler
+	return v
+}
+
+// ブランチを選んだときに呼ぶ関数を設定
+func (v *branchView) SetSelectFunc(handler func(branch BranchInfo)) *branchView {
+	v.selectFunc = handler
+	return v
+}
+
+// 選択中のブランチを取得（一覧が空ならfalse）
+func (v *branchView) selected() (BranchInfo, bool) {
+	if v.current < 0 || v.current >= len(v.branches) {
+		return BranchInfo{}, false
+	}
+	return v.branches[v.current], true
+}
+
+// ブランチの一覧を別のゴルーチンで読み込み直す
+// 選択していたブランチが残っていれば選択したままにする
+func (v *branchView) Refresh() {
+	v.generation++
+	generation := v.generation
+	go func() {
+		branches, err := v.repo.Branches()
+		headBranch, _ := v.repo.CurrentBranchName()
+		v.queue(func() {
+			if generation != v.generation {
+				return
+			}
+			if err != nil {
+				v.message = fmt.Sprintf("[red]Failed to read branches: %s[-]", tview.Escape(formatMessage(err.Error())))
+				v.render()
+				return
+			}
+			old, ok := v.selected()
+			v.branches = sortBranches(branches, v.order)
+			v.headBranch = headBranch
+			v.loaded = true
+			if ok {
+				v.current = v.find(old.FullName, v.current)
+			}
+			v.current = max(min(v.current, len(v.branches)-1), 0)
+			v.render()
+		})
+	}()
+}
+
+// ブランチの行を探す（なければdefaultIndex）
+func (v *branchView) find(fullName string, defaultIndex int) int {
+	for i, branch := range v.branches {
+		if branch.FullName == fullName {
+			return i
+		}
+	}
+	return defaultIndex
+}
+
+// 一覧を描画する
+func (v *branchView) render() {
+	_, _, _, height := v.list.GetInnerRect()
+
+	// 名前と上流の列の幅をそろえる
+	nameWidth, trackingWidth := 0, 0
+	for _, branch := range v.branches {
+		nameWidth = max(nameWidth, runewidth.StringWidth(branch.Name))
+		trackingWidth = max(trackingWidth, runewidth.StringWidth(branch.Tracking()))
+	}
+
+	var b strings.Builder
+	row, selectedRow := 0, 0
+	writeLine := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString("\n")
+		row++
+	}
+
+	if len(v.branches) == 0 {
+		if v.loaded {
+			writeLine("No branches")
+		} else {
+			writeLine("Loading...")
+		}
+	}
+	for i, branch := range v.branches {
+		if i == 0 || branch.Kind != v.branches[i-1].Kind {
+			// 区分の見出し
+			if i > 0 {
+				writeLine("")
+			}
+			count := 0
+			for _, other := range v.branches {
+				if other.Kind == branch.Kind {
+					count++
+				}
+			}
+			title := "Local"
+			if branch.Kind == RefRemoteBranch {
+				title = "Remote"
+			}
+			writeLine("[yellow::b]%s (%d)[-::-]", title, count)
+		}
+
+		marker := " "
+		if branch.Kind == RefLocalBranch && branch.Name == v.headBranch {
+			marker = "*"
+		}
+		merged := ""
+		if branch.Merged && marker != "*" {
+			merged = "  (merged)"
+		}
+		line := fmt.Sprintf("%s %s  ", marker, runewidth.FillRight(branch.Name, nameWidth))
+		if trackingWidth > 0 {
+			line += runewidth.FillRight(branch.Tracking(), trackingWidth) + "  "
+		}
+		line += fmt.Sprintf("%s  %s%s", branch.Date.Format("2006-01-02"), branch.Subject, merged)
+		switch {
+		case i == v.current:
+			selectedRow = row
+			writeLine("[black:white]%s[-:-]", tview.Escape(line))
+		case marker == "*":
+			writeLine("[green]%s[-]", tview.Escape(line))
+		case branch.Kind == RefRemoteBranch:
+			writeLine("[red]%s[-]", tview.Escape(line))
+		default:
+			writeLine("%s", tview.Escape(line))
+		}
+	}
+	v.list.SetText(b.String())
+
+	// 選択中の行が画面に表示されるようにスクロールする（区分の先頭では見出しも表示する）
+	top := selectedRow
+	if v.current >= 0 && v.current < len(v.branches) && (v.current == 0 || v.branches[v.current-1].Kind != v.branches[v.current].Kind) {
+		top = max(selectedRow-1, 0)
+	}
+	if top < v.scrollOffset {
+		v.scrollOffset = top
+	} else if height > 0 && selectedRow >= v.scrollOffset+height {
+		v.scrollOffset = selectedRow - height + 1
+	}
+	v.list.ScrollTo(v.scrollOffset, 0)
+
+	v.footer.Clear()
+	v.footer.Write([]byte(fmt.Sprintf("Sorted by %s", v.order)))
+	if v.message != "" {
+		v.footer.Write([]byte("  " + v.message))
+	}
+	v.footer.Write([]byte("\n[gray]Enter: jump to tip  s: sort by name/date  R: reload  q: close[-]"))
+}
+
+// キー入力のハンドリング
+func (v *branchView) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	v.message = ""
+	_, _, _, height := v.list.GetInnerRect()
+
+	switch event.Key() {
+	case tcell.KeyUp:
+		if v.current > 0 {
+			v.current--
+		}
+	case tcell.KeyDown:
+		if v.current < len(v.branches)-1 {
+			v.current++
+		}
+	case tcell.KeyPgUp:
+		v.current = max(v.current-max(height-1, 1), 0)
+	case tcell.KeyPgDn:
+		v.current = max(min(v.current+max(height-1, 1), len(v.branches)-1), 0)
+	case tcell.KeyEnter:
+		if branch, ok := v.selected(); ok && v.selectFunc != nil {
+			v.selectFunc(branch)
+		}
+		return nil
+	case tcell.KeyEscape:
+		if v.closeFunc != nil {
+			v.closeFunc()
+		}
+		return nil
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case 's':
+			// 選択中のブランチを選択したまま並べ替える
+			old, ok := v.selected()
+			v.order = (v.order + 1) % 2
+			v.branches = sortBranches(v.branches, v.order)
+			if ok {
+				v.current = v.find(old.FullName, v.current)
+			}
+		case 'R':
+			v.Refresh()
+		case 'q':
+			if v.closeFunc != nil {
+				v.closeFunc()
+			}
+			return nil
+		default:
+			return event
+		}
+	default:
+		return event
+	}
+	v.render()
+	return nil
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// 件名の長さの目安（これを超えると警告の色にする）
+const (
+	subjectSoftLimit = 50
+	subjectHardLimit = 72
+)
+
+// コミットメッセージを書いてコミットするビュー
+// 閉じても書きかけのメッセージは残り、次に開いたときに続きから書ける
+type commitComposer struct {
+	*tview.Flex
+	box    *tview.Flex     // 目盛りと入力欄を囲む枠
+	ruler  *tview.TextView // 件名の長さの目安を示す目盛り
+	editor *tview.TextArea
+	output *tview.TextView // フックなどのコマンドの出力（出力があるときだけ表示する）
+	footer *tview.TextView // 件名の長さと使えるキーを表示する2行の領域
+	repo   Repository
+	queue  func(func()) // 別のゴルーチンの結果をUIのゴルーチンで処理する（app.QueueUpdateDraw）
+
+	amend      bool   // 直前のコミットを作り直すかどうか
+	prefill    string // 最後に入力欄に設定した初期値（書き換えていなければ切り替えで差し替える）
+	template   string // commit.templateの内容
+	initial    string // amendしないときの初期値（commit.templateがなければ直前のコミットのメッセージ）
+	message    string // 操作の結果
+	committing bool   // コミット中かどうか
+	generation int    // 初期値を読み込むたびに増やし、古い読み込み結果を捨てる
+
+	closeFunc  func()                    // ビューを閉じるときに呼ぶ関数
+	commitFunc func(output, head string) // コミットしたときに呼ぶ関数
+}
+
+// コミットメッセージの入力欄を作成
+func newCommitComposer(repo Repository, queue func(func())) *commitComposer {
+	c := &commitComposer{
+		ruler:  tview.NewTextView().SetDynamicColors(true).SetWrap(false),
+		editor: tview.NewTextArea().SetPlaceholder("Commit message (the first line is the subject)"),
+		output: tview.NewTextView().SetDynamicColors(true).SetScrollable(true),
+		footer: tview.NewTextView().SetDynamicColors(true),
+		repo:   repo,
+		queue:  queue,
+	}
+	c.output.SetBorder(true).SetTitle(" Output ")
+	c.editor.SetChangedFunc(c.render)
+	c.editor.SetInputCapture(c.handleKey)
+
+	c.box = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(c.ruler, 1, 0, false).
+		AddItem(c.editor, 0, 1, true)
+	c.box.SetBorder(true)
+	c.box.SetDrawFunc(func(screen tcell.Screen, x, y, width, height int) (int, int, int, int) {
+		// 枠の内側の幅に合わせて目盛りを描き直す
+		c.ruler.SetText(subjectRuler(width - 2))
+		return x + 1, y + 1, width - 2, height - 2
+	})
+
+	c.Flex = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(c.box, 0, 1, true).
+		AddItem(c.output, 0, 0, false).
+		AddItem(c.footer, 2, 0, false)
+	c.render()
+	return c
+}
+
+// ビューを閉じるときに呼ぶ関数を設定
+func (c *commitComposer) SetCloseFunc(handler func()) *commitComposer {
+	c.closeFunc = handler
+	return c
+}
+
+// コミットしたときに呼ぶ関数を設定（コマンドの出力と新しいHEADを渡す）
+func (c *commitComposer) SetCommitFunc(handler func(output, head string)) *commitComposer {
+	c.commitFunc = handler
+	return c
+}
+
+// 入力欄を開く
+// 書きかけのメッセージがなければcommit.templateの内容を初期値にし、
+// commit.templateがなければ直前のコミットのメッセージを初期値にする
+func (c *commitComposer) Open() {
+	c.message = ""
+	c.showOutput("")
+	c.generation++
+	generation := c.generation
+	go func() {
+		template, err := c.repo.CommitTemplate()
+		initial := template
+		if err == nil && template == "" {
+			// まだコミットがなければ読めないので空のままにする
+			if message, err := c.repo.HeadCommitMessage(); err == nil {
+				initial = message
+			}
+		}
+		c.queue(func() {
+			if generation != c.generation {
+				return
+			}
+			if err != nil {
+				c.message = fmt.Sprintf("[red]Failed to read commit template: %s[-]", tview.Escape(formatMessage(err.Error())))
+			}
+			c.template = template
+			c.initial = initial
+			if !c.amend && c.editor.GetText() == "" {
+				c.setPrefill(initial)
+			}
+			c.render()
+		})
+	}()
+	c.render()
+}
+
+// 入力欄に初期値を設定する
+func (c *commitComposer) setPrefill(text string) {
+	c.prefill = text
+	c.editor.SetText(text, false)
+}
+
+// 書き換えていない初期値だけが入っているかどうか
+func (c *commitComposer) unmodified() bool {
+	text := c.editor.GetText()
+	return text == "" || text == c.prefill
+}
+
+// amendするかどうかを切り替える
+// メッセージを書き換えていなければ、amendするときは直前のコミットのメッセージを初期値にする
+func (c *commitComposer) toggleAmend() {
+	c.amend = !c.amend
+	if !c.unmodified() {
+		return
+	}
+	if !c.amend {
+		c.generation++
+		c.setPrefill(c.initial)
+		return
+	}
+	c.generation++
+	generation := c.generation
+	go func() {
+		message, err := c.repo.HeadCommitMessage()
+		c.queue(func() {
+			if generation != c.generation || !c.amend {
+				return
+			}
+			if err != nil {
+				c.message = fmt.Sprintf("[red]Failed to read the last commit message: %s[-]", tview.Escape(formatMessage(err.Error())))
+			} else if c.unmodified() {
+				c.setPrefill(message)
+			}
+			c.render()
+		})
+	}()
+}
+
+// 書いたメッセージでコミットする
+func (c *commitComposer) commit() {
+	message := c.editor.GetText()
+	switch {
+	case cleanupMessage(message) == "":
+		c.message = "[yellow]The commit message is empty[-]"
+		return
+	case !c.amend && c.template != "" && cleanupMessage(message) == cleanupMessage(c.template):
+		c.message = "[yellow]The commit template has not been edited[-]"
+		return
+	}
+
+	c.committing = true
+	c.message = "Committing…"
+	amend := c.amend
+	go func() {
+		output, err := c.repo.Commit(message, amend)
+		head := ""
+		if err == nil {
+			head, err = c.repo.HeadCommitHash()
+		}
+		c.queue(func() {
+			c.committing = false
+			c.showOutput(output)
+			if err != nil {
+				c.message = fmt.Sprintf("[red]Commit failed: %s[-]", tview.Escape(formatMessage(err.Error())))
+				c.render()
+				return
+			}
+
+			// 次のコミットのために空にする
+			c.message = ""
+			c.amend = false
+			c.generation++
+			c.setPrefill("")
+			c.showOutput("")
+			c.render()
+			if c.commitFunc != nil {
+				c.commitFunc(output, head)
+			}
+		})
+	}()
+}
+
+// コマンドの出力を表示する（空なら出力の領域を隠す）
+func (c *commitComposer) showOutput(output string) {
+	output = strings.TrimRight(output, "\n")
+	c.output.SetText(tview.Escape(output))
+	height := 0
+	if output != "" {
+		height = min(strings.Count(output, "\n")+3, 12)
+	}
+	c.ResizeItem(c.output, height, 0)
+}
+
+// 件名の長さの目安を示す目盛りを作る（50文字と72文字の位置に印を付ける）
+func subjectRuler(width int) string {
+	var b strings.Builder
+	b.WriteString("[gray]")
+	for col := 1; col <= width; col++ {
+		switch {
+		case col == subjectSoftLimit:
+			b.WriteString("[yellow]|[gray]")
+		case col == subjectHardLimit:
+			b.WriteString("[red]|[gray]")
+		case col%10 == 0:
+			b.WriteString("+")
+		default:
+			b.WriteString("-")
+		}
+	}
+	b.WriteString("[-]")
+	return b.String()
+}
+
+// 件名の長さを目安に合わせた色で表示用に整形する
+func subjectLength(message string) string {
+	subject, _, _ := strings.Cut(message, "\n")
+	length := runewidth.StringWidth(subject)
+	color := "green"
+	if length > subjectHardLimit {
+		color = "red"
+	} else if length > subjectSoftLimit {
+		color = "yellow"
+	}
+	return fmt.Sprintf("[%s]Subject: %d/%d[-]", color, length, subjectSoftLimit)
+}
+
+// 件名の長さと操作の結果を描画する
+func (c *commitComposer) render() {
+	if c.amend {
+		c.box.SetTitle(" Commit (amend) ")
+	} else {
+		c.box.SetTitle(" Commit ")
+	}
+
+	c.footer.Clear()
+	line := subjectLength(c.editor.GetText())
+	if c.amend {
+		line += "  [yellow::b]amend[-::-]"
+	}
+	if c.message != "" {
+		line += "  " + c.message
+	}
+	c.footer.Write([]byte(line))
+	c.footer.Write([]byte("\n[gray]Ctrl-S: commit  Ctrl-T: toggle amend  Esc: close (the draft is kept)[-]"))
+}
+
+// キー入力のハンドリング
+func (c *commitComposer) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	if c.committing {
+		// コミット中はメッセージを変えられないようにする
+		return nil
+	}
+	switch event.Key() {
+	case tcell.KeyCtrlS:
+		c.commit()
+	case tcell.KeyCtrlT:
+		c.message = ""
+		c.toggleAmend()
+	case tcell.KeyEscape:
+		if c.closeFunc != nil {
+			c.closeFunc()
+		}
+		return nil
+	default:
+		return event
+	}
+	c.render()
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// 変更されたファイルごとの行数
+type FileStat struct {
+	Path      string
+	Additions int // 追加行数
+	Deletions int // 削除行数
+	Binary    bool
+}
+
+// コミットの詳細情報を格納する構造体
+type CommitDetail struct {
+	Hash           string
+	Parents        []string
+	Refs           []string // コミットを指しているrefのリスト
+	Author         string
+	AuthorEmail    string
+	AuthorDate     string
+	Committer      string
+	CommitterEmail string
+	CommitterDate  string
+	Message        string     // 改行を含む完全なコミットメッセージ
+	Files          []FileStat // 最初の親との差分で変更されたファイル
+}
+
+// タグの情報（注釈付きタグなら作成者とメッセージも含む）
+type TagDetail struct {
+	Name        string
+	Annotated   bool
+	Tagger      string
+	TaggerEmail string
+	TaggerDate  string
+	Message     string
+}
+
+// --statのような棒グラフの最大幅
+const statBarWidth = 20
+
+// コミットの詳細情報を表示用の文字列に整形する
+func formatCommitDetail(detail CommitDetail) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "[yellow]commit %s[-]\n", detail.Hash)
+	if len(detail.Refs) > 0 {
+		fmt.Fprintf(&b, "Refs:      [aqua]%s[-]\n", tview.Escape(strings.Join(detail.Refs, ", ")))
+	}
+	if len(detail.Parents) > 0 {
+		var parents []string
+		for _, parent := range detail.Parents {
+			parents = append(parents, parent[:min(7, len(parent))])
+		}
+		fmt.Fprintf(&b, "Parents:   %s\n", strings.Join(parents, " "))
+	}
+	fmt.Fprintf(&b, "Author:    %s <%s>\n", tview.Escape(detail.Author), tview.Escape(detail.AuthorEmail))
+	fmt.Fprintf(&b, "           %s\n", detail.AuthorDate)
+	fmt.Fprintf(&b, "Committer: %s <%s>\n", tview.Escape(detail.Committer), tview.Escape(detail.CommitterEmail))
+	fmt.Fprintf(&b, "           %s\n", detail.CommitterDate)
+
+	// コミットメッセージはインデントして全文を表示
+	b.WriteString("\n")
+	for _, line := range strings.Split(strings.TrimRight(detail.Message, "\n"), "\n") {
+		fmt.Fprintf(&b, "    %s\n", tview.Escape(line))
+	}
+
+	if len(detail.Files) == 0 {
+		return b.String()
+	}
+
+	// 変更されたファイルの一覧を--statのように表示
+	b.WriteString("\n")
+	maxChanges := 0
+	totalAdditions, totalDeletions := 0, 0
+	for _, file := range detail.Files {
+		maxChanges = max(maxChanges, file.Additions+file.Deletions)
+		totalAdditions += file.Additions
+		totalDeletions += file.Deletions
+	}
+	for _, file := range detail.Files {
+		if file.Binary {
+			fmt.Fprintf(&b, " %s | Bin\n", tview.Escape(file.Path))
+			continue
+		}
+		additions, deletions := file.Additions, file.Deletions
+		if maxChanges > statBarWidth {
+			// 棒グラフが長くなりすぎないように縮める
+			additions = (additions*statBarWidth + maxChanges - 1) / maxChanges
+			deletions = (deletions*statBarWidth + maxChanges - 1) / maxChanges
+		}
+		fmt.Fprintf(&b, " %s | %d [green]%s[red]%s[-]\n", tview.Escape(file.Path), file.Additions+file.Deletions,
+			strings.Repeat("+", additions), strings.Repeat("-", deletions))
+	}
+	fmt.Fprintf(&b, " %d files changed, %d insertions(+), %d deletions(-)\n", len(detail.Files), totalAdditions, totalDeletions)
+
+	return b.String()
+}
+
+// 注釈付きタグの情報を表示用の文字列に整形する（軽量タグは名前だけ）
+func formatTagDetail(tag TagDetail) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "[fuchsia]tag %s[-]\n", tview.Escape(tag.Name))
+	if !tag.Annotated {
+		return b.String()
+	}
+	fmt.Fprintf(&b, "Tagger:    %s <%s>\n", tview.Escape(tag.Tagger), tview.Escape(tag.TaggerEmail))
+	fmt.Fprintf(&b, "           %s\n", tag.TaggerDate)
+	b.WriteString("\n")
+	for _, line := range strings.Split(strings.TrimRight(tag.Message, "\n"), "\n") {
+		fmt.Fprintf(&b, "    %s\n", tview.Escape(line))
+	}
+	return b.String()
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// 差分の1行
+type diffLine struct {
+	Kind byte   // ' '（変更なし）、'+'（追加）、'-'（削除）、'\\'（末尾の改行なし）
+	Text string // 先頭の記号を除いた内容
+}
+
+// 差分のハンク（"@@"で始まるまとまり）
+type diffHunk struct {
+	Header   string // "@@ -1,3 +1,4 @@" の行
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []diffLine
+}
+
+// ファイルごとの差分
+type diffFile struct {
+	Header []string // "diff --git"から"+++"までの行
+	Path   string   // 表示用のファイル名
+	Hunks  []diffHunk
+}
+
+// ハンクの先頭行を解析するための正規表現
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ハンクの先頭行から行番号と行数を取り出す
+func parseHunkHeader(line string) (diffHunk, bool) {
+	m := hunkHeaderPattern.FindStringSubmatch(line)
+	if m == nil {
+		return diffHunk{}, false
+	}
+
+	// 行数が省略されている場合は1行
+	number := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	return diffHunk{
+		Header:   line,
+		OldStart: number(m[1]),
+		OldLines: number(m[2]),
+		NewStart: number(m[3]),
+		NewLines: number(m[4]),
+	}, true
+}
+
+// "diff --git a/foo b/foo" の行からファイル名を取り出す
+func diffGitPath(line string) string {
+	line = strings.TrimPrefix(line, "diff --git ")
+	if i := strings.Index(line, " b/"); i >= 0 {
+		return line[i+3:]
+	}
+	return line
+}
+
+// unified形式の差分を解析する
+func parseDiff(text string) []diffFile {
+	var files []diffFile
+	var file *diffFile
+	var hunk *diffHunk
+
+	for _, line := range strings.Split(text, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			// 新しいファイルの開始
+			files = append(files, diffFile{Header: []string{line}, Path: diffGitPath(line)})
+			file = &files[len(files)-1]
+			hunk = nil
+
+		case file == nil:
+			// 最初のファイルより前の行は無視
+
+		case strings.HasPrefix(line, "@@"):
+			h, ok := parseHunkHeader(line)
+			if !ok {
+				continue
+			}
+			file.Hunks = append(file.Hunks, h)
+			hunk = &file.Hunks[len(file.Hunks)-1]
+
+		case line == "":
+			// 出力の末尾などの空行は無視
+
+		case hunk == nil:
+			// ハンクより前はファイルのヘッダ
+			file.Header = append(file.Header, line)
+			if strings.HasPrefix(line, "+++ ") && line != "+++ /dev/null" {
+				file.Path = strings.TrimPrefix(strings.TrimPrefix(line, "+++ "), "b/")
+			}
+
+		default:
+			hunk.Lines = append(hunk.Lines, diffLine{Kind: line[0], Text: line[1:]})
+		}
+	}
+
+	return files
+}
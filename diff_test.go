@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHunkHeader(t *testing.T) {
+	tests := []struct {
+		line string
+		want diffHunk
+		ok   bool
+	}{
+		{"@@ -1,3 +1,4 @@", diffHunk{OldStart: 1, OldLines: 3, NewStart: 1, NewLines: 4}, true},
+		{"@@ -10 +12,0 @@ func main() {", diffHunk{OldStart: 10, OldLines: 1, NewStart: 12, NewLines: 0}, true},
+		{"@@ -0,0 +1 @@", diffHunk{OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 1}, true},
+		{"@@@ -1,2 -1,2 +1,3 @@@", diffHunk{}, false},
+		{"not a hunk", diffHunk{}, false},
+	}
+
+	for _, test := range tests {
+		got, ok := parseHunkHeader(test.line)
+		if ok != test.ok {
+			t.Errorf("parseHunkHeader(%q) ok = %v", test.line, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		test.want.Header = test.line
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseHunkHeader(%q) = %+v; want %+v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestParseDiff(t *testing.T) {
+	text := `diff --git a/a.txt b/a.txt
+index 1111111..2222222 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,2 +1,2 @@
+ keep
+-old
++new
+@@ -10 +10,2 @@
+ ten
++eleven
+\ No newline at end of file
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/bin.dat b/bin.dat
+Binary files a/bin.dat and b/bin.dat differ
+`
+	files := parseDiff(text)
+	if len(files) != 3 {
+		t.Fatalf("parseDiff() returned %d files", len(files))
+	}
+
+	a := files[0]
+	if a.Path != "a.txt" || len(a.Header) != 4 || len(a.Hunks) != 2 {
+		t.Errorf("a.txt = %+v", a)
+	}
+	wantLines := []diffLine{{' ', "keep"}, {'-', "old"}, {'+', "new"}}
+	if !reflect.DeepEqual(a.Hunks[0].Lines, wantLines) {
+		t.Errorf("a.txt hunk 0 = %+v", a.Hunks[0].Lines)
+	}
+	if last := a.Hunks[1].Lines[len(a.Hunks[1].Lines)-1]; last.Kind != '\\' {
+		t.Errorf("a.txt hunk 1 last line = %+v", last)
+	}
+
+	// 削除されたファイルは"diff --git"の行からファイル名を取る
+	if files[1].Path != "gone.txt" || len(files[1].Hunks) != 1 {
+		t.Errorf("gone.txt = %+v", files[1])
+	}
+	if files[2].Path != "bin.dat" || len(files[2].Hunks) != 0 || len(files[2].Header) != 2 {
+		t.Errorf("bin.dat = %+v", files[2])
+	}
+}
+
+func TestParseDiffEmpty(t *testing.T) {
+	if files := parseDiff(""); len(files) != 0 {
+		t.Errorf("parseDiff(\"\") = %+v", files)
+	}
+}
@@ -0,0 +1,612 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// 左右に並べて表示できる最小の画面幅
+const sideBySideMinWidth = 80
+
+// 差分をスクロールして表示するビュー
+// 作業ツリーやインデックスの差分では、カーソルで選んだ行やハンクをステージングできる
+type diffView struct {
+	*tview.Flex
+	text          *tview.TextView
+	footer        *tview.TextView // ステージングの操作や問い合わせを表示する2行の領域
+	queue         func(func())    // 別のゴルーチンの結果をUIのゴルーチンで処理する（app.QueueUpdateDraw）
+	title         string
+	files         []diffFile
+	message       string                 // 差分の代わりに表示するメッセージ（読み込み中など）
+	sideBySide    bool                   // 左右に並べて表示するかどうか
+	hunkRows      []int                  // 各ハンクの表示開始行
+	fileRows      []int                  // 各ファイルの表示開始行
+	renderedWidth int                    // 最後に描画したときの幅
+	load          func() (string, error) // 差分を読み込む関数（ステージングした後に読み込み直す）
+	generation    int                    // 読み込むたびに増やし、前の読み込み結果を捨てる
+	closeFunc     func()                 // ビューを閉じるときに呼ぶ関数
+
+	// ステージングの状態
+	staging     *diffStaging
+	rowLines    map[int]diffRowLine // 表示行 -> 差分の変更行
+	changeRows  []int               // 変更行の表示行
+	cursor      int                 // カーソルのある表示行
+	anchor      int                 // 範囲選択の開始行（-1なら範囲選択していない）
+	prompt      *statusPrompt       // 確認中の問い合わせ
+	stageResult string              // 操作の結果
+	applying    bool                // パッチを適用中かどうか
+}
+
+// 作業ツリーやインデックスの差分でステージングを行うための設定
+type diffStaging struct {
+	staged bool                                           // インデックスの差分（選んだ変更をステージングから外す）
+	apply  func(patch string, cached, reverse bool) error // パッチを適用する関数
+}
+
+// 表示行に対応する差分の変更行
+type diffRowLine struct {
+	File int
+	diffPosition
+}
+
+// 差分表示ビューを作成
+func newDiffView(queue func(func())) *diffView {
+	v := &diffView{
+		text: tview.NewTextView().
+			SetDynamicColors(true).
+			SetScrollable(true).
+			SetWrap(false),
+		footer:        tview.NewTextView().SetDynamicColors(true),
+		queue:         queue,
+		renderedWidth: -1,
+		anchor:        -1,
+	}
+	v.text.SetBorder(true)
+	v.text.SetInputCapture(v.handleKey)
+	v.Flex = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(v.text, 0, 1, true).
+		AddItem(v.footer, 0, 0, false)
+	return v
+}
+
+// ビューを閉じるときに呼ぶ関数を設定
+func (v *diffView) SetCloseFunc(handler func()) *diffView {
+	v.closeFunc = handler
+	return v
+}
+
+// 差分を別のゴルーチンで読み込んで表示する
+// stagingを指定すると、選んだ行やハンクをステージングできる（nilなら表示だけ）
+func (v *diffView) Load(title string, load func() (string, error), staging *diffStaging) {
+	v.load = load
+	v.staging = staging
+	v.prompt = nil
+	v.stageResult = ""
+	v.cursor = 0
+	v.anchor = -1
+	if staging != nil {
+		v.ResizeItem(v.footer, 2, 0)
+	} else {
+		v.ResizeItem(v.footer, 0, 0)
+	}
+	v.SetMessage(title, "Loading...")
+	v.reload(false)
+}
+
+// 差分を読み込み直す（keepCursorならカーソルを同じ位置の近くに残す）
+func (v *diffView) reload(keepCursor bool) {
+	v.generation++
+	generation := v.generation
+	title, load := v.title, v.load
+
+	// 大きなコミットでも操作が止まらないように非同期で読み込む
+	go func() {
+		diff, err := load()
+		v.queue(func() {
+			if generation != v.generation {
+				return
+			}
+			if err != nil {
+				v.SetMessage(title, fmt.Sprintf("Failed to load diff: %v", err))
+				return
+			}
+			if keepCursor {
+				v.updateDiff(diff)
+			} else {
+				v.SetDiff(title, diff)
+			}
+		})
+	}()
+}
+
+// 表示する差分を設定
+func (v *diffView) SetDiff(title, text string) {
+	v.title = title
+	v.files = parseDiff(text)
+	v.message = ""
+	if len(v.files) == 0 {
+		v.message = "No changes"
+	}
+	v.cursor = 0
+	v.render()
+	v.text.ScrollToBeginning()
+	if len(v.changeRows) > 0 {
+		v.moveCursor(v.changeRows[0])
+	}
+}
+
+// ステージングした後の差分に差し替える（スクロール位置とカーソルの位置はそのまま）
+func (v *diffView) updateDiff(text string) {
+	v.files = parseDiff(text)
+	v.message = ""
+	if len(v.files) == 0 {
+		v.message = "No changes"
+	}
+	v.render()
+	v.moveCursor(v.cursor)
+}
+
+// 差分の代わりにメッセージを表示
+func (v *diffView) SetMessage(title, message string) {
+	v.title = title
+	v.files = nil
+	v.message = message
+	v.render()
+	v.text.ScrollToBeginning()
+}
+
+// 画面幅が変わったときは描画し直す（左右表示の幅を合わせるため）
+func (v *diffView) Draw(screen tcell.Screen) {
+	_, _, width, _ := v.text.GetInnerRect()
+	if width != v.renderedWidth {
+		v.render()
+	}
+	v.Flex.Draw(screen)
+}
+
+// 左右に並べて表示するかどうか（画面が狭いときとステージングするときは常に1列）
+func (v *diffView) useSideBySide() bool {
+	_, _, width, _ := v.text.GetInnerRect()
+	return v.sideBySide && v.staging == nil && width >= sideBySideMinWidth
+}
+
+// 選択している変更行（範囲選択していなければカーソルの行）
+func (v *diffView) selectedRows() (int, int) {
+	if v.anchor < 0 {
+		return v.cursor, v.cursor
+	}
+	return min(v.anchor, v.cursor), max(v.anchor, v.cursor)
+}
+
+// 差分を描画する
+func (v *diffView) render() {
+	_, _, width, _ := v.text.GetInnerRect()
+	v.renderedWidth = width
+
+	mode := "unified"
+	if v.useSideBySide() {
+		mode = "side-by-side"
+	}
+	if v.staging != nil {
+		v.text.SetTitle(fmt.Sprintf(" %s - ↑↓: line  ]/[: hunk  }/{: file  q: close ", v.title))
+	} else {
+		v.text.SetTitle(fmt.Sprintf(" %s (%s) - ]/[: hunk  }/{: file  s: side-by-side  q: close ", v.title, mode))
+	}
+	v.renderFooter()
+
+	v.hunkRows = nil
+	v.fileRows = nil
+	v.rowLines = make(map[int]diffRowLine)
+	v.changeRows = nil
+	if v.message != "" {
+		v.text.SetText(tview.Escape(v.message))
+		return
+	}
+
+	var b strings.Builder
+	row := 0
+	writeLine := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString("\n")
+		row++
+	}
+	first, last := v.selectedRows()
+
+	for f, file := range v.files {
+		v.fileRows = append(v.fileRows, row)
+		for _, line := range file.Header {
+			writeLine("[yellow::b]%s[-::-]", tview.Escape(line))
+		}
+
+		for h, hunk := range file.Hunks {
+			v.hunkRows = append(v.hunkRows, row)
+			writeLine("[aqua]%s[-]", tview.Escape(hunk.Header))
+			if v.useSideBySide() {
+				for _, line := range sideBySideLines(hunk, (width-1)/2) {
+					writeLine("%s", line)
+				}
+				continue
+			}
+			for i, line := range hunk.Lines {
+				if v.staging == nil || (line.Kind != '+' && line.Kind != '-') {
+					writeLine("%s", formatDiffLine(line))
+					continue
+				}
+				// ステージングできる行はカーソルと選択範囲を表示する
+				v.rowLines[row] = diffRowLine{File: f, diffPosition: diffPosition{Hunk: h, Line: i}}
+				v.changeRows = append(v.changeRows, row)
+				text := tview.Escape(string(line.Kind) + line.Text)
+				switch {
+				case row == v.cursor:
+					writeLine("[black:white]%s[-:-]", text)
+				case row >= first && row <= last:
+					writeLine("[white:blue]%s[-:-]", text)
+				default:
+					writeLine("%s", formatDiffLine(line))
+				}
+			}
+		}
+	}
+
+	v.text.SetText(b.String())
+}
+
+// ステージングの操作や問い合わせを表示する
+func (v *diffView) renderFooter() {
+	v.footer.Clear()
+	if v.staging == nil {
+		return
+	}
+	if v.prompt != nil {
+		v.footer.Write([]byte(v.prompt.Render()))
+		return
+	}
+
+	action := "stage"
+	if v.staging.staged {
+		action = "unstage"
+	}
+	help := fmt.Sprintf("Space: %s lines  Enter: %s hunk  v: select range", action, action)
+	if !v.staging.staged {
+		help += "  x: discard lines"
+	}
+	if v.applying {
+		v.footer.Write([]byte("Applying..."))
+	} else if v.stageResult != "" {
+		v.footer.Write([]byte(v.stageResult))
+	} else if v.anchor >= 0 {
+		v.footer.Write([]byte("Selecting lines (v to cancel)"))
+	}
+	v.footer.Write([]byte("\n[gray]" + help + "[-]"))
+}
+
+// 差分の1行に色を付ける
+func formatDiffLine(line diffLine) string {
+	text := tview.Escape(string(line.Kind) + line.Text)
+	switch line.Kind {
+	case '+':
+		return "[green]" + text + "[-]"
+	case '-':
+		return "[red]" + text + "[-]"
+	case '\\':
+		return "[gray]" + text + "[-]"
+	}
+	return text
+}
+
+// 左右表示の片側のセルを作る（行番号が0のときは空欄）
+func sideBySideCell(number int, text string, color string, width int) string {
+	if number == 0 {
+		return strings.Repeat(" ", width)
+	}
+	cell := fmt.Sprintf("%4d %s", number, strings.ReplaceAll(text, "\t", "    "))
+	cell = runewidth.FillRight(runewidth.Truncate(cell, width, ""), width)
+	if color == "" {
+		return tview.Escape(cell)
+	}
+	return "[" + color + "]" + tview.Escape(cell) + "[-]"
+}
+
+// ハンクを左右（変更前と変更後）に並べた行に変換する
+func sideBySideLines(hunk diffHunk, width int) []string {
+	var rows []string
+	oldNumber, newNumber := hunk.OldStart, hunk.NewStart
+	var removed, added []diffLine
+
+	// 連続する削除行と追加行を1行ずつ対応させて出力する
+	flush := func() {
+		for i := 0; i < max(len(removed), len(added)); i++ {
+			left := sideBySideCell(0, "", "", width)
+			right := sideBySideCell(0, "", "", width)
+			if i < len(removed) {
+				left = sideBySideCell(oldNumber, removed[i].Text, "red", width)
+				oldNumber++
+			}
+			if i < len(added) {
+				right = sideBySideCell(newNumber, added[i].Text, "green", width)
+				newNumber++
+			}
+			rows = append(rows, left+"│"+right)
+		}
+		removed, added = nil, nil
+	}
+
+	for _, line := range hunk.Lines {
+		switch line.Kind {
+		case '-':
+			removed = append(removed, line)
+		case '+':
+			added = append(added, line)
+		case ' ':
+			flush()
+			rows = append(rows, sideBySideCell(oldNumber, line.Text, "", width)+"│"+sideBySideCell(newNumber, line.Text, "", width))
+			oldNumber++
+			newNumber++
+		}
+	}
+	flush()
+
+	return rows
+}
+
+// 現在のスクロール位置より後（または前）にある行へ移動する
+func (v *diffView) jump(rows []int, forward bool) {
+	current, _ := v.text.GetScrollOffset()
+	if v.staging != nil {
+		// ステージングするときはカーソルを移動する
+		current = v.cursor
+	}
+	target := -1
+	if forward {
+		for _, row := range rows {
+			if row > current {
+				target = row
+				break
+			}
+		}
+	} else {
+		for i := len(rows) - 1; i >= 0; i-- {
+			if rows[i] < current {
+				target = rows[i]
+				break
+			}
+		}
+	}
+	if target < 0 {
+		return
+	}
+	if v.staging == nil {
+		v.text.ScrollTo(target, 0)
+		return
+	}
+	// 移動した先の最初の変更行にカーソルを置き、見出しから表示する
+	v.text.ScrollTo(target, 0)
+	for _, row := range v.changeRows {
+		if row > target {
+			v.moveCursor(row)
+			return
+		}
+	}
+}
+
+// カーソルを表示行に移動する（変更行でなければ近くの変更行にする）
+func (v *diffView) moveCursor(row int) {
+	if len(v.changeRows) == 0 {
+		v.cursor = 0
+		return
+	}
+	nearest := v.changeRows[0]
+	for _, r := range v.changeRows {
+		if abs(r-row) < abs(nearest-row) {
+			nearest = r
+		}
+	}
+	v.cursor = nearest
+	v.render()
+
+	// カーソルが画面に表示されるようにスクロールする
+	_, _, _, height := v.text.GetInnerRect()
+	offset, _ := v.text.GetScrollOffset()
+	if v.cursor < offset {
+		v.text.ScrollTo(v.cursor, 0)
+	} else if height > 0 && v.cursor >= offset+height {
+		v.text.ScrollTo(v.cursor-height+1, 0)
+	}
+}
+
+// 整数の絶対値
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// カーソルを前後の変更行に移動する
+func (v *diffView) stepCursor(forward bool) {
+	for i, row := range v.changeRows {
+		if row != v.cursor {
+			continue
+		}
+		if forward && i+1 < len(v.changeRows) {
+			v.moveCursor(v.changeRows[i+1])
+		} else if !forward && i > 0 {
+			v.moveCursor(v.changeRows[i-1])
+		}
+		return
+	}
+}
+
+// 選んだ変更行のパッチを作って適用する
+// wholeHunkならカーソルのあるハンクの変更行をすべて選ぶ
+func (v *diffView) applySelection(wholeHunk, discard bool) {
+	cursorLine, ok := v.rowLines[v.cursor]
+	if !ok || v.applying {
+		return
+	}
+	selected := make(map[diffPosition]bool)
+	if wholeHunk {
+		for _, line := range v.rowLines {
+			if line.File == cursorLine.File && line.Hunk == cursorLine.Hunk {
+				selected[line.diffPosition] = true
+			}
+		}
+	} else {
+		// 選択範囲のうちカーソルと同じファイルの行だけを対象にする
+		first, last := v.selectedRows()
+		for row, line := range v.rowLines {
+			if row >= first && row <= last && line.File == cursorLine.File {
+				selected[line.diffPosition] = true
+			}
+		}
+	}
+
+	// ステージングから外す場合と捨てる場合は、逆向きのパッチにする
+	staging := v.staging
+	reverse := staging.staged || discard
+	patch := partialPatch(v.files[cursorLine.File], selected, reverse)
+	if patch == "" {
+		return
+	}
+
+	v.anchor = -1
+	v.applying = true
+	v.stageResult = ""
+	v.renderFooter()
+	go func() {
+		err := staging.apply(patch, !discard, reverse)
+		v.queue(func() {
+			v.applying = false
+			if err != nil {
+				v.stageResult = fmt.Sprintf("[red]Failed to apply patch: %s[-]", tview.Escape(formatMessage(err.Error())))
+			}
+			v.render()
+			if staging == v.staging {
+				v.reload(true)
+			}
+		})
+	}()
+}
+
+// キー入力のハンドリング
+func (v *diffView) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	// 問い合わせ中はその入力として扱う
+	if v.prompt != nil {
+		switch v.prompt.HandleKey(event) {
+		case inputAccepted:
+			prompt := v.prompt
+			v.prompt = nil
+			prompt.Accept()
+		case inputCanceled:
+			v.prompt = nil
+		}
+		v.renderFooter()
+		return nil
+	}
+
+	if event.Key() == tcell.KeyEscape {
+		if v.staging != nil && v.anchor >= 0 {
+			// 範囲選択を解除する
+			v.anchor = -1
+			v.render()
+			return nil
+		}
+		if v.closeFunc != nil {
+			v.closeFunc()
+		}
+		return nil
+	}
+
+	if v.staging != nil && v.message == "" {
+		v.stageResult = ""
+		switch event.Key() {
+		case tcell.KeyUp:
+			v.stepCursor(false)
+			return nil
+		case tcell.KeyDown:
+			v.stepCursor(true)
+			return nil
+		case tcell.KeyEnter:
+			v.applySelection(true, false)
+			return nil
+		}
+		switch event.Rune() {
+		case ' ':
+			v.applySelection(false, false)
+			return nil
+		case 'v':
+			// 範囲選択を開始または解除する
+			if v.anchor < 0 {
+				v.anchor = v.cursor
+			} else {
+				v.anchor = -1
+			}
+			v.render()
+			return nil
+		case 'x':
+			// 作業ツリーの変更は戻せないので確認する
+			if v.staging.staged {
+				return nil
+			}
+			first, last := v.selectedRows()
+			count := 0
+			for _, row := range v.changeRows {
+				if row >= first && row <= last {
+					count++
+				}
+			}
+			if count > 0 {
+				v.prompt = newConfirmPrompt(fmt.Sprintf("Discard %d changed lines from the working tree?", count), func() {
+					v.applySelection(false, true)
+				})
+				v.renderFooter()
+			}
+			return nil
+		case 's':
+			// ステージングするときは1列で表示する
+			return nil
+		}
+	}
+
+	switch event.Rune() {
+	case ']':
+		// 次のハンクへ
+		v.jump(v.hunkRows, true)
+	case '[':
+		// 前のハンクへ
+		v.jump(v.hunkRows, false)
+	case '}':
+		// 次のファイルへ
+		v.jump(v.fileRows, true)
+	case '{':
+		// 前のファイルへ
+		v.jump(v.fileRows, false)
+	case 's':
+		// 1列表示と左右表示を切り替え
+		// 表示中のハンクが画面の先頭に来るように位置を合わせる
+		current, _ := v.text.GetScrollOffset()
+		hunk := -1
+		for i, row := range v.hunkRows {
+			if row <= current {
+				hunk = i
+			}
+		}
+		v.sideBySide = !v.sideBySide
+		v.render()
+		if hunk >= 0 && hunk < len(v.hunkRows) {
+			v.text.ScrollTo(v.hunkRows[hunk], 0)
+		}
+	case 'q':
+		if v.closeFunc != nil {
+			v.closeFunc()
+		}
+	default:
+		return event
+	}
+	return nil
+}
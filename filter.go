@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// コミットログを絞り込む条件
+type LogFilter struct {
+	Author string // 作者（部分一致）
+	Since  string // この日時以降のコミット
+	Until  string // この日時以前のコミット
+	Path   string // このパスを変更したコミット
+	Ref    string // --allの代わりに表示するref
+}
+
+// 条件が指定されていないかどうか
+func (f LogFilter) IsEmpty() bool {
+	return f == LogFilter{}
+}
+
+// 表示するコミットの親が表示されないことがある条件かどうか
+// パスの条件では親が書き換えられるが、作者や日時の条件では書き換えられない
+func (f LogFilter) HidesParents() bool {
+	return f.Author != "" || f.Since != "" || f.Until != ""
+}
+
+// 表示されるコミットだけを親として残す（グラフの線が途切れたままにならないように）
+func shownParents(parents []string, shown map[string]bool) []string {
+	var kept []string
+	for _, parent := range parents {
+		if shown[parent] {
+			kept = append(kept, parent)
+		}
+	}
+	return kept
+}
+
+// 値に空白が含まれる場合は引用符で囲む
+func quoteFilterValue(value string) string {
+	if strings.ContainsAny(value, " \t") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// 入力欄やステータス表示に使う文字列に変換
+func (f LogFilter) String() string {
+	var parts []string
+	add := func(key, value string) {
+		if value != "" {
+			parts = append(parts, key+":"+quoteFilterValue(value))
+		}
+	}
+	add("author", f.Author)
+	add("since", f.Since)
+	add("until", f.Until)
+	add("ref", f.Ref)
+	add("path", f.Path)
+	return strings.Join(parts, " ")
+}
+
+// 空白で区切られた語に分割する（引用符で囲まれた部分は区切らない）
+func splitFilterWords(text string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inQuote := false
+	hasWord := false
+	for _, r := range text {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			hasWord = true
+		case (r == ' ' || r == '\t') && !inQuote:
+			if hasWord {
+				words = append(words, word.String())
+				word.Reset()
+				hasWord = false
+			}
+		default:
+			word.WriteRune(r)
+			hasWord = true
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if hasWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// "author:alice since:2024-01-01 path:src/" のような文字列を解析する
+// キーのない語はパスとして扱う
+func parseLogFilter(text string) (LogFilter, error) {
+	words, err := splitFilterWords(text)
+	if err != nil {
+		return LogFilter{}, err
+	}
+
+	var filter LogFilter
+	for _, word := range words {
+		key, value, found := strings.Cut(word, ":")
+		if !found {
+			key, value = "path", word
+		}
+		switch key {
+		case "author":
+			filter.Author = value
+		case "since":
+			filter.Since = value
+		case "until":
+			filter.Until = value
+		case "path":
+			filter.Path = value
+		case "ref":
+			if strings.HasPrefix(value, "-") {
+				return LogFilter{}, fmt.Errorf("invalid ref: %s", value)
+			}
+			filter.Ref = value
+		default:
+			return LogFilter{}, fmt.Errorf("unknown filter key: %s", key)
+		}
+	}
+	return filter, nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitFilterWords(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    []string
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"  a  b\tc ", []string{"a", "b", "c"}, false},
+		{`author:"John Smith" path:src`, []string{"author:John Smith", "path:src"}, false},
+		{`""`, []string{""}, false},
+		{`author:"open`, nil, true},
+	}
+
+	for _, test := range tests {
+		got, err := splitFilterWords(test.text)
+		if (err != nil) != test.wantErr {
+			t.Errorf("splitFilterWords(%q) error = %v", test.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("splitFilterWords(%q) = %q; want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestParseLogFilter(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    LogFilter
+		wantErr bool
+	}{
+		{"", LogFilter{}, false},
+		{
+			"author:alice since:2024-01-01 until:2024-02-01 path:src/ ref:main",
+			LogFilter{Author: "alice", Since: "2024-01-01", Until: "2024-02-01", Path: "src/", Ref: "main"},
+			false,
+		},
+		{`author:"John Smith"`, LogFilter{Author: "John Smith"}, false},
+		{"README.md", LogFilter{Path: "README.md"}, false}, // キーのない語はパス
+		{"since:yesterday", LogFilter{Since: "yesterday"}, false},
+		{"color:red", LogFilter{}, true},
+		{"ref:--output=/tmp/x", LogFilter{}, true}, // gitのオプションとして解釈されるref
+	}
+
+	for _, test := range tests {
+		got, err := parseLogFilter(test.text)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseLogFilter(%q) error = %v", test.text, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseLogFilter(%q) = %+v; want %+v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestLogFilterStringRoundTrip(t *testing.T) {
+	filter := LogFilter{Author: "John Smith", Since: "2024-01-01", Ref: "main", Path: "src/"}
+	got, err := parseLogFilter(filter.String())
+	if err != nil || got != filter {
+		t.Errorf("parseLogFilter(%q) = %+v, %v; want %+v", filter.String(), got, err, filter)
+	}
+}
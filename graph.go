@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// グラフの線がどの方向に接続しているかを表すビット
+const (
+	graphUp = 1 << iota
+	graphDown
+	graphLeft
+	graphRight
+)
+
+// グラフの描画に使う文字のセット
+type graphGlyphs struct {
+	node        rune         // コミットを表す文字
+	uncommitted rune         // 未コミットの変更を表す文字
+	horizontal  rune         // レーン間をつなぐ横線
+	lines       map[int]rune // 接続方向の組み合わせ -> 文字
+}
+
+// Unicodeの罫線文字を使ったグラフ
+var unicodeGraphGlyphs = graphGlyphs{
+	node:        '●',
+	uncommitted: '○',
+	horizontal:  '─',
+	lines: map[int]rune{
+		graphUp | graphDown:                          '│',
+		graphLeft | graphRight:                       '─',
+		graphUp | graphLeft:                          '┘',
+		graphUp | graphRight:                         '└',
+		graphDown | graphLeft:                        '┐',
+		graphDown | graphRight:                       '┌',
+		graphUp | graphDown | graphLeft:              '┤',
+		graphUp | graphDown | graphRight:             '├',
+		graphUp | graphLeft | graphRight:             '┴',
+		graphDown | graphLeft | graphRight:           '┬',
+		graphUp | graphDown | graphLeft | graphRight: '┼',
+	},
+}
+
+// ASCII文字だけを使ったグラフ（罫線文字を表示できない端末向け）
+var asciiGraphGlyphs = graphGlyphs{
+	node:        '*',
+	uncommitted: 'o',
+	horizontal:  '-',
+	lines: map[int]rune{
+		graphUp | graphDown:                          '|',
+		graphLeft | graphRight:                       '-',
+		graphUp | graphLeft:                          '/',
+		graphUp | graphRight:                         '\\',
+		graphDown | graphLeft:                        '\\',
+		graphDown | graphRight:                       '/',
+		graphUp | graphDown | graphLeft:              '+',
+		graphUp | graphDown | graphRight:             '+',
+		graphUp | graphLeft | graphRight:             '+',
+		graphDown | graphLeft | graphRight:           '+',
+		graphUp | graphDown | graphLeft | graphRight: '+',
+	},
+}
+
+// レーンごとに使う色
+var graphLaneColors = []string{"red", "green", "blue", "fuchsia", "aqua", "olive", "teal", "purple"}
+
+// 使用するグラフ文字を選択（環境変数CIT_GRAPH=asciiでASCII表示）
+func selectGraphGlyphs() graphGlyphs {
+	if os.Getenv("CIT_GRAPH") == "ascii" {
+		return asciiGraphGlyphs
+	}
+	return unicodeGraphGlyphs
+}
+
+// 空いているレーンを探し、なければ右端に追加する
+func allocateLane(lanes []string, exclude int) ([]string, int) {
+	for i, hash := range lanes {
+		if hash == "" && i != exclude {
+			return lanes, i
+		}
+	}
+	return append(lanes, ""), len(lanes)
+}
+
+// コミットを順に受け取ってレーンを計算するための状態
+// コミットログを少しずつ読み込むときも続きから計算できる
+type graphBuilder struct {
+	glyphs graphGlyphs
+	lanes  []string // 各レーンが次に待っているコミットのハッシュ
+}
+
+// グラフの計算を開始する
+func newGraphBuilder(glyphs graphGlyphs) *graphBuilder {
+	return &graphBuilder{glyphs: glyphs}
+}
+
+// 親コミットのハッシュからレーンを計算し、各コミットのGraphを設定する
+// commitsは子が親より先に並んでいる必要がある
+func (g *graphBuilder) Add(commits []Commit) {
+	lanes, glyphs := g.lanes, g.glyphs
+
+	for i := range commits {
+		commit := &commits[i]
+		parents := commit.Parents
+
+		// 上の行から線が来ているレーンを記録
+		above := make([]bool, len(lanes))
+		for j, hash := range lanes {
+			above[j] = hash != ""
+		}
+
+		// このコミットを待っているレーンを探す（複数あればそこで合流する）
+		col := -1
+		var targets []int
+		for j, hash := range lanes {
+			if hash != commit.Hash {
+				continue
+			}
+			if col < 0 {
+				col = j
+			} else {
+				targets = append(targets, j)
+				lanes[j] = ""
+			}
+		}
+		if col < 0 {
+			// どのレーンも待っていなければ新しいレーンを使う（ブランチの先端）
+			lanes, col = allocateLane(lanes, -1)
+		}
+
+		// 最初の親は同じレーンで引き継ぐ
+		lanes[col] = ""
+		if len(parents) > 0 {
+			lanes[col] = parents[0]
+		}
+
+		// マージ元の親は既存のレーンにつなぐか、新しいレーンを割り当てる
+		for _, parent := range parents[min(1, len(parents)):] {
+			lane := -1
+			for j, hash := range lanes {
+				if hash == parent && j != col {
+					lane = j
+					break
+				}
+			}
+			if lane < 0 {
+				lanes, lane = allocateLane(lanes, col)
+				lanes[lane] = parent
+			}
+			targets = append(targets, lane)
+		}
+
+		// 横線でつなぐ範囲
+		lo, hi := col, col
+		for _, t := range targets {
+			lo = min(lo, t)
+			hi = max(hi, t)
+		}
+
+		// 行を描画
+		width := max(len(above), len(lanes))
+		var graph strings.Builder
+		for j := 0; j < width; j++ {
+			if j == col {
+				if commit.IsUncommitted {
+					graph.WriteRune(glyphs.uncommitted)
+				} else {
+					graph.WriteRune(glyphs.node)
+				}
+			} else {
+				bits := 0
+				if j < len(above) && above[j] {
+					bits |= graphUp
+				}
+				if j < len(lanes) && lanes[j] != "" {
+					bits |= graphDown
+				}
+				if lo < j && j <= hi {
+					bits |= graphLeft
+				}
+				if lo <= j && j < hi {
+					bits |= graphRight
+				}
+				if r, ok := glyphs.lines[bits]; ok {
+					graph.WriteRune(r)
+				} else {
+					graph.WriteRune(' ')
+				}
+			}
+
+			// レーン間の横線
+			if lo <= j && j < hi {
+				graph.WriteRune(glyphs.horizontal)
+			} else {
+				graph.WriteRune(' ')
+			}
+		}
+		commit.Graph = graph.String()
+
+		// 右端の空きレーンを詰める
+		for len(lanes) > 0 && lanes[len(lanes)-1] == "" {
+			lanes = lanes[:len(lanes)-1]
+		}
+	}
+
+	g.lanes = lanes
+}
+
+// グラフ文字列にレーンごとの色を付ける
+func colorizeGraph(graph string) string {
+	var colored strings.Builder
+	for i, r := range []rune(graph) {
+		if r == ' ' {
+			colored.WriteRune(r)
+			continue
+		}
+		// 各レーンは2文字分（線とレーン間の横線）
+		color := graphLaneColors[(i/2)%len(graphLaneColors)]
+		if i%2 == 1 {
+			color = graphLaneColors[(i/2+1)%len(graphLaneColors)]
+		}
+		fmt.Fprintf(&colored, "[%s]%c[-]", color, r)
+	}
+	return colored.String()
+}
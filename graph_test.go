@@ -0,0 +1,87 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// "hash:parent1,parent2" の形式でコミットを作る
+func graphCommits(specs ...string) []Commit {
+	var commits []Commit
+	for _, spec := range specs {
+		hash, parents, _ := strings.Cut(spec, ":")
+		commit := Commit{Hash: hash, IsUncommitted: hash == "-"}
+		if parents != "" {
+			commit.Parents = strings.Split(parents, ",")
+		}
+		commits = append(commits, commit)
+	}
+	return commits
+}
+
+// コミットのグラフを行ごとに取り出す（末尾の空白は除く）
+func graphRows(commits []Commit) []string {
+	var rows []string
+	for _, commit := range commits {
+		rows = append(rows, strings.TrimRight(commit.Graph, " "))
+	}
+	return rows
+}
+
+func TestGraphBuilderAdd(t *testing.T) {
+	tests := []struct {
+		name    string
+		commits []string
+		want    []string
+	}{
+		{
+			name:    "linear",
+			commits: []string{"c3:c2", "c2:c1", "c1"},
+			want:    []string{"*", "*", "*"},
+		},
+		{
+			name:    "uncommitted on head",
+			commits: []string{"-:c2", "c2:c1", "c1"},
+			want:    []string{"o", "*", "*"},
+		},
+		{
+			name:    "branch and merge",
+			commits: []string{"m:a,b", "b:base", "a:base", "base"},
+			want:    []string{"*-\\", "| *", "* |", "*-/"},
+		},
+		{
+			name:    "two branch tips",
+			commits: []string{"x:base", "y:base", "base"},
+			want:    []string{"*", "| *", "*-/"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			commits := graphCommits(test.commits...)
+			newGraphBuilder(asciiGraphGlyphs).Add(commits)
+			if got := graphRows(commits); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("graph = %q; want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestGraphBuilderPages(t *testing.T) {
+	// ページに分けて追加しても、一度に追加したときと同じグラフになる
+	specs := []string{"m:a,b", "b:base", "a:base", "base:root", "root"}
+
+	whole := graphCommits(specs...)
+	newGraphBuilder(asciiGraphGlyphs).Add(whole)
+
+	paged := graphCommits(specs...)
+	builder := newGraphBuilder(asciiGraphGlyphs)
+	builder.Add(paged[:2])
+	builder.Add(paged[2:3])
+	builder.Add(paged[3:])
+
+	if !reflect.DeepEqual(graphRows(paged), graphRows(whole)) {
+		t.Errorf("paged graph = %q; want %q", graphRows(paged), graphRows(whole))
+	}
+}
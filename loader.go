@@ -0,0 +1,121 @@
+package main
+
+import (
+	"io"
+	"time"
+)
+
+// 1回に読み込むコミットの数
+const logPageSize = 500
+
+// コミットログを少しずつ読み込み、グラフの計算をしながらCommitのリストを作る
+// 読み込んだページはUIのゴルーチンに渡した後は変更しない
+type commitLoader struct {
+	reader LogReader
+	graph  *graphBuilder
+}
+
+// 未コミットの変更を表すダミーコミットを作成
+func newUncommittedCommit(repo Repository, headHash string) Commit {
+	// 変更の概要を取得
+	changesSummary, err := repo.UncommittedChangesSummary()
+	if err != nil {
+		changesSummary = "uncommitted changes"
+	}
+
+	// 現在の日時
+	now := time.Now().Format("2006-01-02 15:04:05")
+
+	uncommitted := Commit{
+		Hash:          "--------",
+		Author:        repo.UserName(),
+		Date:          now,
+		Message:       "Uncommitted Changes: " + changesSummary,
+		IsUncommitted: true,
+	}
+
+	// HEADの上に積まれた変更としてグラフに表示する
+	if headHash != "" {
+		uncommitted.Parents = []string{headHash}
+	}
+	return uncommitted
+}
+
+// コミットログの読み込みを開始し、最初のページを返す（条件が空ならすべてのブランチ）
+// 未コミットの変更がある場合は先頭に追加する
+func startCommitLoader(repo Repository, filter LogFilter) (*commitLoader, []Commit, bool, error) {
+	// 現在のHEADのハッシュを取得
+	headHash, err := repo.HeadCommitHash()
+	if err != nil {
+		// エラーがあってもプロセスは続行（未コミットの変更をHEADにつなげられないだけ）
+		headHash = ""
+	}
+
+	reader, err := repo.Log(filter)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	loader := &commitLoader{
+		reader: reader,
+		graph:  newGraphBuilder(selectGraphGlyphs()),
+	}
+
+	var commits []Commit
+	if repo.HasUncommittedChanges() {
+		commits = loader.add([]Commit{newUncommittedCommit(repo, headHash)})
+	}
+
+	page, done, err := loader.Next()
+	if err != nil {
+		loader.Close()
+		return nil, nil, false, err
+	}
+	commits = append(commits, page...)
+
+	return loader, commits, done, nil
+}
+
+// 読み込んだコミットにグラフを設定する
+func (l *commitLoader) add(commits []Commit) []Commit {
+	l.graph.Add(commits)
+	return commits
+}
+
+// 次のページを読み込む。最後まで読み込んだらdoneがtrueになる
+// 同時に複数のゴルーチンから呼び出してはいけない
+func (l *commitLoader) Next() ([]Commit, bool, error) {
+	page, err := l.reader.Next(logPageSize)
+	if err == io.EOF {
+		l.reader.Close()
+		return l.add(page), true, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return l.add(page), false, nil
+}
+
+// 読み込みを中止する
+func (l *commitLoader) Close() {
+	l.reader.Close()
+}
+
+// 読み込み直したコミットの中から、前に選択していたコミットの位置を探す
+// なくなっていれば前のリストで近くにあったコミットを順に探し、それもなければ同じ位置にする
+func findNearestCommit(commits, oldCommits []Commit, oldIndex int) int {
+	positions := make(map[string]int, len(commits))
+	for i, commit := range commits {
+		positions[commit.Hash] = i
+	}
+	for d := 0; d < len(oldCommits); d++ {
+		for _, j := range []int{oldIndex + d, oldIndex - d} {
+			if j < 0 || j >= len(oldCommits) {
+				continue
+			}
+			if i, ok := positions[oldCommits[j].Hash]; ok {
+				return i
+			}
+		}
+	}
+	return max(min(oldIndex, len(commits)-1), 0)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// 親子関係が一直線のコミットをn件作る（新しい順）
+func linearCommits(n int) []Commit {
+	commits := make([]Commit, n)
+	for i := range commits {
+		commits[i] = Commit{Hash: fmt.Sprintf("c%04d", n-i), Author: "Alice", Message: fmt.Sprintf("commit %d", n-i)}
+		if i < n-1 {
+			commits[i].Parents = []string{fmt.Sprintf("c%04d", n-i-1)}
+		}
+	}
+	return commits
+}
+
+func TestFindNearestCommit(t *testing.T) {
+	commits := func(hashes ...string) []Commit {
+		var list []Commit
+		for _, hash := range hashes {
+			list = append(list, Commit{Hash: hash})
+		}
+		return list
+	}
+	old := commits("a", "b", "c", "d", "e")
+
+	tests := []struct {
+		name     string
+		commits  []Commit
+		oldIndex int
+		want     int
+	}{
+		{"same list", old, 2, 2},
+		{"new commits on top", commits("x", "y", "a", "b", "c", "d", "e"), 2, 4},
+		{"selected removed, next kept", commits("a", "b", "d", "e"), 2, 2},
+		{"selected and next removed", commits("a", "b", "e"), 2, 1},
+		{"nothing left", commits("x", "y"), 4, 1},
+		{"empty", nil, 3, 0},
+	}
+
+	for _, test := range tests {
+		if got := findNearestCommit(test.commits, old, test.oldIndex); got != test.want {
+			t.Errorf("%s: findNearestCommit() = %d; want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestStartCommitLoader(t *testing.T) {
+	repo := &fakeRepository{head: "c0600", commits: linearCommits(600), uncommitted: "2 files changed"}
+
+	loader, commits, done, err := startCommitLoader(repo, LogFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loader.Close()
+
+	// 最初のページの前に未コミットの変更の行が入る
+	if done || len(commits) != logPageSize+1 {
+		t.Fatalf("first page: %d commits, done = %v", len(commits), done)
+	}
+	if !commits[0].IsUncommitted || commits[0].Message != "Uncommitted Changes: 2 files changed" || commits[0].Parents[0] != "c0600" {
+		t.Errorf("uncommitted row = %+v", commits[0])
+	}
+
+	page, done, err := loader.Next()
+	if err != nil || !done || len(page) != 100 {
+		t.Fatalf("second page: %d commits, done = %v, err = %v", len(page), done, err)
+	}
+	if page[len(page)-1].Hash != "c0001" || page[len(page)-1].Graph == "" {
+		t.Errorf("last commit = %+v", page[len(page)-1])
+	}
+}
+
+func TestStartCommitLoaderError(t *testing.T) {
+	repo := &fakeRepository{logErr: fmt.Errorf("bad revision")}
+	if _, _, _, err := startCommitLoader(repo, LogFilter{}); err == nil {
+		t.Error("startCommitLoader() succeeded; want error")
+	}
+}
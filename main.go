@@ -1,11 +1,10 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/gdamore/tcell/v2"
@@ -13,29 +12,23 @@ import (
 )
 
 // コミット情報を格納する構造体
+// 読み込んでUIに渡した後は変更しない（HEADやブランチの位置はRefIndexから判定する）
 type Commit struct {
-	Hash             string
-	Author           string
-	Date             string
-	Message          string
-	IsUncommitted    bool     // 未コミットの変更を表すフラグ
-	Branch           string   // コミットが属するブランチ名
-	BranchLoaded     bool     // ブランチ情報が読み込まれたかどうか
-	IsHead           bool     // HEADを指しているかどうか
-	Branches         []string // コミットに関連するブランチのリスト
-	HeadBranches     []string // このコミットを指しているブランチのリスト
-	BranchesLoaded   bool     // ブランチリストが読み込まれたかどうか
+	Hash          string
+	Author        string
+	Date          string
+	Message       string
+	IsUncommitted bool     // 未コミットの変更を表すフラグ
+	Parents       []string // 親コミットのハッシュのリスト
+	Graph         string   // コミットグラフの描画文字列
 }
 
-// ブランチ情報のキャッシュ用マップとミューテックス
-var (
-	branchCache     = make(map[string]string) // コミットハッシュ -> ブランチ名のマッピング
-	branchCacheLock sync.RWMutex
-)
+// Gitリポジトリのディレクトリ
+const gitDir = ".git"
 
 // Gitリポジトリが存在するか確認
 func checkGitRepository() bool {
-	_, err := os.Stat(".git")
+	_, err := os.Stat(gitDir)
 	return err == nil
 }
 
@@ -57,396 +50,1254 @@ func formatMessage(message string) string {
 	return strings.ReplaceAll(message, "\n", " ")
 }
 
-// 未コミットの変更があるか確認
-func hasUncommittedChanges() bool {
-	// git status --porcelain で未コミットの変更を確認
-	cmd := exec.Command("git", "status", "--porcelain")
-	output, err := cmd.Output()
-
-	// エラーまたは出力が空の場合は未コミットの変更なし
-	if err != nil || len(output) == 0 {
-		return false
+// gitコマンドの出力から、作成したコミットを表す"[branch hash] subject"の行を探す（なければfallback）
+func commitLine(output, fallback string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "[") {
+			return line
+		}
 	}
-
-	return true
+	return fallback
 }
 
-// 未コミットの変更の概要を取得
-func getUncommittedChangesSummary() (string, error) {
-	// 変更されたファイルの数を取得
-	cmdStatus := exec.Command("git", "status", "--porcelain")
-	statusOutput, err := cmdStatus.Output()
+func main() {
+	// Gitリポジトリの存在確認
+	if !checkGitRepository() {
+		fmt.Println("エラー: カレントディレクトリにGitリポジトリが存在しません。")
+		os.Exit(1)
+	}
+
+	// Gitリポジトリへのアクセス手段を用意
+	repo, err := openRepository(".")
 	if err != nil {
-		return "", err
+		fmt.Printf("エラー: Gitリポジトリを開けませんでした: %v\n", err)
+		os.Exit(1)
 	}
 
-	// 行ごとに分割して数をカウント
-	changedFiles := strings.Split(strings.TrimSpace(string(statusOutput)), "\n")
-	numChanges := len(changedFiles)
-	if numChanges == 0 {
-		return "", nil // 変更なし
+	// Gitコミットログの読み込みを開始（最初のページだけを読み込み、残りは後で読み込む）
+	loader, commits, loadingDone, err := startCommitLoader(repo, LogFilter{})
+	if err != nil {
+		fmt.Printf("エラー: Gitコミットログの取得に失敗しました: %v\n", err)
+		os.Exit(1)
 	}
 
-	return fmt.Sprintf("%d files changed", numChanges), nil
-}
+	// pullで上流の変更を取り込む方法（環境変数CIT_PULLで選択できる）
+	pullMode, err := parsePullMode(os.Getenv("CIT_PULL"))
+	if err != nil {
+		fmt.Printf("エラー: CIT_PULLの値が正しくありません: %v\n", err)
+		os.Exit(1)
+	}
 
-// 現在のHEADのコミットハッシュを取得
-func getHeadCommitHash() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "HEAD")
-	output, err := cmd.Output()
+	// ブランチとタグの一覧を読み込む（refが変わったときだけ読み込み直す）
+	refIndex, err := loadRefIndex(repo)
 	if err != nil {
-		return "", err
+		// 読み込めなくてもコミットログは表示できるので空のまま続行
+		refIndex = &RefIndex{}
 	}
 
-	return strings.TrimSpace(string(output)), nil
-}
+	// 途中で止まっているマージやリベースなど（読み込めなければ操作の途中ではないものとする）
+	operation, _ := repo.Operation()
 
-// 現在のHEADが指しているブランチ名を取得する
-func getCurrentBranchName() (string, bool) {
-	// git symbolic-ref --short HEAD でブランチ名を取得
-	cmd := exec.Command("git", "symbolic-ref", "--short", "HEAD")
-	output, err := cmd.Output()
+	app := tview.NewApplication()
 
-	// エラーの場合はdetached HEAD状態
-	if err != nil {
-		return "", false
+	// コミットログ表示用のTextViewを使用して、より細かい制御を可能にする
+	textView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetRegions(true).
+		SetScrollable(true).
+		SetWrap(false) // 画面に収まらない部分は右端で切り捨てる
+
+	// ステータス表示用の領域
+	statusArea := tview.NewTextView().
+		SetDynamicColors(true).
+		SetTextAlign(tview.AlignLeft)
+
+	// コミット詳細表示用のTextView（dキーで表示を切り替える）
+	detailView := tview.NewTextView().
+		SetDynamicColors(true).
+		SetScrollable(true).
+		SetWrap(true)
+	detailView.SetBorder(true).SetTitle(" Commit ")
+
+	// コミットリストと詳細表示を左右に並べる領域
+	listFlex := tview.NewFlex().
+		AddItem(textView, 0, 1, true)
+
+	// レイアウト設定 - FlexでTextViewの下に2行の余白を作成
+	flex := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(listFlex, 0, 1, true).   // テキストビューが伸縮するように比率を設定
+		AddItem(statusArea, 2, 0, false) // 下部に高さ2行の固定領域
+
+	// 別のゴルーチンで読み込んだ結果をビューに渡す
+	queueUpdate := func(f func()) { app.QueueUpdateDraw(f) }
+
+	// 差分表示用のビュー
+	diffViewer := newDiffView(queueUpdate)
+
+	// 未コミットの変更の一覧
+	statusViewer := newStatusView(repo, queueUpdate)
+
+	// コミットメッセージの入力欄
+	composer := newCommitComposer(repo, queueUpdate)
+
+	// ブランチの一覧
+	branchViewer := newBranchView(repo, queueUpdate)
+
+	// リベースの手順の編集画面
+	rebaseViewer := newRebasePlanner(repo, queueUpdate, func(p tview.Primitive) { app.SetFocus(p) })
+
+	// スタッシュの一覧
+	stashViewer := newStashView(repo, queueUpdate)
+
+	// マージコミットなどのメッセージの入力欄
+	messageEditor := newMessageEditor()
+
+	// 画面の切り替え用（コミットリストの上に差分表示などを重ねる）
+	pages := tview.NewPages().
+		AddPage("main", flex, true, true).
+		AddPage("status", statusViewer, true, false).
+		AddPage("diff", diffViewer, true, false).
+		AddPage("commit", composer, true, false).
+		AddPage("branches", branchViewer, true, false).
+		AddPage("rebase", rebaseViewer, true, false).
+		AddPage("stash", stashViewer, true, false).
+		AddPage("message", messageEditor, true, false)
+
+	// 以下の状態はUIのゴルーチン（キー入力の処理とQueueUpdateDraw）からだけ読み書きする
+	// 他のゴルーチンで読み込んだ結果はQueueUpdateDrawで渡す
+
+	// 現在選択されているコミットのインデックス
+	currentCommit := 0
+
+	// スクロール位置の管理（先頭からの行オフセット）
+	scrollOffset := 0
+
+	// 確認モードとブランチ選択モードのフラグ
+	confirmMode := false
+	branchSelectMode := false
+	confirmAfterBranchSelect := false // ブランチ選択後の確認モードフラグ
+	mergeAfterBranchSelect := false   // ブランチ選択後にそのブランチをマージするかどうか
+	mergeGeneration := 0              // マージの見積もりを始めるたびに増やし、古い見積もりの結果を捨てる
+
+	// チェックアウト操作の状態
+	isDetachedHeadMode := false // detached headモードかどうか
+	checkoutHash := ""          // チェックアウトするコミット（確認中にリストが読み込み直されても変わらない）
+
+	// ブランチ選択用の変数
+	var availableBranches []Ref // ローカルブランチとリモートブランチ
+	currentBranchIndex := 0
+
+	// 検索の状態
+	searchMode := false         // 検索文字列の入力中かどうか
+	searchInput := &lineInput{} // 入力中の検索文字列
+	searchQuery := ""           // 強調表示とn/Nに使う検索文字列
+	searchForward := true       // /なら下方向、?なら上方向に検索
+	searchOrigin := 0           // 検索を始めたときの選択位置
+
+	// 続きのページを読み込みながら探している検索
+	var pendingSearch *searchRequest
+
+	// 絞り込みの状態
+	filter := LogFilter{}       // 適用中の絞り込み条件
+	filterMode := false         // 絞り込み条件の入力中かどうか
+	filterInput := &lineInput{} // 入力中の絞り込み条件
+
+	// 操作の結果などをステータス領域の2行目に表示するメッセージ
+	statusMessage := ""
+
+	// ブランチの操作などでステータス領域に表示している問い合わせ
+	var actionPrompt *statusPrompt
+
+	// 途中で止まっている操作を設定する
+	// 操作の途中ではステータス領域を1行増やして、その状態を表示する
+	setOperation := func(newOperation Operation) {
+		operation = newOperation
+		height := 2
+		if operation.Kind != operationNone {
+			height = 3
+		}
+		flex.ResizeItem(statusArea, height, 0)
 	}
+	setOperation(operation)
 
-	// ブランチ名を返す
-	return strings.TrimSpace(string(output)), true
-}
+	// マージやリベースなどの途中ならactionを行わずにその旨を表示する（行えないならtrueを返す）
+	blockedByOperation := func(action string) bool {
+		if operation.Kind == operationNone {
+			return false
+		}
+		statusMessage = fmt.Sprintf("[yellow]Cannot %s while a %s is in progress; press o to continue or abort it[-]", action, operation.Kind)
+		return true
+	}
 
-// ブランチのコミットハッシュを取得
-func getBranchCommitHash(branchName string) (string, error) {
-	cmd := exec.Command("git", "rev-parse", branchName)
-	output, err := cmd.Output()
-	if err != nil {
-		return "", err
+	// 実行中のfetch、pull、push（同時には1つだけ実行する）
+	remoteOperation := "" // 実行中の操作の名前（実行中でなければ空）
+	remoteProgress := ""  // 最後に受け取った進捗の行
+
+	// 実行中のcherry-pick、revert、resetなどのHEADを動かす操作（同時には1つだけ実行する）
+	headAction := "" // 実行中の操作の名前（実行中でなければ空）
+
+	// コミットログの読み込み状態
+	loadingPage := false // 次のページを読み込み中かどうか
+	loadGeneration := 0  // 読み込み直すたびに増やし、古い読み込み結果を捨てる
+	reloading := false   // 別のゴルーチンで読み込み直している途中かどうか
+	reloadGeneration := 0
+	var requestMoreCommits, loadNextPage func()
+
+	// コミットを表示する関数（読み込み結果を受け取ったときにも呼び出すので先に宣言する）
+	var displayCommits func()
+
+	// 詳細表示の状態
+	detailVisible := false
+	detailHash := "" // 詳細表示中のコミットのハッシュ
+
+	// 選択中のコミットを取得する（リストが空ならfalse）
+	selectedCommit := func() (Commit, bool) {
+		if currentCommit < 0 || currentCommit >= len(commits) {
+			return Commit{}, false
+		}
+		return commits[currentCommit], true
 	}
 
-	return strings.TrimSpace(string(output)), nil
-}
+	// 選択中のコミットの詳細を表示する関数（選択が変わったときだけ読み込む）
+	showCommitDetail := func() {
+		commit, ok := selectedCommit()
+		if !detailVisible || !ok || commit.Hash == detailHash {
+			return
+		}
+		detailHash = commit.Hash
+		detailView.ScrollToBeginning()
 
-// コミットが属するブランチを取得（キャッシュを活用）
-func getCommitBranch(hash string) string {
-	// キャッシュを確認
-	branchCacheLock.RLock()
-	branch, exists := branchCache[hash]
-	branchCacheLock.RUnlock()
+		if commit.IsUncommitted {
+			detailView.SetText(tview.Escape(commit.Message))
+			return
+		}
 
-	if exists {
-		return branch
+		// 詳細の取得には時間がかかることがあるので非同期で読み込む
+		// コミットを指している注釈付きタグがあれば、その作成者とメッセージも表示する
+		detailView.SetText("Loading...")
+		tagNames := refIndex.NamesAt(commit.Hash, RefTag)
+		go func(hash string) {
+			detail, err := repo.CommitDetail(hash)
+			var tags []TagDetail
+			for _, name := range tagNames {
+				if tag, err := repo.TagDetail(name); err == nil && tag.Annotated {
+					tags = append(tags, tag)
+				}
+			}
+			app.QueueUpdateDraw(func() {
+				// 読み込み中に選択が移動した場合は結果を捨てる
+				if hash != detailHash {
+					return
+				}
+				if err != nil {
+					detailView.SetText(fmt.Sprintf("[red]Failed to load commit: %v[-]", err))
+					return
+				}
+				text := formatCommitDetail(detail)
+				for _, tag := range tags {
+					text += "\n" + formatTagDetail(tag)
+				}
+				detailView.SetText(text)
+			})
+		}(commit.Hash)
 	}
 
-	// キャッシュになければ取得して保存
-	cmd := exec.Command("git", "branch", "--contains", hash)
-	output, err := cmd.Output()
-	if err != nil {
-		return "" // エラーの場合は空文字列を返す
+	// 検索に一致するコミットを選択する
+	// 読み込んだ範囲の末尾（上方向なら先頭から折り返した末尾）まで見つからなければ、
+	// 続きのページを読み込みながら検索を続ける
+	runSearch := func(request searchRequest) {
+		index := findCommit(commits, refIndex, request.query, request.start, request.forward)
+		if !loadingDone && request.wrapped(index) {
+			pendingSearch = &request
+			loadNextPage()
+			return
+		}
+		pendingSearch = nil
+		if index >= 0 {
+			currentCommit = index
+		}
 	}
 
-	// 出力から現在のブランチを探す
-	branches := strings.Split(strings.TrimSpace(string(output)), "\n")
-	foundBranch := ""
-	for _, branch := range branches {
-		branch = strings.TrimSpace(branch)
-		if strings.HasPrefix(branch, "*") {
-			// 現在のブランチの場合、「* 」を除去
-			foundBranch = strings.TrimSpace(strings.TrimPrefix(branch, "*"))
-			break
-		} else if branch != "" && foundBranch == "" {
-			// 最初に見つけたブランチを保存
-			foundBranch = branch
+	// コミットログの読み込みを別のゴルーチンでやり直す
+	// 読み込んでいる間も操作できるように、結果はQueueUpdateDrawで渡して一度に切り替える
+	// keepSelectionなら選択中のコミットを選択したまま（なくなった場合は近くのコミットを選択）にし、
+	// スクロール位置は変えない。そうでなければ先頭を選択する
+	// targetを指定した場合は、そのコミットが見つかるまで読み込んで選択する
+	startReload := func(newFilter LogFilter, keepSelection bool, target string) {
+		reloadGeneration++
+		generation := reloadGeneration
+		reloading = true
+		failure := "Reload failed"
+		if newFilter != filter {
+			failure = "Filter failed"
 		}
+
+		// 選択していたコミットが見つかるまで読み込む
+		// 未コミットの変更の行を選択していた場合は、前と同じ数まで読み込む
+		selected, wanted := "", len(commits)
+		if commit, ok := selectedCommit(); ok && !commit.IsUncommitted {
+			selected = commit.Hash
+		}
+		if target != "" {
+			selected = target
+			keepSelection = true
+		}
+		go func() {
+			// コミットログと同じ時点のrefを表示するように、refも読み込み直す
+			index, indexErr := loadRefIndex(repo)
+			newOperation, operationErr := repo.Operation()
+
+			newLoader, newCommits, done, err := startCommitLoader(repo, newFilter)
+			// 読み込んだページに選択していたコミットがあるかどうか
+			found := func(page []Commit) bool {
+				if selected == "" {
+					return len(newCommits) >= wanted
+				}
+				for _, commit := range page {
+					if commit.Hash == selected {
+						return true
+					}
+				}
+				return false
+			}
+			for page := newCommits; err == nil && keepSelection && !done && !found(page); {
+				page, done, err = newLoader.Next()
+				newCommits = append(newCommits, page...)
+			}
+
+			app.QueueUpdateDraw(func() {
+				if err != nil && newLoader != nil {
+					newLoader.Close()
+				}
+				if generation != reloadGeneration {
+					// さらに読み込み直している場合は、この結果は捨てる
+					if err == nil {
+						newLoader.Close()
+					}
+					return
+				}
+				reloading = false
+				if err != nil {
+					statusMessage = fmt.Sprintf("[red]%s: %s[-]", failure, tview.Escape(formatMessage(err.Error())))
+					displayCommits()
+					return
+				}
+
+				// 前の読み込みを中止する（読み込み中ならその完了時に中止する）
+				if !loadingPage {
+					loader.Close()
+				}
+				loader = newLoader
+				loadGeneration++
+				loadingPage = false
+				loadingDone = done
+				pendingSearch = nil
+
+				if keepSelection {
+					currentCommit = findNearestCommit(newCommits, commits, currentCommit)
+					scrollOffset = max(min(scrollOffset, len(newCommits)-1), 0)
+					for i, commit := range newCommits {
+						if target != "" && commit.Hash == target {
+							currentCommit = i
+							break
+						}
+					}
+				} else {
+					currentCommit = 0
+					scrollOffset = 0
+					statusMessage = ""
+				}
+				commits = newCommits
+				filter = newFilter
+				if indexErr == nil {
+					refIndex = index
+				}
+				if operationErr == nil {
+					setOperation(newOperation)
+				}
+				detailHash = ""
+				displayCommits()
+			})
+		}()
 	}
 
-	// キャッシュに保存
-	branchCacheLock.Lock()
-	branchCache[hash] = foundBranch
-	branchCacheLock.Unlock()
+	// 絞り込み条件を変えてコミットログを読み込み直す
+	applyFilter := func(newFilter LogFilter) {
+		startReload(newFilter, false, "")
+	}
 
-	return foundBranch
-}
+	// コミットログを読み込み直す
+	reloadCommits := func() {
+		startReload(filter, true, "")
+	}
 
-// コミットが属する複数のブランチリストを取得
-func getCommitBranches(hash string) []string {
-	cmd := exec.Command("git", "branch", "--contains", hash)
-	output, err := cmd.Output()
-	if err != nil {
-		return []string{} // エラーの場合は空のスライスを返す
+	// コミットログを読み込み直してコミットを選択する（コミットを作った後など）
+	reloadAndSelect := func(hash string) {
+		startReload(filter, true, hash)
 	}
 
-	var branches []string
-	for _, branch := range strings.Split(strings.TrimSpace(string(output)), "\n") {
-		branch = strings.TrimSpace(branch)
-		// "*" で始まる場合はカレントブランチを示す
-		if strings.HasPrefix(branch, "*") {
-			branch = strings.TrimSpace(strings.TrimPrefix(branch, "*"))
+	// 新しいrefの一覧に切り替える
+	// 読み込んでいないコミットをrefが指すようになった場合や、refが削除された場合は
+	// 表示するコミットも変わるのでコミットログも読み込み直す
+	applyRefIndex := func(index *RefIndex) {
+		old := refIndex
+		refIndex = index
+		detailHash = "" // 詳細表示のrefも更新する
+
+		loaded := make(map[string]bool, len(commits))
+		for _, commit := range commits {
+			loaded[commit.Hash] = true
 		}
-
-		if branch != "" {
-			branches = append(branches, branch)
+		added, removed := index.ChangedTargets(old)
+		reload := len(removed) > 0
+		for _, hash := range added {
+			if !loaded[hash] {
+				reload = true
+			}
+		}
+		if index.Head != old.Head {
+			// 未コミットの変更はHEADの上に表示しているので、HEADが移動したら作り直す
+			hasUncommitted := len(commits) > 0 && commits[0].IsUncommitted
+			if hasUncommitted || !loaded[index.Head] {
+				reload = true
+			}
+		}
+		if reload {
+			reloadCommits()
 		}
 	}
 
-	return branches
-}
+	// refの一覧を読み込み直す（ブランチの切り替えなどの操作の後に呼び出す）
+	refreshRefs := func() {
+		if index, err := loadRefIndex(repo); err == nil {
+			applyRefIndex(index)
+		}
+	}
 
-// 一括でブランチマッピングを取得（高速化のため）
-func getBranchesForCommits(commits []Commit) {
-	// 非同期でマッピング情報を取得
-	go func() {
-		// すべてのブランチを一度だけ取得
-		cmd := exec.Command("git", "branch", "-a", "--format=%(objectname) %(refname:short)")
-		output, err := cmd.Output()
-		if err != nil {
+	// 未コミットの変更の行を更新する（変更がなければuncommittedはnil）
+	updateUncommitted := func(uncommitted *Commit) {
+		hasRow := len(commits) > 0 && commits[0].IsUncommitted
+		if (uncommitted != nil) != hasRow {
+			// 行が増減するとグラフのレーンも変わるので読み込み直す
+			reloadCommits()
 			return
 		}
+		if uncommitted != nil {
+			// 概要だけを新しい行に差し替える（グラフは同じ）
+			row := *uncommitted
+			row.Parents, row.Graph = commits[0].Parents, commits[0].Graph
+			commits[0] = row
+			if currentCommit == 0 {
+				detailHash = ""
+			}
+		}
+	}
 
-		// 結果をパースしてキャッシュに格納
-		branchMappings := strings.Split(strings.TrimSpace(string(output)), "\n")
-		branchCacheLock.Lock()
-		for _, mapping := range branchMappings {
-			parts := strings.SplitN(mapping, " ", 2)
-			if len(parts) == 2 {
-				commitHash := parts[0]
-				branchName := parts[1]
-				branchCache[commitHash] = branchName
+	// 未コミットの変更の行を別のゴルーチンで読み込み直す
+	refreshUncommitted := func() {
+		go func() {
+			var uncommitted *Commit
+			if repo.HasUncommittedChanges() {
+				row := newUncommittedCommit(repo, "")
+				uncommitted = &row
 			}
+			app.QueueUpdateDraw(func() {
+				updateUncommitted(uncommitted)
+				displayCommits()
+			})
+		}()
+	}
+
+	// 差分を表示する関数（閉じるとcloseを呼ぶ）
+	showDiff := func(title string, load func() (string, error), staging *diffStaging, close func()) {
+		diffViewer.SetCloseFunc(close)
+		diffViewer.Load(title, load, staging)
+		pages.SwitchToPage("diff")
+		app.SetFocus(diffViewer)
+	}
+
+	// コミットの差分を表示する関数
+	openDiff := func(hash string) {
+		showDiff(fmt.Sprintf("Diff %s", hash[:7]), func() (string, error) { return repo.CommitDiff(hash) }, nil, func() {
+			pages.SwitchToPage("main")
+			app.SetFocus(textView)
+		})
+	}
+
+	// 未コミットの変更の一覧を開く
+	openStatus := func() {
+		pages.SwitchToPage("status")
+		app.SetFocus(statusViewer)
+		statusViewer.Refresh()
+	}
+
+	// 一覧を閉じてコミットリストに戻る（ステージングなどをしたので未コミットの変更の行も更新する）
+	statusViewer.SetCloseFunc(func() {
+		pages.SwitchToPage("main")
+		app.SetFocus(textView)
+		refreshUncommitted()
+	})
+
+	// 一覧で選択したファイルの差分を表示する
+	// 差分の中で選んだ行やハンクをステージングでき、閉じると一覧に戻って読み込み直す
+	statusViewer.SetDiffFunc(func(entry statusEntry) {
+		staged := entry.Section == sectionStaged
+		title := fmt.Sprintf("%s (%s)", entry.File.DisplayPath(), strings.ToLower(entry.Section.String()))
+		staging := &diffStaging{staged: staged, apply: repo.ApplyPatch}
+		if entry.File.Conflicted {
+			// 衝突しているファイルの差分は適用できない形式なので表示だけにする
+			staging = nil
 		}
-		branchCacheLock.Unlock()
-	}()
-}
+		showDiff(title, func() (string, error) { return repo.FileDiff(entry.File, staged) }, staging, func() {
+			pages.SwitchToPage("status")
+			app.SetFocus(statusViewer)
+			statusViewer.Refresh()
+		})
+	})
 
-// 特定のコミットのブランチ情報を非同期で取得
-func loadBranchInfoAsync(commit *Commit) {
-	if (commit.BranchLoaded && commit.BranchesLoaded) || commit.IsUncommitted {
-		return
+	// コミットメッセージの入力欄を開く（閉じるとcloseを呼ぶ）
+	openComposer := func(close func()) {
+		composer.SetCloseFunc(close)
+		composer.Open()
+		pages.SwitchToPage("commit")
+		app.SetFocus(composer)
 	}
 
-	go func(c *Commit) {
-		// 単一ブランチ情報のロード
-		if !c.BranchLoaded {
-			// キャッシュをチェック
-			branchCacheLock.RLock()
-			branch, exists := branchCache[c.Hash]
-			branchCacheLock.RUnlock()
+	// コミットしたらコミットリストに戻り、新しいコミットを選択する
+	composer.SetCommitFunc(func(output, head string) {
+		pages.SwitchToPage("main")
+		app.SetFocus(textView)
+		// フックの出力の後にある"[branch hash] subject"の行を表示する
+		statusMessage = tview.Escape(commitLine(output, "Committed"))
+		reloadAndSelect(head)
+		displayCommits()
+	})
 
-			if exists {
-				c.Branch = branch
-				c.BranchLoaded = true
-			} else {
-				// キャッシュになければ取得
-				branch = getCommitBranch(c.Hash)
-				c.Branch = branch
-				c.BranchLoaded = true
-			}
-		}
-
-		// ブランチリストの情報をロード
-		if !c.BranchesLoaded {
-			// そのコミットに関連する全てのブランチを取得
-			allBranches := getCommitBranches(c.Hash)
-			c.Branches = allBranches
-			
-			// このコミットを指しているブランチ（HEADブランチ）を特定
-			var headBranches []string
-			
-			for _, branch := range allBranches {
-				// 各ブランチについて、そのブランチの先頭コミットかどうか確認
-				branchHash, err := getBranchCommitHash(branch)
-				if err == nil && branchHash == c.Hash {
-					// ブランチの先頭コミットの場合のみ表示に追加
-					headBranches = append(headBranches, branch)
-				}
-			}
-			
-			c.HeadBranches = headBranches
-			c.BranchesLoaded = true
-		}
-	}(commit)
-}
+	// 一覧からコミットメッセージの入力欄を開き、閉じると一覧に戻る
+	statusViewer.SetCommitFunc(func() {
+		openComposer(func() {
+			pages.SwitchToPage("status")
+			app.SetFocus(statusViewer)
+			statusViewer.Refresh()
+		})
+	})
+
+	// メッセージの入力をやめたらコミットリストに戻る
+	messageEditor.SetCloseFunc(func() {
+		pages.SwitchToPage("main")
+		app.SetFocus(textView)
+		displayCommits()
+	})
+
+	rebaseViewer.SetCloseFunc(func() {
+		pages.SwitchToPage("main")
+		app.SetFocus(textView)
+	})
+
+	// リベースしたらコミットリストに戻り、新しいHEADを選択する
+	// editの手順で止まった場合はその旨を表示する
+	rebaseViewer.SetRebaseFunc(func(output string, err error) {
+		pages.SwitchToPage("main")
+		app.SetFocus(textView)
+		if err != nil {
+			statusMessage = fmt.Sprintf("[red]Rebase failed: %s[-]", tview.Escape(formatMessage(err.Error())))
+		} else {
+			statusMessage = tview.Escape(lastOutputLine(output))
+			for _, line := range strings.Split(output, "\n") {
+				if strings.HasPrefix(line, "Stopped at") {
+					statusMessage = tview.Escape(line + "; amend it and run git rebase --continue")
+					break
+				}
+			}
+		}
+		if head, err := repo.HeadCommitHash(); err == nil {
+			reloadAndSelect(head)
+		} else {
+			reloadCommits()
+		}
+		displayCommits()
+	})
 
-// コミットをチェックアウトする - switchとcheckoutを適切に使い分ける
-func checkoutCommit(commit Commit) (string, error) {
-	// ブランチ名が存在する場合、ブランチのHEADとコミットハッシュを比較
-	if commit.Branch != "" {
-		branchHash, err := getBranchCommitHash(commit.Branch)
-		if err == nil && branchHash == commit.Hash {
-			// ブランチのHEADとコミットハッシュが一致する場合はswitchを使用
-			cmd := exec.Command("git", "switch", commit.Branch)
-			output, err := cmd.CombinedOutput()
-			return string(output), err
+	// コミットを選択する（読み込んでいなければ見つかるまで読み込み直す）
+	// 絞り込みで表示されないコミットなら絞り込みを解除する
+	jumpToCommit := func(hash string) {
+		for i, commit := range commits {
+			if commit.Hash == hash {
+				currentCommit = i
+				return
+			}
+		}
+		if !filter.IsEmpty() {
+			statusMessage = "Filter cleared to show the commit"
+			startReload(LogFilter{}, true, hash)
+			return
 		}
+		reloadAndSelect(hash)
 	}
 
-	// ブランチが存在しない場合、または一致しない場合はcheckoutでハッシュを指定
-	cmd := exec.Command("git", "checkout", commit.Hash)
-	output, err := cmd.CombinedOutput()
-	return string(output), err
-}
+	// ブランチの一覧を開く
+	openBranches := func() {
+		pages.SwitchToPage("branches")
+		app.SetFocus(branchViewer)
+		branchViewer.Refresh()
+	}
 
-// コミット情報をリフレッシュする関数（ブランチ切り替え後に呼び出す）
-func refreshCommitInfo(commits []Commit) {
-	// ブランチ情報をクリアして再取得するように設定
-	branchCacheLock.Lock()
-	for k := range branchCache {
-		delete(branchCache, k)
+	branchViewer.SetCloseFunc(func() {
+		pages.SwitchToPage("main")
+		app.SetFocus(textView)
+	})
+
+	// 一覧で選んだブランチの先頭のコミットに移動する
+	branchViewer.SetSelectFunc(func(branch BranchInfo) {
+		pages.SwitchToPage("main")
+		app.SetFocus(textView)
+		jumpToCommit(branch.Hash)
+		displayCommits()
+	})
+
+	// スタッシュの一覧を開く
+	openStashes := func() {
+		pages.SwitchToPage("stash")
+		app.SetFocus(stashViewer)
+		stashViewer.Refresh()
 	}
-	branchCacheLock.Unlock()
 
-	// コミットのブランチ情報をリセット
-	for i := range commits {
-		if !commits[i].IsUncommitted {
-			commits[i].Branch = ""
-			commits[i].BranchLoaded = false
-			commits[i].Branches = nil
-			commits[i].HeadBranches = nil
-			commits[i].BranchesLoaded = false
+	// 一覧を閉じてコミットリストに戻る（適用や削除をしたので読み込み直す）
+	stashViewer.SetCloseFunc(func() {
+		pages.SwitchToPage("main")
+		app.SetFocus(textView)
+		reloadCommits()
+		displayCommits()
+	})
+
+	// 一覧でスタッシュを適用するときに、マージやリベースなどの途中かどうかを調べる
+	stashViewer.SetOperationFunc(func() Operation { return operation })
+
+	// 一覧で選択したスタッシュの差分を表示し、閉じると一覧に戻る
+	stashViewer.SetDiffFunc(func(stash Stash) {
+		showDiff(fmt.Sprintf("%s: %s", stash.Name, stash.Message), func() (string, error) { return repo.CommitDiff(stash.Hash) }, nil, func() {
+			pages.SwitchToPage("stash")
+			app.SetFocus(stashViewer)
+		})
+	})
+
+	// ブランチやタグの操作を行い、結果を表示してrefを読み込み直す（成功したかどうかを返す）
+	runRefAction := func(failure, success string, action func() error) bool {
+		if err := action(); err != nil {
+			statusMessage = fmt.Sprintf("[red]%s: %s[-]", failure, tview.Escape(formatMessage(err.Error())))
+			return false
 		}
+		statusMessage = tview.Escape(success)
+		refreshRefs()
+		return true
 	}
 
-	// ブランチ情報を非同期で再取得
-	getBranchesForCommits(commits)
+	// 未コミットの変更をスタッシュする（メッセージを入力してから、追跡していないファイルも含めるか選ぶ）
+	stashChanges := func() {
+		actionPrompt = newInputPrompt("Stash message: ", "", func(message string) {
+			message = strings.TrimSpace(message)
+			choices := []string{"Tracked files", "Include untracked"}
+			actionPrompt = newChoicePrompt("Stash:", choices, func(i int) {
+				runRefAction("Stash failed", "Stashed the uncommitted changes", func() error {
+					_, err := repo.StashChanges(message, i == 1)
+					return err
+				})
+				refreshUncommitted()
+			}).SetHint("←→: select  Enter: confirm  Esc: cancel")
+		}).SetHint("Empty for the default message (WIP on <branch>: <HEAD>)")
+	}
 
-	// HEADの位置も更新
-	headHash, err := getHeadCommitHash()
-	if err == nil {
-		for i := range commits {
-			if !commits[i].IsUncommitted {
-				commits[i].IsHead = (commits[i].Hash == headHash)
+	// コミットを指しているローカルブランチを選んでから操作する（1つだけなら選ばずに操作する）
+	withBranchAt := func(hash, action string, then func(branch Ref)) {
+		var branches []Ref
+		var names []string
+		for _, ref := range refIndex.RefsAt(hash) {
+			if ref.Kind == RefLocalBranch {
+				branches = append(branches, ref)
+				names = append(names, ref.Name)
 			}
 		}
+		switch len(branches) {
+		case 0:
+			statusMessage = "[yellow]No local branch points to this commit[-]"
+		case 1:
+			then(branches[0])
+		default:
+			actionPrompt = newChoicePrompt(fmt.Sprintf("%s branch:", action), names, func(i int) {
+				then(branches[i])
+			}).SetHint("←→: select  Enter: confirm  Esc: cancel")
+		}
 	}
-}
 
-// Gitコミットログを取得
-func getGitCommits() ([]Commit, error) {
-	// 現在のHEADのハッシュを取得
-	headHash, err := getHeadCommitHash()
-	if err != nil {
-		// エラーがあってもプロセスは続行（HEADのハイライトができないだけ）
-		headHash = ""
+	// コミットにブランチを作成する（作成したブランチに切り替えることもできる）
+	createBranch := func(hash string) {
+		actionPrompt = newInputPrompt(fmt.Sprintf("New branch at %s: ", hash[:7]), "", func(name string) {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return
+			}
+			// マージやリベースの途中では切り替えられない
+			choices := []string{"Create"}
+			if operation.Kind == operationNone {
+				choices = append(choices, "Create and switch")
+			}
+			actionPrompt = newChoicePrompt(fmt.Sprintf("Branch '%s':", name), choices, func(choice int) {
+				created := runRefAction("Create branch failed", fmt.Sprintf("Created branch '%s' at %s", name, hash[:7]), func() error {
+					return repo.CreateBranch(name, hash)
+				})
+				if created && choice == 1 {
+					runRefAction(fmt.Sprintf("Created branch '%s' but checkout failed", name), fmt.Sprintf("Switched to a new branch '%s'", name), func() error {
+						_, err := repo.SwitchBranch(name)
+						return err
+					})
+				}
+			}).SetHint("←→: select  Enter: confirm  Esc: cancel")
+		})
 	}
 
-	// 日時をGitの標準形式で取得
-	cmd := exec.Command("git", "log", "--all", "--pretty=format:%H|%an|%ad|%s")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, err
+	// ブランチの名前を変える
+	renameBranch := func(branch Ref) {
+		actionPrompt = newInputPrompt(fmt.Sprintf("Rename '%s' to: ", branch.Name), branch.Name, func(name string) {
+			name = strings.TrimSpace(name)
+			if name == "" || name == branch.Name {
+				return
+			}
+			runRefAction("Rename branch failed", fmt.Sprintf("Renamed branch '%s' to '%s'", branch.Name, name), func() error {
+				return repo.RenameBranch(branch.Name, name)
+			})
+		})
+	}
+
+	// ブランチを削除する（マージされていなければ強制的に削除するか確認する）
+	deleteBranch := func(branch Ref) {
+		actionPrompt = newConfirmPrompt(fmt.Sprintf("Delete branch '%s'?", branch.Name), func() {
+			err := repo.DeleteBranch(branch.Name, false)
+			if errors.Is(err, errBranchNotMerged) {
+				actionPrompt = newConfirmPrompt(fmt.Sprintf("Branch '%s' is not fully merged into HEAD. Force delete?", branch.Name), func() {
+					runRefAction("Delete branch failed", fmt.Sprintf("Deleted branch '%s' (was %s)", branch.Name, branch.Hash[:7]), func() error {
+						return repo.DeleteBranch(branch.Name, true)
+					})
+				}).SetHint("Commits reachable only from this branch will be lost")
+				return
+			}
+			runRefAction("Delete branch failed", fmt.Sprintf("Deleted branch '%s' (was %s)", branch.Name, branch.Hash[:7]), func() error {
+				return err
+			})
+		})
+	}
+
+	// ブランチの上流を設定する（空にすると設定を外す）
+	setUpstream := func(branch Ref) {
+		actionPrompt = newInputPrompt(fmt.Sprintf("Upstream of '%s': ", branch.Name), branch.Upstream, func(upstream string) {
+			upstream = strings.TrimSpace(upstream)
+			switch {
+			case upstream == branch.Upstream:
+				return
+			case upstream == "":
+				runRefAction("Unset upstream failed", fmt.Sprintf("Branch '%s' no longer has an upstream", branch.Name), func() error {
+					return repo.SetUpstream(branch.Name, "")
+				})
+			default:
+				runRefAction("Set upstream failed", fmt.Sprintf("Branch '%s' now tracks '%s'", branch.Name, upstream), func() error {
+					return repo.SetUpstream(branch.Name, upstream)
+				})
+			}
+		}).SetHint("e.g. origin/main or a local branch (empty to unset)")
 	}
 
-	var commits []Commit
-	lines := strings.Split(string(output), "\n")
-	for _, line := range lines {
-		parts := strings.SplitN(line, "|", 4)
-		if len(parts) == 4 {
-			hash := parts[0]
-			commit := Commit{
-				Hash:          hash,
-				Author:        parts[1],
-				Date:          formatDate(parts[2]),
-				Message:       formatMessage(parts[3]),
-				IsUncommitted: false,
-				BranchLoaded:  false,            // 初期状態では未ロード
-				IsHead:        hash == headHash, // HEADかどうかをチェック
+	// コミットにタグを作成する（annotatedならメッセージも入力する）
+	createTag := func(hash string, annotated bool) {
+		actionPrompt = newInputPrompt(fmt.Sprintf("New tag at %s: ", hash[:7]), "", func(name string) {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return
+			}
+			if !annotated {
+				runRefAction("Create tag failed", fmt.Sprintf("Created tag '%s' at %s", name, hash[:7]), func() error {
+					return repo.CreateTag(name, hash, "")
+				})
+				return
+			}
+			actionPrompt = newInputPrompt(fmt.Sprintf("Message for '%s': ", name), "", func(message string) {
+				if strings.TrimSpace(message) == "" {
+					statusMessage = "[yellow]An annotated tag needs a message[-]"
+					return
+				}
+				runRefAction("Create tag failed", fmt.Sprintf("Created annotated tag '%s' at %s", name, hash[:7]), func() error {
+					return repo.CreateTag(name, hash, message)
+				})
+			})
+		})
+	}
+
+	// タグを削除する
+	deleteTag := func(name string) {
+		actionPrompt = newConfirmPrompt(fmt.Sprintf("Delete tag '%s'?", name), func() {
+			runRefAction("Delete tag failed", fmt.Sprintf("Deleted tag '%s'", name), func() error {
+				return repo.DeleteTag(name)
+			})
+		})
+	}
+
+	// 選択中のコミットでタグの操作を選ぶ
+	openTagMenu := func(hash string) {
+		tags := refIndex.NamesAt(hash, RefTag)
+		actions := []string{"Create", "Create annotated"}
+		if len(tags) > 0 {
+			actions = append(actions, "Delete")
+		}
+		actionPrompt = newChoicePrompt(fmt.Sprintf("Tag at %s:", hash[:7]), actions, func(i int) {
+			switch actions[i] {
+			case "Create":
+				createTag(hash, false)
+			case "Create annotated":
+				createTag(hash, true)
+			case "Delete":
+				if len(tags) == 1 {
+					deleteTag(tags[0])
+					return
+				}
+				actionPrompt = newChoicePrompt("Delete tag:", tags, func(i int) {
+					deleteTag(tags[i])
+				}).SetHint("←→: select  Enter: confirm  Esc: cancel")
 			}
+		}).SetHint("←→: select  Enter: confirm  Esc: cancel")
+	}
 
-			commits = append(commits, commit)
+	// fetch、pull、pushを別のゴルーチンで実行し、進捗をステータス領域に表示する
+	// 終わったら結果を表示し、コミットログとrefを読み込み直す
+	runRemote := func(name string, run func(progress func(line string)) (string, error), success func(output string) string) {
+		if remoteOperation != "" {
+			statusMessage = fmt.Sprintf("[yellow]%s is still running[-]", remoteOperation)
+			return
 		}
+		remoteOperation, remoteProgress = name, ""
+		go func() {
+			// 進捗は細かく届くので、画面の更新は間引く
+			var lastUpdate time.Time
+			progress := func(line string) {
+				if time.Since(lastUpdate) < 100*time.Millisecond {
+					return
+				}
+				lastUpdate = time.Now()
+				app.QueueUpdateDraw(func() {
+					if remoteOperation == name {
+						remoteProgress = line
+						displayCommits()
+					}
+				})
+			}
+			output, err := run(progress)
+			app.QueueUpdateDraw(func() {
+				remoteOperation, remoteProgress = "", ""
+				if err != nil {
+					statusMessage = fmt.Sprintf("[red]%s failed: %s[-]", name, tview.Escape(formatMessage(err.Error())))
+				} else {
+					statusMessage = tview.Escape(success(output))
+				}
+				reloadCommits()
+				displayCommits()
+			})
+		}()
 	}
 
-	// 未コミットの変更がある場合、先頭に追加
-	if hasUncommittedChanges() {
-		// 現在のユーザー名を取得
-		userCmd := exec.Command("git", "config", "user.name")
-		userName, _ := userCmd.Output()
+	// すべてのリモートからfetchする
+	fetchRemotes := func() {
+		runRemote("Fetch", repo.Fetch, func(string) string {
+			return "Fetched all remotes"
+		})
+	}
 
-		// 変更の概要を取得
-		changesSummary, err := getUncommittedChangesSummary()
-		if err != nil {
-			changesSummary = "uncommitted changes"
+	// 現在のブランチに上流の変更を取り込む
+	pullBranch := func() {
+		runRemote("Pull", func(progress func(line string)) (string, error) {
+			return repo.Pull(pullMode, progress)
+		}, func(output string) string {
+			return fmt.Sprintf("Pulled (%s): %s", pullMode, lastOutputLine(output))
+		})
+	}
+
+	// 現在のブランチをpushする（force with leaseも選べる）
+	pushBranch := func() {
+		choices := []string{"Push", "Force with lease"}
+		actionPrompt = newChoicePrompt(fmt.Sprintf("Push '%s':", refIndex.HeadBranch), choices, func(i int) {
+			force := i == 1
+			runRemote("Push", func(progress func(line string)) (string, error) {
+				return repo.Push(force, progress)
+			}, func(output string) string {
+				return "Pushed: " + lastOutputLine(output)
+			})
+		}).SetHint("←→: select  Enter: confirm  Esc: cancel")
+	}
+
+	// HEADを動かす操作を別のゴルーチンで行い、終わったら結果を表示してコミットログを読み込み直し、新しいHEADを選択する
+	// フックを実行するので時間がかかることがあり、実行中はステータス領域にその旨を表示する
+	// 失敗しても競合などで作業ツリーが変わっていることがあるので読み込み直す
+	// finishedがnilでなければ、結果を表示した後に操作のエラーを渡して呼ぶ
+	runHeadAction := func(name string, action func() (string, error), success func(output string) string, finished func(err error)) {
+		if headAction != "" {
+			statusMessage = fmt.Sprintf("[yellow]%s is still running[-]", headAction)
+			return
 		}
+		headAction, statusMessage = name, ""
+		go func() {
+			output, err := action()
+			head := ""
+			if err == nil {
+				head, _ = repo.HeadCommitHash()
+			}
+			app.QueueUpdateDraw(func() {
+				headAction = ""
+				switch {
+				case err != nil:
+					statusMessage = fmt.Sprintf("[red]%s failed: %s[-]", name, tview.Escape(formatMessage(err.Error())))
+					reloadCommits()
+				case head != "":
+					statusMessage = tview.Escape(success(output))
+					reloadAndSelect(head)
+				default:
+					statusMessage = tview.Escape(success(output))
+					reloadCommits()
+				}
+				if finished != nil {
+					finished(err)
+				}
+				displayCommits()
+			})
+		}()
+	}
 
-		// 現在の日時
-		now := time.Now().Format("2006-01-02 15:04:05")
+	// 操作の対象になる現在のブランチの名前（detached HEADならHEAD）
+	headName := func() string {
+		if refIndex.HeadBranch == "" {
+			return "HEAD"
+		}
+		return refIndex.HeadBranch
+	}
 
-		// 未コミット変更を表すダミーコミットを作成
-		uncommitted := Commit{
-			Hash:          "--------",
-			Author:        strings.TrimSpace(string(userName)),
-			Date:          now,
-			Message:       "Uncommitted Changes: " + changesSummary,
-			IsUncommitted: true,
+	// 途中で止まっている操作を続ける、中止する、または今のコミットを飛ばす
+	// 衝突が残っていれば続けず、中止と飛ばすのは取り消せないので確認する
+	openOperationMenu := func() {
+		kind := operation.Kind
+		actions := kind.Actions()
+		var choices []string
+		for _, action := range actions {
+			choices = append(choices, strings.ToUpper(action.String()[:1])+action.String()[1:])
+		}
+		finish := func(action OperationAction) {
+			done := map[OperationAction]string{operationContinue: "continued", operationAbort: "aborted", operationSkip: "skipped"}
+			runHeadAction(fmt.Sprintf("%s --%s", kind, action), func() (string, error) {
+				return repo.FinishOperation(kind, action)
+			}, func(output string) string {
+				if strings.TrimSpace(output) == "" {
+					return fmt.Sprintf("The %s was %s", kind, done[action])
+				}
+				return lastOutputLine(output)
+			}, nil)
 		}
+		actionPrompt = newChoicePrompt(operation.Banner()+":", choices, func(i int) {
+			switch action := actions[i]; {
+			case action == operationContinue && len(operation.Conflicts) > 0:
+				statusMessage = "[yellow]Resolve and stage the conflicted files first (Enter on the uncommitted row)[-]"
+			case action == operationContinue:
+				finish(action)
+			case action == operationAbort:
+				actionPrompt = newConfirmPrompt(fmt.Sprintf("Abort the %s and return to the state before it?", kind), func() { finish(action) })
+			default:
+				actionPrompt = newConfirmPrompt(fmt.Sprintf("Skip the current commit of the %s?", kind), func() { finish(action) })
+			}
+		}).SetHint("←→: select  Enter: confirm  Esc: cancel")
+	}
 
-		// 先頭に追加
-		commits = append([]Commit{uncommitted}, commits...)
+	// 選択中のコミットの変更を現在のHEADに適用する
+	cherryPick := func(hash string) {
+		actionPrompt = newConfirmPrompt(fmt.Sprintf("Cherry-pick %s onto '%s'?", hash[:7], headName()), func() {
+			runHeadAction("Cherry-pick", func() (string, error) { return repo.CherryPick(hash) }, func(output string) string {
+				return commitLine(output, "Cherry-picked "+hash[:7])
+			}, nil)
+		})
 	}
 
-	// 起動時の処理負荷を減らすため、ブランチ情報は後で非同期に読み込む
-	getBranchesForCommits(commits)
+	// 選択中のコミットを打ち消すコミットを作成する
+	revertCommit := func(hash string) {
+		actionPrompt = newConfirmPrompt(fmt.Sprintf("Revert %s on '%s'?", hash[:7], headName()), func() {
+			runHeadAction("Revert", func() (string, error) { return repo.Revert(hash) }, func(output string) string {
+				return commitLine(output, "Reverted "+hash[:7])
+			}, nil)
+		})
+	}
 
-	return commits, nil
-}
+	// 現在のブランチを選択中のコミットに移動する（soft、mixed、hardを選んでから確認する）
+	resetTo := func(hash string) {
+		modes := []ResetMode{resetSoft, resetMixed, resetHard}
+		choices := []string{"Soft", "Mixed", "Hard"}
+		actionPrompt = newChoicePrompt(fmt.Sprintf("Reset '%s' to %s:", headName(), hash[:7]), choices, func(i int) {
+			mode := modes[i]
+			confirm := func(discards bool) {
+				actionPrompt = newConfirmPrompt(fmt.Sprintf("Reset '%s' to %s (%s)?", headName(), hash[:7], mode), func() {
+					runHeadAction("Reset", func() (string, error) { return repo.Reset(hash, mode) }, func(string) string {
+						return fmt.Sprintf("Reset '%s' to %s (%s)", headName(), hash[:7], mode)
+					}, nil)
+				})
+				// 作業ツリーの変更は取り戻せないので強く警告する
+				if discards {
+					actionPrompt.SetWarning("WARNING: all uncommitted changes will be discarded and cannot be recovered")
+				}
+			}
+			if mode != resetHard {
+				confirm(false)
+				return
+			}
+			// 変更があるかどうかは別のゴルーチンで調べてから確認する
+			go func() {
+				discards := repo.HasUncommittedChanges()
+				app.QueueUpdateDraw(func() {
+					confirm(discards)
+					displayCommits()
+				})
+			}()
+		}).SetHint("Soft: keep index and working tree  Mixed: keep working tree  Hard: discard all changes  Esc: cancel")
+	}
 
-func main() {
-	// Gitリポジトリの存在確認
-	if !checkGitRepository() {
-		fmt.Println("エラー: カレントディレクトリにGitリポジトリが存在しません。")
-		os.Exit(1)
+	// 選択中のコミットをベースにして、その後のコミットのリベースの手順を編集する
+	// 手順は読み込んだコミットの一覧からHEADの最初の親をたどって作る
+	openRebase := func(base string) {
+		if !filter.IsEmpty() {
+			// 絞り込んだ一覧では親が書き換えられていることがある
+			statusMessage = "[yellow]Clear the filter to plan a rebase[-]"
+			return
+		}
+		head, err := repo.HeadCommitHash()
+		if err != nil {
+			statusMessage = fmt.Sprintf("[red]Cannot rebase: %s[-]", tview.Escape(formatMessage(err.Error())))
+			return
+		}
+		steps, err := planRebase(commits, head, base)
+		if err != nil {
+			statusMessage = fmt.Sprintf("[red]Cannot rebase: %s[-]", tview.Escape(err.Error()))
+			return
+		}
+		rebaseViewer.Open(base, steps)
+		pages.SwitchToPage("rebase")
+		app.SetFocus(rebaseViewer)
 	}
 
-	// Gitコミットログを取得
-	commits, err := getGitCommits()
-	if err != nil {
-		fmt.Printf("エラー: Gitコミットログの取得に失敗しました: %v\n", err)
-		os.Exit(1)
+	// 確認したコミットまたはブランチをチェックアウトする
+	// 確認中にリストが読み込み直されても、確認したコミットをチェックアウトする
+	checkoutConfirmed := func() (string, error) {
+		if isDetachedHeadMode {
+			// detached headモードの場合はハッシュを直接チェックアウト
+			return repo.CheckoutDetached(checkoutHash)
+		} else if selectedBranch := availableBranches[currentBranchIndex]; selectedBranch.Kind == RefRemoteBranch {
+			// リモートブランチの場合は追跡するローカルブランチを作成して切り替える
+			return repo.SwitchTrackingBranch(trackingBranchName(selectedBranch.Name), selectedBranch.Name)
+		} else {
+			// ブランチモードの場合は選択したブランチをチェックアウト
+			return repo.SwitchBranch(selectedBranch.Name)
+		}
 	}
 
-	app := tview.NewApplication()
+	// チェックアウトに成功したときにステータス領域に表示するメッセージ
+	checkoutSucceeded := func(output string) string {
+		// 成功時は短くメッセージを表示
+		shortMsg := "Checkout successful"
+		if len(output) > 0 {
+			shortMsg = formatMessage(strings.TrimSpace(output))
+			if len(shortMsg) > 60 { // 長すぎる場合は切り詰め
+				shortMsg = shortMsg[:60] + "..."
+			}
+		}
 
-	// コミットログ表示用のTextViewを使用して、より細かい制御を可能にする
-	textView := tview.NewTextView().
-		SetDynamicColors(true).
-		SetRegions(true).
-		SetScrollable(true)
+		if isDetachedHeadMode {
+			return tview.Escape(fmt.Sprintf("Checkout successful (detached HEAD): %s", shortMsg))
+		} else if branch := availableBranches[currentBranchIndex]; branch.Kind == RefRemoteBranch {
+			return tview.Escape(fmt.Sprintf("Switched to a new branch '%s' tracking '%s'", trackingBranchName(branch.Name), branch.Name))
+		}
+		return tview.Escape(fmt.Sprintf("Switched to branch '%s': %s", availableBranches[currentBranchIndex].Name, shortMsg))
+	}
 
-	// ステータス表示用の領域
-	statusArea := tview.NewTextView().
-		SetDynamicColors(true).
-		SetTextAlign(tview.AlignLeft)
+	// ローカルの変更を上書きするためにチェックアウトできなかったときに、変更をスタッシュしてからチェックアウトするか確認する
+	// 追跡していないファイルが上書きされる場合は、そのファイルもスタッシュする
+	offerStashAndCheckout := func(checkoutErr error) {
+		untracked := strings.Contains(checkoutErr.Error(), "untracked working tree files")
+		actionPrompt = newConfirmPrompt("Local changes would be overwritten by checkout. Stash them and switch?", func() {
+			if _, err := repo.StashChanges("", untracked); err != nil {
+				statusMessage = fmt.Sprintf("[red]Stash failed: %s[-]", tview.Escape(formatMessage(err.Error())))
+				return
+			}
+			output, err := checkoutConfirmed()
+			if err != nil {
+				statusMessage = fmt.Sprintf("[red]Stashed the local changes but checkout failed: %s[-]", tview.Escape(formatMessage(err.Error())))
+			} else {
+				statusMessage = "Stashed the local changes (stash@{0}). " + checkoutSucceeded(output)
+			}
+			refreshRefs()
+			refreshUncommitted()
+		}).SetHint("Apply them again later from the stash list (S)")
+	}
 
-	// レイアウト設定 - FlexでTextViewの下に2行の余白を作成
-	flex := tview.NewFlex().
-		SetDirection(tview.FlexRow).
-		AddItem(textView, 0, 1, true).   // テキストビューが伸縮するように比率を設定
-		AddItem(statusArea, 2, 0, false) // 下部に高さ2行の固定領域
+	// sourceを現在のHEADにマージする
+	// 競合で止まったら衝突したファイルを表示して、中止するか確認する
+	runMerge := func(source Ref, mode MergeMode, message string) {
+		label := source.Name
+		if label == "" {
+			label = source.Hash[:7]
+		}
+		// 競合で止まったときは、衝突したファイルも操作と同じゴルーチンで調べる
+		var conflicts []string
+		runHeadAction("Merge", func() (string, error) {
+			output, err := repo.Merge(mergeRevision(source), mode, message)
+			if err != nil {
+				if files, statusErr := repo.Status(); statusErr == nil {
+					for _, file := range files {
+						if file.Conflicted {
+							conflicts = append(conflicts, file.Path)
+						}
+					}
+				}
+			}
+			return output, err
+		}, func(output string) string {
+			switch mode {
+			case mergeFastForward:
+				return fmt.Sprintf("Fast-forwarded '%s' to %s", headName(), label)
+			case mergeSquash:
+				return commitLine(output, "Squashed "+label)
+			}
+			return fmt.Sprintf("Merged %s into '%s'", label, headName())
+		}, func(error) {
+			if len(conflicts) == 0 {
+				return
+			}
+			// squashではマージの途中にならないので、解決したらそのままコミットする
+			hint := "n: resolve and stage the files, then press o to continue the merge"
+			if mode == mergeSquash {
+				hint = "n: resolve and stage the files, then press c to commit them"
+			}
+			actionPrompt = newConfirmPrompt(fmt.Sprintf("Merge of %s stopped with %s. Abort it?", label, describeConflicts(conflicts)), func() {
+				runHeadAction("Abort", func() (string, error) {
+					return repo.FinishOperation(operationMerge, operationAbort)
+				}, func(string) string {
+					return "The merge was aborted"
+				}, nil)
+			}).SetHint(hint)
+		})
+	}
 
-	// 現在選択されているコミットのインデックス
-	currentCommit := 0
+	// マージする方法を選んでからsourceを現在のHEADにマージする
+	// 取り込まれるコミットの数を表示し、マージコミットを作る場合はメッセージを複数行で編集できるようにする
+	// コミットの数は履歴が長いと数えるのに時間がかかるので、別のゴルーチンで数えてから問い合わせる
+	mergeFrom := func(source Ref) {
+		label := source.Name
+		if label == "" {
+			label = source.Hash[:7]
+		}
+		mergeGeneration++
+		generation := mergeGeneration
+		statusMessage = tview.Escape(fmt.Sprintf("Counting the commits to merge from %s…", label))
+		go func() {
+			preview, err := repo.MergePreview(mergeRevision(source))
+			app.QueueUpdateDraw(func() {
+				// 数えている間に別の問い合わせを始めていれば、そちらを優先する
+				if generation != mergeGeneration || actionPrompt != nil {
+					return
+				}
+				statusMessage = ""
+				defer displayCommits()
+				if err != nil {
+					statusMessage = fmt.Sprintf("[red]Cannot merge: %s[-]", tview.Escape(formatMessage(err.Error())))
+					return
+				}
+				if preview.Commits == 0 {
+					statusMessage = tview.Escape(fmt.Sprintf("Already up to date with %s", label))
+					return
+				}
 
-	// スクロール位置の管理（先頭からの行オフセット）
-	scrollOffset := 0
+				// 早送りできなければff-onlyは選べない
+				var modes []MergeMode
+				var choices []string
+				hint := "No fast-forward: always create a merge commit  Squash: one commit without merging  Esc: cancel"
+				if preview.FastForward {
+					modes, choices = append(modes, mergeFastForward), append(choices, "Fast-forward only")
+					hint = "Fast-forward only: just move '" + headName() + "'  " + hint
+				} else {
+					hint = "Fast-forward is not possible  " + hint
+				}
+				modes = append(modes, mergeNoFastForward, mergeSquash)
+				choices = append(choices, "No fast-forward", "Squash")
 
-	// 確認モードとブランチ選択モードのフラグ
-	confirmMode := false
-	branchSelectMode := false
-	confirmAfterBranchSelect := false // ブランチ選択後の確認モードフラグ
+				count := fmt.Sprintf("%d commits", preview.Commits)
+				if preview.Commits == 1 {
+					count = "1 commit"
+				}
+				actionPrompt = newChoicePrompt(fmt.Sprintf("Merge %s into '%s' (%s):", label, headName(), count), choices, func(i int) {
+					mode := modes[i]
+					if mode == mergeFastForward {
+						runMerge(source, mode, "")
+						return
+					}
+					title := fmt.Sprintf(" Merge %s into '%s' (%s) ", label, headName(), mode)
+					messageEditor.Open(title, mergeMessage(source, refIndex.HeadBranch, mode == mergeSquash), func(message string) {
+						pages.SwitchToPage("main")
+						app.SetFocus(textView)
+						runMerge(source, mode, message)
+						displayCommits()
+					})
+					pages.SwitchToPage("message")
+					app.SetFocus(messageEditor)
+				}).SetHint(hint)
+			})
+		}()
+	}
 
-	// チェックアウト操作の状態
-	isDetachedHeadMode := false // detached headモードかどうか
+	// 選択中のコミットを現在のHEADにマージする
+	// コミットを指しているブランチがあれば、チェックアウトと同じようにマージするブランチを選ぶ
+	startMerge := func(hash string) {
+		var branches []Ref
+		for _, kind := range []RefKind{RefLocalBranch, RefRemoteBranch} {
+			for _, ref := range refIndex.RefsAt(hash) {
+				if ref.Kind == kind && !(kind == RefLocalBranch && ref.Name == refIndex.HeadBranch) {
+					branches = append(branches, ref)
+				}
+			}
+		}
+		if len(branches) == 0 {
+			mergeFrom(Ref{Hash: hash})
+			return
+		}
+		availableBranches = branches
+		currentBranchIndex = 0
+		branchSelectMode = true
+		mergeAfterBranchSelect = true
+	}
 
-	// ブランチ選択用の変数
-	var availableBranches []string
-	currentBranchIndex := 0
+	// 選択中のコミットでブランチの操作を選ぶ
+	openBranchMenu := func(hash string) {
+		actions := []string{"Create"}
+		if len(refIndex.NamesAt(hash, RefLocalBranch)) > 0 {
+			actions = append(actions, "Rename", "Delete", "Upstream")
+		}
+		actionPrompt = newChoicePrompt(fmt.Sprintf("Branch at %s:", hash[:7]), actions, func(i int) {
+			switch actions[i] {
+			case "Create":
+				createBranch(hash)
+			case "Rename":
+				withBranchAt(hash, "Rename", renameBranch)
+			case "Delete":
+				withBranchAt(hash, "Delete", deleteBranch)
+			case "Upstream":
+				withBranchAt(hash, "Set upstream of", setUpstream)
+			}
+		}).SetHint("←→: select  Enter: confirm  Esc: cancel")
+	}
 
 	// コミットを表示する関数
-	displayCommits := func() {
+	displayCommits = func() {
 		textView.Clear()
 
 		// 画面幅と高さを取得（ステータス領域の分を考慮）
-		_, _, width, height := textView.GetInnerRect()
+		_, _, _, height := textView.GetInnerRect()
 
 		// 現在の選択位置が画面に表示されていないときのみスクロール位置を調整
 		if currentCommit < scrollOffset {
@@ -457,152 +1308,236 @@ func main() {
 			scrollOffset = currentCommit - height + 1
 		}
 
-		// 表示範囲内のコミットのブランチ情報を非同期でロード（表示されているものだけ）
-		visibleStart := scrollOffset
-		visibleEnd := scrollOffset + height
-		if visibleEnd > len(commits) {
-			visibleEnd = len(commits)
-		}
-
-		// 現在選択されているコミットの情報を優先的にロード
-		if currentCommit >= 0 && currentCommit < len(commits) && !commits[currentCommit].IsUncommitted {
-			loadBranchInfoAsync(&commits[currentCommit])
-		}
-
-		// 表示範囲内のコミットのブランチ情報を非同期でロード
-		for i := visibleStart; i < visibleEnd; i++ {
-			if i >= 0 && i < len(commits) && !commits[i].IsUncommitted {
-				loadBranchInfoAsync(&commits[i])
-			}
-		}
-
 		for i, commit := range commits {
 			// 表示範囲内だけ処理
 			if i < scrollOffset || i >= scrollOffset+height {
 				continue
 			}
 
+			// 検索に一致するコミットは一致した部分を強調表示する
+			highlight := ""
+			if commitMatches(commit, refIndex, searchQuery) {
+				highlight = searchQuery
+			}
+
+			// HEADを指しているかどうか
+			isHead := !commit.IsUncommitted && commit.Hash == refIndex.Head
+
 			// 表示形式を変更: ハッシュ - 日付 - 作者 - メッセージ
-			display := fmt.Sprintf("%s - %s - %s - %s", commit.Hash[:7], commit.Date, commit.Author, commit.Message)
-			
-			// ブランチ名の表示を追加（コミットのハッシュ値とブランチが指すハッシュ値が一致する行のみ）
+			// 強調表示は検索の対象になる項目（ハッシュの先頭、作者、件名）だけに行う
+			display := fmt.Sprintf("%s - %s - %s - %s", highlightHashPrefix(commit.Hash[:7], highlight),
+				tview.Escape(commit.Date), highlightMatches(commit.Author, highlight), highlightMatches(commit.Message, highlight))
+
+			// ブランチ名とタグの表示を追加（コミットのハッシュ値とブランチが指すハッシュ値が一致する行のみ）
 			if !commit.IsUncommitted {
-				var branchesDisplay []string
-				
-				// キャッシュされたブランチ情報があれば使用し、なければ取得
-				if commit.BranchesLoaded {
-					branchesDisplay = commit.HeadBranches
-				} else {
-					// そのコミットに関連する全てのブランチを取得
-					branches := getCommitBranches(commit.Hash)
-					
-					for _, branch := range branches {
-						// 各ブランチについて、そのブランチの先頭コミットかどうか確認
-						branchHash, err := getBranchCommitHash(branch)
-						if err == nil && branchHash == commit.Hash {
-							// ブランチの先頭コミットの場合のみ表示に追加
-							branchesDisplay = append(branchesDisplay, branch)
-						}
-					}
-					
-					// 非同期でキャッシュを更新
-					if !commit.IsUncommitted {
-						loadBranchInfoAsync(&commits[i])
-					}
-				}
-				
+				branchesDisplay := refIndex.NamesAt(commit.Hash, RefLocalBranch)
+				remoteBranches := refIndex.NamesAt(commit.Hash, RefRemoteBranch)
+				tags := refIndex.NamesAt(commit.Hash, RefTag)
+
 				// ブランチ情報がある場合は表示
-				if len(branchesDisplay) > 0 || commit.IsHead {
+				if len(branchesDisplay) > 0 || len(remoteBranches) > 0 || len(tags) > 0 || isHead {
 					branchesStr := " "
-					
+
 					// HEADが指しているコミットの場合は{HEAD}を追加
-					if commit.IsHead {
+					if isHead {
 						branchesStr += " [aqua]{HEAD}[-]"
 					}
-					
+
 					// 全てのブランチを表示
 					for _, branch := range branchesDisplay {
-						branchesStr += fmt.Sprintf(" [aqua]{%s}[-]", branch)
+						branchesStr += fmt.Sprintf(" [aqua]{%s}[-]", highlightMatches(branch, highlight))
+					}
+
+					// リモート追跡ブランチはローカルブランチと見分けられるように別の色で表示
+					for _, branch := range remoteBranches {
+						branchesStr += fmt.Sprintf(" [red]{%s}[-]", highlightMatches(branch, highlight))
+					}
+
+					// タグはリリースを見分けられるように別の色で表示
+					for _, tag := range tags {
+						branchesStr += fmt.Sprintf(" [fuchsia]{tag: %s}[-]", highlightMatches(tag, highlight))
 					}
 					display += branchesStr
 				}
 			}
 
-			// 画面幅に合わせて文字列を切り捨て
-			if len(display) > width {
-				display = display[:width]
-			}
-
-			// 表示スタイルの適用
+			// 表示スタイルの適用（グラフは行の左端に表示）
 			if i == currentCommit {
 				// 現在選択されている行
 				if commit.IsUncommitted {
 					// 未コミットの変更を選択中の場合は特別な表示
-					fmt.Fprintf(textView, "[black:yellow]%s[-:-]\n", display)
+					fmt.Fprintf(textView, "[black:yellow]%s%s[-:-]\n", commit.Graph, display)
 				} else {
-					fmt.Fprintf(textView, "[black:white]%s[-:-]\n", display)
+					fmt.Fprintf(textView, "[black:white]%s%s[-:-]\n", commit.Graph, display)
 				}
-			} else if commit.IsHead {
+			} else if isHead {
 				// HEADを指しているコミットは黄色で表示
-				fmt.Fprintf(textView, "[yellow]%s[-:-]\n", display)
+				fmt.Fprintf(textView, "%s[yellow]%s[-:-]\n", colorizeGraph(commit.Graph), display)
 			} else if commit.IsUncommitted {
 				// 未コミットの変更は強調表示
-				fmt.Fprintf(textView, "[yellow]%s[-:-]\n", display)
+				fmt.Fprintf(textView, "%s[yellow]%s[-:-]\n", colorizeGraph(commit.Graph), display)
 			} else {
 				// 通常のコミット
-				fmt.Fprintf(textView, "%s\n", display)
+				fmt.Fprintf(textView, "%s%s\n", colorizeGraph(commit.Graph), display)
 			}
 		}
 
 		// 計算済みのスクロール位置に直接移動
 		textView.ScrollTo(scrollOffset, 0)
 
+		// 詳細表示を選択中のコミットに合わせる
+		showCommitDetail()
+
+		// 末尾に近づいていれば続きを読み込む
+		requestMoreCommits()
+
 		// ステータスエリアの更新
+		// マージやリベースなどの途中なら、その状態を1行目に表示する
 		statusArea.Clear()
-		if branchSelectMode && !commits[currentCommit].IsUncommitted && len(availableBranches) > 0 {
+		if operation.Kind != operationNone {
+			var actions []string
+			for _, action := range operation.Kind.Actions() {
+				actions = append(actions, action.String())
+			}
+			statusArea.Write([]byte(fmt.Sprintf("[black:yellow] %s [-:-]  [gray]o: %s[-]\n", tview.Escape(operation.Banner()), strings.Join(actions, ", "))))
+		}
+		if actionPrompt != nil {
+			// ブランチの操作などの問い合わせ中
+			statusArea.Write([]byte(actionPrompt.Render()))
+		} else if searchMode {
+			// 検索文字列の入力中
+			prompt := "/"
+			if !searchForward {
+				prompt = "?"
+			}
+			statusArea.Write([]byte(searchInput.Render(prompt)))
+		} else if filterMode {
+			// 絞り込み条件の入力中
+			statusArea.Write([]byte(filterInput.Render("Filter: ")))
+			statusArea.Write([]byte("\n[gray]author:NAME since:DATE until:DATE ref:REF path:PATH (empty to clear)[-]"))
+		} else if branchSelectMode && len(availableBranches) > 0 {
 			// ブランチ選択モード時: 利用可能なブランチを左右矢印で選択できるように表示
 			var branchDisplay string
 			for i, branch := range availableBranches {
 				if i == currentBranchIndex {
 					// 選択中のブランチは強調表示
-					branchDisplay += fmt.Sprintf("[black:white]%s[-:-] ", branch)
+					branchDisplay += fmt.Sprintf("[black:white]%s[-:-] ", tview.Escape(branch.Name))
+				} else if branch.Kind == RefRemoteBranch {
+					branchDisplay += fmt.Sprintf("[red]%s[-] ", tview.Escape(branch.Name))
 				} else {
-					branchDisplay += fmt.Sprintf("%s ", branch)
+					branchDisplay += fmt.Sprintf("%s ", tview.Escape(branch.Name))
 				}
 			}
 			// 右矢印や左矢印キーで選択することを示唆
-			statusArea.Write([]byte(fmt.Sprintf("Select branch to checkout (←→ to move, Enter to confirm): %s", branchDisplay)))
-		} else if confirmMode && !commits[currentCommit].IsUncommitted {
+			purpose := "checkout"
+			if mergeAfterBranchSelect {
+				purpose = "merge"
+			}
+			statusArea.Write([]byte(fmt.Sprintf("Select branch to %s (←→ to move, Enter to confirm): %s", purpose, branchDisplay)))
+		} else if confirmMode {
 			// 確認モード時: コミットチェックアウト確認メッセージを表示
-			commit := commits[currentCommit]
-
 			var checkoutMsg string
 			if isDetachedHeadMode {
 				// detached headになる場合
-				checkoutMsg = fmt.Sprintf("Checkout commit %s? (detached HEAD) [y/n]", commit.Hash[:7])
-			} else if len(availableBranches) > 0 && currentBranchIndex < len(availableBranches) {
-				// ブランチ選択後の確認の場合は、選択されたブランチ名を使用
-				checkoutMsg = fmt.Sprintf("Checkout branch '%s'? [y/n]", availableBranches[currentBranchIndex])
+				checkoutMsg = fmt.Sprintf("Checkout commit %s? (detached HEAD) [y/n]", checkoutHash[:7])
+			} else if branch := availableBranches[currentBranchIndex]; branch.Kind == RefRemoteBranch {
+				// リモートブランチはそれを追跡するローカルブランチを作って切り替える
+				checkoutMsg = fmt.Sprintf("Create local branch '%s' tracking '%s' and switch to it? [y/n]", tview.Escape(trackingBranchName(branch.Name)), tview.Escape(branch.Name))
 			} else {
-				// 通常のブランチにswitchする場合（この条件はほとんど使用されない）
-				checkoutMsg = fmt.Sprintf("Checkout branch '%s'? [y/n]", commit.Branch)
+				// ブランチ選択後の確認の場合は、選択されたブランチ名を使用
+				checkoutMsg = fmt.Sprintf("Checkout branch '%s'? [y/n]", tview.Escape(branch.Name))
 			}
 
 			statusArea.Write([]byte(checkoutMsg))
 		} else {
 			// 通常時: コミット総数と現在のHEADが指すブランチ名の表示
 			branchInfo := ""
-			// HEADが指すブランチ名を取得
-			branchName, isAttached := getCurrentBranchName()
-			if isAttached {
+			if refIndex.HeadBranch != "" {
 				// ブランチに紐付いている場合はブランチ名を表示
-				branchInfo = fmt.Sprintf(" (Branch: %s)", branchName)
+				branchInfo = fmt.Sprintf(" (Branch: %s)", refIndex.HeadBranch)
 			} else {
 				// detached HEAD状態の場合はその旨を表示
 				branchInfo = " (detached HEAD)"
 			}
-			statusArea.Write([]byte(fmt.Sprintf("Total commits: %d%s", len(commits), branchInfo)))
+			// 絞り込み中はその条件を表示
+			filterInfo := ""
+			if !filter.IsEmpty() {
+				filterInfo = fmt.Sprintf(" (Filter: %s)", tview.Escape(filter.String()))
+			}
+			// 読み込み中は読み込んだ数を表示
+			total := fmt.Sprintf("%d", len(commits))
+			if reloading {
+				total = fmt.Sprintf("reloading… %d", len(commits))
+			} else if !loadingDone {
+				total = fmt.Sprintf("loading… %d", len(commits))
+			}
+			statusArea.Write([]byte(fmt.Sprintf("Total commits: %s%s%s", total, branchInfo, filterInfo)))
+
+			// 2行目には操作の結果、なければ実行中のfetchなどの進捗や検索の状態を表示
+			if statusMessage != "" {
+				statusArea.Write([]byte("\n" + statusMessage))
+			} else if headAction != "" {
+				statusArea.Write([]byte(fmt.Sprintf("\n[yellow]%s…[-]", headAction)))
+			} else if remoteOperation != "" {
+				statusArea.Write([]byte(fmt.Sprintf("\n[yellow]%s…[-] %s", remoteOperation, tview.Escape(remoteProgress))))
+			} else if pendingSearch != nil {
+				statusArea.Write([]byte(fmt.Sprintf("\nSearching: %s (loading more commits…)", tview.Escape(pendingSearch.query))))
+			} else if searchQuery != "" {
+				// 最後まで読み込んでいなければ、読み込んだ範囲での結果であることを示す
+				scope := ""
+				if !loadingDone {
+					scope = " in loaded commits"
+				}
+				if count := countMatches(commits, refIndex, searchQuery); count > 0 {
+					statusArea.Write([]byte(fmt.Sprintf("\nSearch: %s (%d matches%s, n/N to move)", tview.Escape(searchQuery), count, scope)))
+				} else {
+					statusArea.Write([]byte(fmt.Sprintf("\n[red]Pattern not found%s: %s[-]", scope, tview.Escape(searchQuery))))
+				}
+			}
+		}
+	}
+
+	// 次のページを非同期で読み込む
+	loadNextPage = func() {
+		if loadingDone || loadingPage {
+			return
+		}
+
+		loadingPage = true
+		generation := loadGeneration
+		currentLoader := loader
+		go func() {
+			page, done, err := currentLoader.Next()
+			app.QueueUpdateDraw(func() {
+				if generation != loadGeneration {
+					// 読み込み直した後なら古い読み込みは中止する
+					currentLoader.Close()
+					return
+				}
+				loadingPage = false
+				if err != nil {
+					loadingDone = true
+					statusMessage = fmt.Sprintf("[red]Failed to load commits: %s[-]", tview.Escape(formatMessage(err.Error())))
+				} else {
+					commits = append(commits, page...)
+					loadingDone = done
+				}
+				if pendingSearch != nil {
+					// 読み込んだページも含めて検索をやり直す
+					runSearch(*pendingSearch)
+				}
+				displayCommits()
+			})
+		}()
+	}
+
+	// 選択位置が読み込み済みの末尾に近づいたら、次のページを読み込む
+	requestMoreCommits = func() {
+		_, _, _, height := textView.GetInnerRect()
+		if currentCommit+2*max(height, 1) < len(commits) {
+			return
 		}
+		loadNextPage()
 	}
 
 	// 初期表示
@@ -618,6 +1553,20 @@ func main() {
 
 	// キー入力のハンドリング
 	textView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		// ブランチの操作などの問い合わせ中はその入力として扱う
+		if actionPrompt != nil {
+			switch actionPrompt.HandleKey(event) {
+			case inputAccepted:
+				prompt := actionPrompt
+				actionPrompt = nil
+				prompt.Accept()
+			case inputCanceled:
+				actionPrompt = nil
+			}
+			displayCommits()
+			return nil
+		}
+
 		// ブランチ選択モードの場合
 		if branchSelectMode {
 			switch event.Key() {
@@ -639,9 +1588,13 @@ func main() {
 
 			case tcell.KeyEnter:
 				// Enterキー: 選択したブランチを確定し、確認モードに移行
+				// マージするブランチを選んでいた場合はマージの方法を選ぶ
 				branchSelectMode = false
 
-				if confirmAfterBranchSelect && currentBranchIndex >= 0 && currentBranchIndex < len(availableBranches) {
+				if mergeAfterBranchSelect && currentBranchIndex >= 0 && currentBranchIndex < len(availableBranches) {
+					mergeAfterBranchSelect = false
+					mergeFrom(availableBranches[currentBranchIndex])
+				} else if confirmAfterBranchSelect && currentBranchIndex >= 0 && currentBranchIndex < len(availableBranches) {
 					// ブランチが選択された後、確認モードに移行
 					confirmAfterBranchSelect = false
 					confirmMode = true
@@ -653,68 +1606,74 @@ func main() {
 			case tcell.KeyEscape:
 				// Escキー: ブランチ選択モードをキャンセル
 				branchSelectMode = false
+				mergeAfterBranchSelect = false
 				displayCommits()
 				return nil
 			}
 			return nil
 		}
 
+		// 検索文字列の入力中は入力に合わせて一致するコミットへ移動する
+		if searchMode {
+			switch searchInput.HandleKey(event) {
+			case inputAccepted:
+				searchMode = false
+				searchQuery = searchInput.String()
+			case inputCanceled:
+				// キャンセルしたら検索を始めた位置に戻る
+				searchMode = false
+				searchQuery = ""
+				pendingSearch = nil
+				currentCommit = searchOrigin
+			default:
+				searchQuery = searchInput.String()
+				currentCommit = searchOrigin
+				pendingSearch = nil
+				if searchQuery != "" {
+					runSearch(searchRequest{query: searchQuery, start: searchOrigin, forward: searchForward})
+				}
+			}
+			displayCommits()
+			return nil
+		}
+
+		// 絞り込み条件の入力中
+		if filterMode {
+			switch filterInput.HandleKey(event) {
+			case inputAccepted:
+				filterMode = false
+				if newFilter, err := parseLogFilter(filterInput.String()); err != nil {
+					statusMessage = fmt.Sprintf("[red]Invalid filter: %s[-]", tview.Escape(err.Error()))
+				} else {
+					applyFilter(newFilter)
+				}
+			case inputCanceled:
+				filterMode = false
+			}
+			displayCommits()
+			return nil
+		}
+
 		// 確認モードの場合、y/n の入力を処理
 		if confirmMode {
 			switch event.Rune() {
 			case 'y', 'Y':
 				// 確認モードをオフにして戻る
+				// 確認中にリストが読み込み直されても、確認したコミットをチェックアウトする
 				confirmMode = false
-				commit := commits[currentCommit]
-
-				// 未コミットの変更の場合は処理しない（安全策）
-				if commit.IsUncommitted {
-					displayCommits()
-					return nil
-				}
-
-				var output []byte
-				var err error
-
-				if isDetachedHeadMode {
-					// detached headモードの場合はハッシュを直接チェックアウト
-					output, err = exec.Command("git", "checkout", commit.Hash).CombinedOutput()
-				} else {
-					// ブランチモードの場合は選択したブランチをチェックアウト
-					selectedBranch := availableBranches[currentBranchIndex]
-					output, err = exec.Command("git", "switch", selectedBranch).CombinedOutput()
-				}
 
 				// ステータスエリアに結果を表示
-				statusArea.Clear()
+				output, err := checkoutConfirmed()
 				if err != nil {
-					statusArea.Write([]byte(fmt.Sprintf("Checkout failed: %v", err)))
-				} else {
-					// 成功時は短くメッセージを表示
-					shortMsg := "Checkout successful"
-					if len(output) > 0 {
-						shortMsg = string(output)
-						if len(shortMsg) > 60 { // 長すぎる場合は切り詰め
-							shortMsg = shortMsg[:60] + "..."
-						}
-					}
-
-					if isDetachedHeadMode {
-						statusArea.Write([]byte(fmt.Sprintf("Checkout successful (detached HEAD): %s", shortMsg)))
-					} else {
-						statusArea.Write([]byte(fmt.Sprintf("Switched to branch '%s': %s", availableBranches[currentBranchIndex], shortMsg)))
+					statusMessage = fmt.Sprintf("[red]Checkout failed: %s[-]", tview.Escape(formatMessage(err.Error())))
+					if isOverwriteError(err) {
+						offerStashAndCheckout(err)
 					}
+				} else {
+					statusMessage = checkoutSucceeded(output)
 
-					// コミット情報をリフレッシュしてブランチ表示を更新
-					refreshCommitInfo(commits)
-
-					// 確実にUI更新を行うため、少し待ってから再度表示を更新
-					go func() {
-						time.Sleep(100 * time.Millisecond)
-						app.QueueUpdateDraw(func() {
-							displayCommits()
-						})
-					}()
+					// ブランチとHEADの表示を更新
+					refreshRefs()
 				}
 
 				// 即時の表示更新
@@ -733,6 +1692,10 @@ func main() {
 		}
 
 		// 通常モード時のキー処理
+		// 前の操作の結果メッセージと、続きを読み込みながらの検索は次のキー入力で止める
+		statusMessage = ""
+		pendingSearch = nil
+
 		switch event.Key() {
 		case tcell.KeyUp:
 			if currentCommit > 0 {
@@ -771,55 +1734,260 @@ func main() {
 			return nil
 
 		case tcell.KeyEnter:
-			// Enter: コミットの選択（ただし、uncommitted changesの場合は何もしない）
-			if !commits[currentCommit].IsUncommitted {
-				commit := commits[currentCommit]
-
-				// detached headかどうかを判定
-				isDetachedHeadMode = true
-				if commit.Branch != "" {
-					// ブランチのHEADとコミットハッシュを比較
-					branchHash, err := getBranchCommitHash(commit.Branch)
-					if err == nil && branchHash == commit.Hash {
-						// ブランチのHEADとコミットハッシュが一致する場合
-						isDetachedHeadMode = false
+			// Enter: コミットの選択（未コミットの変更の行ではファイルの一覧を開く）
+			if commit, ok := selectedCommit(); ok && commit.IsUncommitted {
+				openStatus()
+			} else if ok && blockedByOperation("checkout") {
+				displayCommits()
+			} else if ok {
+				// このコミットを指しているブランチがなければdetached HEADになる
+				// リモートブランチも選べるようにする（ローカルブランチの後に並べる）
+				checkoutHash = commit.Hash
+				var branches []Ref
+				for _, kind := range []RefKind{RefLocalBranch, RefRemoteBranch} {
+					for _, ref := range refIndex.RefsAt(commit.Hash) {
+						if ref.Kind == kind {
+							branches = append(branches, ref)
+						}
 					}
 				}
+				isDetachedHeadMode = len(branches) == 0
 
 				if isDetachedHeadMode {
 					// detached head の場合は直接確認モードへ
 					confirmMode = true
 				} else {
-					// 通常のブランチの場合は、まずブランチ選択UIを表示
-					// コミットに関連付けられたすべてのブランチを取得
-					branches := getCommitBranches(commit.Hash)
-
-					if len(branches) > 0 {
-						// ブランチが存在する場合は選択モードを表示
-						availableBranches = branches
-						currentBranchIndex = 0
-						branchSelectMode = true
-						confirmAfterBranchSelect = true // ブランチ選択後に確認モードに入るフラグ
-					} else {
-						// ブランチが存在しない場合は確認モードへ
-						confirmMode = true
-					}
+					// ブランチがある場合は、まずブランチ選択UIを表示
+					availableBranches = branches
+					currentBranchIndex = 0
+					branchSelectMode = true
+					confirmAfterBranchSelect = true // ブランチ選択後に確認モードに入るフラグ
+					mergeAfterBranchSelect = false
 				}
 
 				displayCommits()
 			}
 			return nil
+
+		case tcell.KeyTab:
+			// Tab: 詳細表示にフォーカスを移してスクロールできるようにする
+			if detailVisible {
+				app.SetFocus(detailView)
+			}
+			return nil
+
+		case tcell.KeyRune:
+			switch event.Rune() {
+			case 'd':
+				// d: コミット詳細の表示/非表示を切り替え
+				detailVisible = !detailVisible
+				if detailVisible {
+					listFlex.AddItem(detailView, 0, 1, false)
+				} else {
+					listFlex.RemoveItem(detailView)
+				}
+				detailHash = ""
+				displayCommits()
+				return nil
+
+			case '/', '?':
+				// /: 下方向に検索、?: 上方向に検索
+				searchMode = true
+				searchForward = event.Rune() == '/'
+				searchOrigin = currentCommit
+				searchInput.SetText("")
+				searchQuery = ""
+				displayCommits()
+				return nil
+
+			case 'n', 'N':
+				// n: 同じ方向に次を検索、N: 逆方向に検索
+				if searchQuery != "" {
+					forward := searchForward == (event.Rune() == 'n')
+					runSearch(searchRequest{query: searchQuery, start: currentCommit, forward: forward})
+					displayCommits()
+				}
+				return nil
+
+			case 'f':
+				// f: 絞り込み条件を入力（現在の条件を編集できるようにする）
+				filterMode = true
+				filterInput.SetText(filter.String())
+				displayCommits()
+				return nil
+
+			case 'R':
+				// R: コミットログ、ref、未コミットの変更をすべて読み込み直す
+				reloadCommits()
+				displayCommits()
+				return nil
+
+			case 'v':
+				// v: 選択中のコミットの差分を表示
+				if commit, ok := selectedCommit(); ok && !commit.IsUncommitted {
+					openDiff(commit.Hash)
+				}
+				return nil
+
+			case 'b':
+				// b: 選択中のコミットでブランチを作成、名前の変更、削除、上流の設定を行う
+				if commit, ok := selectedCommit(); ok && !commit.IsUncommitted {
+					openBranchMenu(commit.Hash)
+					displayCommits()
+				}
+				return nil
+
+			case 't':
+				// t: 選択中のコミットにタグを作成、またはタグを削除する
+				if commit, ok := selectedCommit(); ok && !commit.IsUncommitted {
+					openTagMenu(commit.Hash)
+					displayCommits()
+				}
+				return nil
+
+			case 'B':
+				// B: ブランチの一覧を開く
+				openBranches()
+				return nil
+
+			case 'c':
+				// c: ステージングした変更をコミットする
+				openComposer(func() {
+					pages.SwitchToPage("main")
+					app.SetFocus(textView)
+				})
+				return nil
+
+			case 'C':
+				// C: 選択中のコミットを現在のHEADにcherry-pickする
+				if commit, ok := selectedCommit(); ok && !commit.IsUncommitted && !blockedByOperation("cherry-pick") {
+					cherryPick(commit.Hash)
+				}
+				displayCommits()
+				return nil
+
+			case 'r':
+				// r: 選択中のコミットをrevertする
+				if commit, ok := selectedCommit(); ok && !commit.IsUncommitted && !blockedByOperation("revert") {
+					revertCommit(commit.Hash)
+				}
+				displayCommits()
+				return nil
+
+			case 'g':
+				// g: 現在のブランチを選択中のコミットにresetする
+				if commit, ok := selectedCommit(); ok && !commit.IsUncommitted && !blockedByOperation("reset") {
+					resetTo(commit.Hash)
+				}
+				displayCommits()
+				return nil
+
+			case 'i':
+				// i: 選択中のコミットより後のコミットをリベースする手順を編集する
+				if commit, ok := selectedCommit(); ok && !commit.IsUncommitted && !blockedByOperation("rebase") {
+					openRebase(commit.Hash)
+				}
+				displayCommits()
+				return nil
+
+			case 'm':
+				// m: 選択中のコミット（またはそれを指すブランチ）を現在のHEADにマージする
+				if commit, ok := selectedCommit(); ok && !commit.IsUncommitted && !blockedByOperation("merge") {
+					startMerge(commit.Hash)
+				}
+				displayCommits()
+				return nil
+
+			case 's':
+				// s: 未コミットの変更の行で、変更をスタッシュする
+				// マージやリベースなどの途中では衝突を解決している変更をスタッシュしないようにする
+				if commit, ok := selectedCommit(); !ok || !commit.IsUncommitted {
+					statusMessage = "Select the uncommitted changes row to stash them"
+				} else if !blockedByOperation("stash") {
+					stashChanges()
+				}
+				displayCommits()
+				return nil
+
+			case 'S':
+				// S: スタッシュの一覧を開く
+				openStashes()
+				return nil
+
+			case 'F':
+				// F: すべてのリモートからfetchする
+				fetchRemotes()
+				displayCommits()
+				return nil
+
+			case 'p':
+				// p: 現在のブランチに上流の変更を取り込む
+				if !blockedByOperation("pull") {
+					pullBranch()
+				}
+				displayCommits()
+				return nil
+
+			case 'o':
+				// o: 途中で止まっているマージやリベースなどを続ける、飛ばす、または中止する
+				if operation.Kind == operationNone {
+					statusMessage = "No merge, rebase, cherry-pick, revert or bisect is in progress"
+				} else {
+					openOperationMenu()
+				}
+				displayCommits()
+				return nil
+
+			case 'P':
+				// P: 現在のブランチをpushする
+				if refIndex.HeadBranch == "" {
+					statusMessage = "[yellow]HEAD is detached; switch to a branch to push[-]"
+				} else {
+					pushBranch()
+				}
+				displayCommits()
+				return nil
+			}
 		}
 
 		return event
 	})
 
+	// 詳細表示のキー入力のハンドリング
+	detailView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyTab {
+			// Tab: コミットリストにフォーカスを戻す
+			app.SetFocus(textView)
+			return nil
+		}
+		if commit, ok := selectedCommit(); ok && event.Rune() == 'v' && commit.Hash == detailHash && !commit.IsUncommitted {
+			// v: 表示中のコミットの差分を表示
+			openDiff(detailHash)
+			return nil
+		}
+		return event
+	})
+
 	// アプリケーション全体のキー入力のハンドリング
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if event.Key() == tcell.KeyEscape {
+			// コミットリスト以外の画面ではそれぞれのビューで処理する
+			if page, _ := pages.GetFrontPage(); page != "main" {
+				return event
+			}
+			// 検索や絞り込み条件の入力中、問い合わせ中はキャンセルとして処理する
+			if searchMode || filterMode || actionPrompt != nil {
+				return event
+			}
+			// 詳細表示にフォーカスがあるときはコミットリストに戻す
+			if app.GetFocus() == detailView {
+				app.SetFocus(textView)
+				return nil
+			}
 			// ブランチ選択モード中のEscapeはブランチ選択モードを解除
 			if branchSelectMode {
 				branchSelectMode = false
+				mergeAfterBranchSelect = false
 				displayCommits()
 				return nil
 			}
@@ -843,29 +2011,56 @@ func main() {
 		return false // 通常の描画処理を継続
 	})
 
-	// 定期的に画面更新とHEADの位置更新を行うタイマー
-	go func() {
-		ticker := time.NewTicker(500 * time.Millisecond)
-		for range ticker.C {
-			app.QueueUpdateDraw(func() {
-				// 最新のHEADの位置を取得
-				headHash, err := getHeadCommitHash()
-				if err == nil {
-					// HEADの位置を更新
-					for i := range commits {
-						if !commits[i].IsUncommitted {
-							commits[i].IsHead = (commits[i].Hash == headHash)
-						}
-					}
+	// リポジトリの変更を監視し、変わった部分だけを読み込み直す
+	// 監視を開始できない場合は起動時の状態のまま表示する
+	if events, err := watchRepository(gitDir, "."); err == nil {
+		go func() {
+			for event := range events {
+				// 読み込みはこのゴルーチンで行い、結果だけをUIに渡す
+				var index *RefIndex
+				if event&watchRefs != 0 {
+					index, _ = loadRefIndex(repo)
 				}
+				// 操作の開始や終了はrefの変更、衝突の解決はインデックスの変更として検出する
+				newOperation, operationErr := repo.Operation()
+				checkWorktree := event&watchWorktree != 0
+				var uncommitted *Commit
+				if checkWorktree && repo.HasUncommittedChanges() {
+					row := newUncommittedCommit(repo, "")
+					uncommitted = &row
+				}
+
+				incomplete := event&watchIncomplete != 0
 
-				// 画面を更新
-				if currentCommit >= 0 && currentCommit < len(commits) {
+				app.QueueUpdateDraw(func() {
+					if operationErr == nil {
+						setOperation(newOperation)
+					}
+					if index != nil {
+						applyRefIndex(index)
+						if page, _ := pages.GetFrontPage(); page == "branches" {
+							// 一覧を開いているときは一覧も更新する
+							branchViewer.Refresh()
+						} else if page == "stash" {
+							stashViewer.Refresh()
+						}
+					}
+					if checkWorktree {
+						updateUncommitted(uncommitted)
+						if page, _ := pages.GetFrontPage(); page == "status" {
+							// 一覧を開いているときは一覧も更新する
+							statusViewer.Refresh()
+						}
+					}
+					if incomplete {
+						// 監視できない部分の変更は検出できないので、手動で読み込み直すように伝える
+						statusMessage = "[yellow]Some directories could not be watched (inotify limit reached); press R to reload[-]"
+					}
 					displayCommits()
-				}
-			})
-		}
-	}()
+				})
+			}
+		}()
+	}
 
 	// アプリケーション実行
 	// QueueUpdateDrawを最初に一度だけ使用するように修正
@@ -879,8 +2074,8 @@ func main() {
 		})
 	}()
 
-	// メインレイアウト（flex）をルートとして設定
-	if err := app.SetRoot(flex, true).Run(); err != nil {
+	// 画面の切り替えを行うpagesをルートとして設定
+	if err := app.SetRoot(pages, true).Run(); err != nil {
 		panic(err)
 	}
 }
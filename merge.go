@@ -0,0 +1,61 @@
+package main
+
+import "fmt"
+
+// ブランチやコミットを現在のHEADにマージする方法
+type MergeMode int
+
+const (
+	mergeFastForward   MergeMode = iota // 早送りできるときだけマージする（--ff-only）
+	mergeNoFastForward                  // 早送りできてもマージコミットを作る（--no-ff）
+	mergeSquash                         // 変更を1つのコミットにまとめる（--squash）
+)
+
+func (m MergeMode) String() string {
+	switch m {
+	case mergeNoFastForward:
+		return "no-ff"
+	case mergeSquash:
+		return "squash"
+	}
+	return "ff-only"
+}
+
+// マージで取り込まれるものの見積もり
+type MergePreview struct {
+	Commits     int  // HEADから辿れないコミットの数（git rev-list --count HEAD..source）
+	FastForward bool // HEADがマージするコミットの祖先で、早送りできるかどうか
+}
+
+// マージするrevision（ブランチならその名前、そうでなければコミットのハッシュ）
+func mergeRevision(source Ref) string {
+	if source.Name != "" {
+		return source.Name
+	}
+	return source.Hash
+}
+
+// マージコミットの既定のメッセージ（gitコマンドと同じ形式）
+// sourceの名前が空ならコミットをマージする。squashならまとめたコミットのメッセージにする
+// gitコマンドと同じく、masterへのマージでは" into master"を付けない
+func mergeMessage(source Ref, head string, squash bool) string {
+	var what string
+	switch {
+	case source.Name == "":
+		what = fmt.Sprintf("commit '%s'", source.Hash[:7])
+	case source.Kind == RefRemoteBranch:
+		what = fmt.Sprintf("remote-tracking branch '%s'", source.Name)
+	case source.Kind == RefTag:
+		what = fmt.Sprintf("tag '%s'", source.Name)
+	default:
+		what = fmt.Sprintf("branch '%s'", source.Name)
+	}
+	message := "Merge " + what
+	if squash {
+		message = "Squash " + what
+	}
+	if head != "" && head != "master" {
+		message += " into " + head
+	}
+	return message
+}
@@ -0,0 +1,34 @@
+package main
+
+import "testing"
+
+func TestMergeMessage(t *testing.T) {
+	hash := "0123456789abcdef0123456789abcdef01234567"
+	tests := []struct {
+		source Ref
+		head   string
+		squash bool
+		want   string
+	}{
+		{Ref{Name: "feature", Kind: RefLocalBranch, Hash: hash}, "master", false, "Merge branch 'feature'"},
+		{Ref{Name: "feature", Kind: RefLocalBranch, Hash: hash}, "develop", false, "Merge branch 'feature' into develop"},
+		{Ref{Name: "origin/feature", Kind: RefRemoteBranch, Hash: hash}, "main", false, "Merge remote-tracking branch 'origin/feature' into main"},
+		{Ref{Hash: hash}, "", false, "Merge commit '0123456'"},
+		{Ref{Name: "feature", Kind: RefLocalBranch, Hash: hash}, "develop", true, "Squash branch 'feature' into develop"},
+	}
+	for _, tt := range tests {
+		if got := mergeMessage(tt.source, tt.head, tt.squash); got != tt.want {
+			t.Errorf("mergeMessage(%+v, %q, %v) = %q; want %q", tt.source, tt.head, tt.squash, got, tt.want)
+		}
+	}
+}
+
+func TestMergeRevision(t *testing.T) {
+	hash := "0123456789abcdef0123456789abcdef01234567"
+	if got := mergeRevision(Ref{Name: "origin/feature", Kind: RefRemoteBranch, Hash: hash}); got != "origin/feature" {
+		t.Errorf("mergeRevision(branch) = %q", got)
+	}
+	if got := mergeRevision(Ref{Hash: hash}); got != hash {
+		t.Errorf("mergeRevision(commit) = %q", got)
+	}
+}
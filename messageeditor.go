@@ -0,0 +1,99 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// マージコミットなどのメッセージを複数行で編集するビュー
+// コミットの入力欄と同じく件名の長さの目安を表示し、Ctrl-Sで書いたメッセージを渡す
+type messageEditor struct {
+	*tview.Flex
+	box     *tview.Flex     // 目盛りと入力欄を囲む枠
+	ruler   *tview.TextView // 件名の長さの目安を示す目盛り
+	editor  *tview.TextArea
+	footer  *tview.TextView // 件名の長さと使えるキーを表示する2行の領域
+	message string          // 入力の誤りなど
+
+	acceptFunc func(message string) // メッセージを確定したときに呼ぶ関数
+	closeFunc  func()               // 確定せずに閉じるときに呼ぶ関数
+}
+
+// メッセージの入力欄を作成
+func newMessageEditor() *messageEditor {
+	e := &messageEditor{
+		ruler:  tview.NewTextView().SetDynamicColors(true).SetWrap(false),
+		editor: tview.NewTextArea(),
+		footer: tview.NewTextView().SetDynamicColors(true),
+	}
+	e.editor.SetChangedFunc(e.render)
+	e.editor.SetInputCapture(e.handleKey)
+
+	e.box = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(e.ruler, 1, 0, false).
+		AddItem(e.editor, 0, 1, true)
+	e.box.SetBorder(true)
+	e.box.SetDrawFunc(func(screen tcell.Screen, x, y, width, height int) (int, int, int, int) {
+		// 枠の内側の幅に合わせて目盛りを描き直す
+		e.ruler.SetText(subjectRuler(width - 2))
+		return x + 1, y + 1, width - 2, height - 2
+	})
+
+	e.Flex = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(e.box, 0, 1, true).
+		AddItem(e.footer, 2, 0, false)
+	return e
+}
+
+// ビューを閉じるときに呼ぶ関数を設定
+func (e *messageEditor) SetCloseFunc(handler func()) *messageEditor {
+	e.closeFunc = handler
+	return e
+}
+
+// titleを枠に表示し、textを初期値にして入力欄を開く（確定するとacceptを呼ぶ）
+func (e *messageEditor) Open(title, text string, accept func(message string)) {
+	e.box.SetTitle(title)
+	e.editor.SetText(text, false)
+	e.acceptFunc = accept
+	e.message = ""
+	e.render()
+}
+
+// 件名の長さと入力の誤りを描画する
+func (e *messageEditor) render() {
+	e.footer.Clear()
+	line := subjectLength(e.editor.GetText())
+	if e.message != "" {
+		line += "  " + e.message
+	}
+	e.footer.Write([]byte(line))
+	e.footer.Write([]byte("\n[gray]Ctrl-S: accept  Esc: cancel  (lines starting with # are removed)[-]"))
+}
+
+// キー入力のハンドリング
+func (e *messageEditor) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Key() {
+	case tcell.KeyCtrlS:
+		message := e.editor.GetText()
+		if cleanupMessage(message) == "" {
+			e.message = "[yellow]The message is empty[-]"
+			break
+		}
+		if e.acceptFunc != nil {
+			e.acceptFunc(message)
+		}
+		return nil
+	case tcell.KeyEscape:
+		if e.closeFunc != nil {
+			e.closeFunc()
+		}
+		return nil
+	default:
+		return event
+	}
+	e.render()
+	return nil
+}
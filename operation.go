@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 途中で止まっている操作の種類
+type OperationKind int
+
+const (
+	operationNone       OperationKind = iota // 操作の途中ではない
+	operationMerge                           // マージ（MERGE_HEAD）
+	operationRebase                          // リベース（rebase-merge/、rebase-apply/）
+	operationCherryPick                      // cherry-pick（CHERRY_PICK_HEAD）
+	operationRevert                          // revert（REVERT_HEAD）
+	operationBisect                          // bisect（BISECT_LOG）
+)
+
+// 操作の名前（gitのサブコマンドの名前と同じ）
+func (k OperationKind) String() string {
+	switch k {
+	case operationMerge:
+		return "merge"
+	case operationRebase:
+		return "rebase"
+	case operationCherryPick:
+		return "cherry-pick"
+	case operationRevert:
+		return "revert"
+	case operationBisect:
+		return "bisect"
+	}
+	return "none"
+}
+
+// 途中で止まっている操作をどう終えるか
+type OperationAction int
+
+const (
+	operationContinue OperationAction = iota // 続ける
+	operationAbort                           // 中止して操作の前に戻す
+	operationSkip                            // 今のコミットを飛ばして続ける
+)
+
+func (a OperationAction) String() string {
+	switch a {
+	case operationAbort:
+		return "abort"
+	case operationSkip:
+		return "skip"
+	}
+	return "continue"
+}
+
+// 操作の種類ごとに使える終え方
+func (k OperationKind) Actions() []OperationAction {
+	switch k {
+	case operationMerge:
+		return []OperationAction{operationContinue, operationAbort}
+	case operationBisect:
+		return []OperationAction{operationSkip, operationAbort}
+	case operationNone:
+		return nil
+	}
+	return []OperationAction{operationContinue, operationSkip, operationAbort}
+}
+
+// 途中で止まっている操作の状態
+type Operation struct {
+	Kind      OperationKind
+	Step      int      // リベースで適用中の手順（1から数える、不明なら0）
+	Total     int      // リベースの手順の数
+	Conflicts []string // 衝突が解決されていないファイル
+}
+
+// 操作の途中であることを示すためにGitディレクトリに作られるファイルとディレクトリ
+// 監視でこれらの作成と削除を検出する
+var operationFiles = []string{"MERGE_HEAD", "CHERRY_PICK_HEAD", "REVERT_HEAD", "BISECT_LOG", "rebase-merge", "rebase-apply"}
+
+// Gitディレクトリのファイルから途中で止まっている操作を判定する（衝突したファイルは含まない）
+// リベース中にもcherry-pickなどのファイルが作られることがあるので、リベースを先に判定する
+func readOperation(gitDir string) Operation {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(gitDir, name))
+		return err == nil
+	}
+	number := func(name string) int {
+		var n int
+		if data, err := os.ReadFile(filepath.Join(gitDir, name)); err == nil {
+			fmt.Sscan(strings.TrimSpace(string(data)), &n)
+		}
+		return n
+	}
+
+	switch {
+	case exists("rebase-merge"):
+		return Operation{Kind: operationRebase, Step: number("rebase-merge/msgnum"), Total: number("rebase-merge/end")}
+	case exists("rebase-apply"):
+		return Operation{Kind: operationRebase, Step: number("rebase-apply/next"), Total: number("rebase-apply/last")}
+	case exists("MERGE_HEAD"):
+		return Operation{Kind: operationMerge}
+	case exists("CHERRY_PICK_HEAD"):
+		return Operation{Kind: operationCherryPick}
+	case exists("REVERT_HEAD"):
+		return Operation{Kind: operationRevert}
+	case exists("BISECT_LOG"):
+		return Operation{Kind: operationBisect}
+	}
+	return Operation{}
+}
+
+// ステータス領域に表示する操作の状態
+func (o Operation) Banner() string {
+	var b strings.Builder
+	title := strings.ToUpper(o.Kind.String())
+	if o.Kind == operationRebase && o.Total > 0 {
+		title += fmt.Sprintf(" %d/%d", o.Step, o.Total)
+	}
+	fmt.Fprintf(&b, "%s in progress", title)
+	if len(o.Conflicts) > 0 {
+		fmt.Fprintf(&b, ": %s", describeConflicts(o.Conflicts))
+	}
+	return b.String()
+}
+
+// 衝突したファイルの一覧を短く説明する（多ければ先頭の3つだけを挙げる）
+func describeConflicts(files []string) string {
+	switch n := len(files); {
+	case n == 1:
+		return "conflict in " + files[0]
+	case n > 3:
+		return fmt.Sprintf("%d conflicts in %s and %d more", n, strings.Join(files[:3], ", "), n-3)
+	}
+	return fmt.Sprintf("%d conflicts in %s", len(files), strings.Join(files, ", "))
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadOperation(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  Operation
+	}{
+		{"none", nil, Operation{}},
+		{"merge", map[string]string{"MERGE_HEAD": "abc\n"}, Operation{Kind: operationMerge}},
+		{"cherry-pick", map[string]string{"CHERRY_PICK_HEAD": "abc\n"}, Operation{Kind: operationCherryPick}},
+		{"revert", map[string]string{"REVERT_HEAD": "abc\n"}, Operation{Kind: operationRevert}},
+		{"bisect", map[string]string{"BISECT_LOG": "git bisect start\n"}, Operation{Kind: operationBisect}},
+		{
+			"interactive rebase",
+			map[string]string{"rebase-merge/msgnum": "2\n", "rebase-merge/end": "5\n", "CHERRY_PICK_HEAD": "abc\n"},
+			Operation{Kind: operationRebase, Step: 2, Total: 5},
+		},
+		{
+			"apply rebase",
+			map[string]string{"rebase-apply/next": "1\n", "rebase-apply/last": "3\n"},
+			Operation{Kind: operationRebase, Step: 1, Total: 3},
+		},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for name, content := range tt.files {
+			path := filepath.Join(dir, name)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := readOperation(dir); got.Kind != tt.want.Kind || got.Step != tt.want.Step || got.Total != tt.want.Total {
+			t.Errorf("%s: readOperation() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOperationBanner(t *testing.T) {
+	tests := []struct {
+		operation Operation
+		want      string
+	}{
+		{Operation{Kind: operationMerge}, "MERGE in progress"},
+		{Operation{Kind: operationRebase, Step: 1, Total: 2, Conflicts: []string{"a.txt"}}, "REBASE 1/2 in progress: conflict in a.txt"},
+		{Operation{Kind: operationCherryPick, Conflicts: []string{"a", "b"}}, "CHERRY-PICK in progress: 2 conflicts in a, b"},
+		{Operation{Kind: operationRevert, Conflicts: []string{"a", "b", "c", "d", "e"}}, "REVERT in progress: 5 conflicts in a, b, c and 2 more"},
+	}
+	for _, tt := range tests {
+		if got := tt.operation.Banner(); got != tt.want {
+			t.Errorf("Banner() = %q, want %q", got, tt.want)
+		}
+	}
+}
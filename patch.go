@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 差分の中の1行の位置
+type diffPosition struct {
+	Hunk int // ハンクの番号
+	Line int // ハンクの中の行の番号
+}
+
+// 差分のうち選んだ変更行だけを適用するパッチを作る（git add -pのように）
+// 選ばなかった変更行は、適用しても内容が変わらないように書き換える
+// reverseなら逆向きに適用する（git apply --reverse）パッチにする
+// 選んだ変更行がなければ空文字列を返す
+func partialPatch(file diffFile, selected map[diffPosition]bool, reverse bool) string {
+	var body strings.Builder
+	oldTotal, newTotal := 0, 0
+	delta := 0 // それまでのハンクで増えた行数（適用する向きに数える）
+
+	for h, hunk := range file.Hunks {
+		var lines []string
+		oldCount, newCount := 0, 0
+		changed := false
+		kept := false // 直前の行を残したかどうか（"\ No newline at end of file"の行のため）
+		for i, line := range hunk.Lines {
+			kind := line.Kind
+			switch kind {
+			case '\\':
+				if kept {
+					lines = append(lines, "\\"+line.Text)
+				}
+				continue
+			case '+', '-':
+				if selected[diffPosition{Hunk: h, Line: i}] {
+					changed = true
+				} else if (kind == '+') != reverse {
+					// 適用する前の内容にない行は捨てる
+					kept = false
+					continue
+				} else {
+					// 適用する前の内容にある行は変更しない行にする
+					kind = ' '
+				}
+			}
+			kept = true
+			lines = append(lines, string(kind)+line.Text)
+			if kind != '+' {
+				oldCount++
+			}
+			if kind != '-' {
+				newCount++
+			}
+		}
+		if !changed {
+			continue
+		}
+
+		// ハンクの開始位置（行数が0のときは直前の行の番号）
+		// 適用する側の開始位置は元の差分のまま、反対側はそれまでのハンクの増減でずらす
+		first := func(start, count int) int {
+			if count == 0 {
+				return start + 1
+			}
+			return start
+		}
+		start := func(first, count int) int {
+			if count == 0 {
+				return first - 1
+			}
+			return first
+		}
+		var oldStart, newStart int
+		if reverse {
+			newFirst := first(hunk.NewStart, hunk.NewLines)
+			oldStart, newStart = start(newFirst+delta, oldCount), start(newFirst, newCount)
+			delta += oldCount - newCount
+		} else {
+			oldFirst := first(hunk.OldStart, hunk.OldLines)
+			oldStart, newStart = start(oldFirst, oldCount), start(oldFirst+delta, newCount)
+			delta += newCount - oldCount
+		}
+
+		fmt.Fprintf(&body, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		for _, line := range lines {
+			body.WriteString(line + "\n")
+		}
+		oldTotal += oldCount
+		newTotal += newCount
+	}
+	if body.Len() == 0 {
+		return ""
+	}
+
+	// 新しいファイルの一部だけを取り消す場合や、削除したファイルの一部だけを適用する場合は
+	// 適用した後もファイルが残るので、ファイルの変更のパッチにする
+	var header strings.Builder
+	toModification := false
+	for _, line := range file.Header {
+		if (strings.HasPrefix(line, "new file mode ") && oldTotal > 0) ||
+			(strings.HasPrefix(line, "deleted file mode ") && newTotal > 0) {
+			toModification = true
+		}
+	}
+	for _, line := range file.Header {
+		if toModification {
+			switch {
+			case strings.HasPrefix(line, "new file mode "), strings.HasPrefix(line, "deleted file mode "), strings.HasPrefix(line, "index "):
+				continue
+			case line == "--- /dev/null":
+				line = "--- a/" + file.Path
+			case line == "+++ /dev/null":
+				line = "+++ b/" + file.Path
+			}
+		}
+		header.WriteString(line + "\n")
+	}
+	return header.String() + body.String()
+}
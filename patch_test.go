@@ -0,0 +1,231 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// テスト用の差分（2つのハンクを持つ）
+const partialPatchDiff = `diff --git a/a.txt b/a.txt
+index 1111111..2222222 100644
+--- a/a.txt
++++ b/a.txt
+@@ -1,4 +1,4 @@
+ one
+-two
+-three
++TWO
++THREE
+ four
+@@ -10,2 +10,3 @@ func
+ ten
++ten and a half
+ eleven
+`
+
+func TestPartialPatch(t *testing.T) {
+	file := parseDiff(partialPatchDiff)[0]
+
+	tests := []struct {
+		name     string
+		selected []diffPosition
+		reverse  bool
+		want     string
+	}{
+		{
+			// 選ばなかった削除行は変更しない行に、追加行は捨てる
+			name:     "stage one removed and one added line",
+			selected: []diffPosition{{0, 1}, {0, 3}},
+			want: `@@ -1,4 +1,4 @@
+ one
+-two
+ three
++TWO
+ four
+`,
+		},
+		{
+			// 前のハンクを適用しない場合は後のハンクの位置はそのまま
+			name:     "stage second hunk only",
+			selected: []diffPosition{{1, 1}},
+			want: `@@ -10,2 +10,3 @@
+ ten
++ten and a half
+ eleven
+`,
+		},
+		{
+			// 前のハンクで行が増えた分だけ後のハンクの位置をずらす
+			name:     "stage added line in both hunks",
+			selected: []diffPosition{{0, 3}, {1, 1}},
+			want: `@@ -1,4 +1,5 @@
+ one
+ two
+ three
++TWO
+ four
+@@ -10,2 +11,3 @@
+ ten
++ten and a half
+ eleven
+`,
+		},
+		{
+			// 逆向きのパッチでは選ばなかった追加行を変更しない行に、削除行は捨てる
+			name:     "unstage one added line",
+			selected: []diffPosition{{0, 4}},
+			reverse:  true,
+			want: `@@ -1,3 +1,4 @@
+ one
+ TWO
++THREE
+ four
+`,
+		},
+		{
+			name:     "nothing selected",
+			selected: nil,
+			want:     "",
+		},
+	}
+
+	header := "diff --git a/a.txt b/a.txt\nindex 1111111..2222222 100644\n--- a/a.txt\n+++ b/a.txt\n"
+	for _, test := range tests {
+		selected := make(map[diffPosition]bool)
+		for _, position := range test.selected {
+			selected[position] = true
+		}
+		got := partialPatch(file, selected, test.reverse)
+		want := test.want
+		if want != "" {
+			want = header + want
+		}
+		if got != want {
+			t.Errorf("%s:\ngot:\n%s\nwant:\n%s", test.name, got, want)
+		}
+	}
+}
+
+func TestPartialPatchNewFile(t *testing.T) {
+	diff := `diff --git a/new.txt b/new.txt
+new file mode 100644
+index 0000000..3333333
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1,2 @@
++first
++second
+`
+	file := parseDiff(diff)[0]
+
+	// 新しいファイルの一部だけを追加するときは新しいファイルのまま
+	got := partialPatch(file, map[diffPosition]bool{{0, 0}: true}, false)
+	if want := "diff --git a/new.txt b/new.txt\nnew file mode 100644\nindex 0000000..3333333\n--- /dev/null\n+++ b/new.txt\n@@ -0,0 +1,1 @@\n+first\n"; got != want {
+		t.Errorf("stage part of new file:\n%s", got)
+	}
+
+	// 一部だけを取り消すとファイルは残るので、ファイルの変更にする
+	got = partialPatch(file, map[diffPosition]bool{{0, 1}: true}, true)
+	if want := "diff --git a/new.txt b/new.txt\n--- a/new.txt\n+++ b/new.txt\n@@ -1,1 +1,2 @@\n first\n+second\n"; got != want {
+		t.Errorf("unstage part of new file:\n%s", got)
+	}
+}
+
+func TestApplyPartialPatch(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("a.txt", "one\ntwo\nthree\nfour\n", "first")
+	r.write("a.txt", "one\nTWO\nthree\nFOUR\n")
+	repo := newExecRepository(r.dir)
+
+	diffOf := func(staged bool) diffFile {
+		t.Helper()
+		text, err := repo.FileDiff(FileStatus{Path: "a.txt"}, staged)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := parseDiff(text)
+		if len(files) != 1 {
+			t.Fatalf("FileDiff() = %q", text)
+		}
+		return files[0]
+	}
+	// textを含む変更行を選ぶ（大文字と小文字は区別しない）
+	changes := func(file diffFile, text string) map[diffPosition]bool {
+		selected := make(map[diffPosition]bool)
+		for h, hunk := range file.Hunks {
+			for i, line := range hunk.Lines {
+				if line.Kind != ' ' && strings.Contains(strings.ToLower(line.Text), text) {
+					selected[diffPosition{h, i}] = true
+				}
+			}
+		}
+		return selected
+	}
+
+	// 2行目の変更だけをステージングする
+	file := diffOf(false)
+	if err := repo.ApplyPatch(partialPatch(file, changes(file, "two"), false), true, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("show", ":a.txt"); got != "one\nTWO\nthree\nfour" {
+		t.Errorf("index after staging = %q", got)
+	}
+
+	// 残りもステージングしてから、2行目の変更だけを取り消す
+	r.git("add", "a.txt")
+	file = diffOf(true)
+	if err := repo.ApplyPatch(partialPatch(file, changes(file, "two"), true), true, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("show", ":a.txt"); got != "one\ntwo\nthree\nFOUR" {
+		t.Errorf("index after unstaging = %q", got)
+	}
+
+	// ステージングしていない2行目の変更を作業ツリーから捨てる
+	file = diffOf(false)
+	if err := repo.ApplyPatch(partialPatch(file, changes(file, "two"), true), false, true); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(r.dir, "a.txt")); string(content) != "one\ntwo\nthree\nFOUR\n" {
+		t.Errorf("a.txt after discard = %q", content)
+	}
+}
+
+func TestApplyPartialPatchAddedAndDeletedFiles(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("old.txt", "a\nb\n", "first")
+	r.write("new.txt", "x\ny\n")
+	os.Remove(filepath.Join(r.dir, "old.txt"))
+	repo := newExecRepository(r.dir)
+
+	apply := func(file FileStatus, staged bool, line int, reverse bool) {
+		t.Helper()
+		text, err := repo.FileDiff(file, staged)
+		if err != nil {
+			t.Fatal(err)
+		}
+		patch := partialPatch(parseDiff(text)[0], map[diffPosition]bool{{0, line}: true}, reverse)
+		if err := repo.ApplyPatch(patch, true, reverse); err != nil {
+			t.Fatalf("ApplyPatch(%s):\n%s\n%v", file.Path, patch, err)
+		}
+	}
+
+	// 追跡していないファイルの1行目だけを追加し、削除したファイルの1行目だけを削除する
+	apply(FileStatus{Path: "new.txt", Untracked: true}, false, 0, false)
+	apply(FileStatus{Path: "old.txt"}, false, 0, false)
+	if got := r.git("show", ":new.txt"); got != "x" {
+		t.Errorf("index new.txt = %q", got)
+	}
+	if got := r.git("show", ":old.txt"); got != "b" {
+		t.Errorf("index old.txt = %q", got)
+	}
+
+	// 追加した新しいファイルの一部を取り消してもファイルはインデックスに残る
+	r.git("add", "new.txt")
+	apply(FileStatus{Path: "new.txt"}, true, 1, true)
+	if got := r.git("show", ":new.txt"); got != "x" {
+		t.Errorf("index new.txt after unstaging = %q", got)
+	}
+}
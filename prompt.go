@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// 1行入力のキー処理の結果
+type inputResult int
+
+const (
+	inputEditing  inputResult = iota // 入力中
+	inputAccepted                    // Enterで確定
+	inputCanceled                    // Escでキャンセル
+)
+
+// ステータス領域で1行の文字列を入力するための状態
+type lineInput struct {
+	text   []rune
+	cursor int // カーソル位置（文字単位）
+}
+
+// 入力内容を設定してカーソルを末尾に移動
+func (in *lineInput) SetText(text string) {
+	in.text = []rune(text)
+	in.cursor = len(in.text)
+}
+
+// 入力内容を取得
+func (in *lineInput) String() string {
+	return string(in.text)
+}
+
+// キー入力を処理する
+func (in *lineInput) HandleKey(event *tcell.EventKey) inputResult {
+	switch event.Key() {
+	case tcell.KeyEnter:
+		return inputAccepted
+	case tcell.KeyEscape:
+		return inputCanceled
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if in.cursor > 0 {
+			in.text = append(in.text[:in.cursor-1], in.text[in.cursor:]...)
+			in.cursor--
+		}
+	case tcell.KeyDelete:
+		if in.cursor < len(in.text) {
+			in.text = append(in.text[:in.cursor], in.text[in.cursor+1:]...)
+		}
+	case tcell.KeyLeft:
+		if in.cursor > 0 {
+			in.cursor--
+		}
+	case tcell.KeyRight:
+		if in.cursor < len(in.text) {
+			in.cursor++
+		}
+	case tcell.KeyHome, tcell.KeyCtrlA:
+		in.cursor = 0
+	case tcell.KeyEnd, tcell.KeyCtrlE:
+		in.cursor = len(in.text)
+	case tcell.KeyCtrlU:
+		// カーソルより前を削除
+		in.text = in.text[in.cursor:]
+		in.cursor = 0
+	case tcell.KeyRune:
+		in.text = append(in.text[:in.cursor], append([]rune{event.Rune()}, in.text[in.cursor:]...)...)
+		in.cursor++
+	}
+	return inputEditing
+}
+
+// プロンプトと入力中の文字列をカーソル付きで表示用に整形する
+func (in *lineInput) Render(prompt string) string {
+	before := tview.Escape(string(in.text[:in.cursor]))
+	cursor := " "
+	after := ""
+	if in.cursor < len(in.text) {
+		cursor = tview.Escape(string(in.text[in.cursor]))
+		after = tview.Escape(string(in.text[in.cursor+1:]))
+	}
+	return prompt + before + "[::r]" + cursor + "[::-]" + after
+}
+
+// ステータス領域で入力や確認を求める問い合わせ
+// 文字列の入力、選択肢からの選択、y/nの確認のいずれかを受け付ける
+// 操作の対象は開いたときにacceptに閉じ込めておき、確定するまでに一覧が読み込み直されても変わらないようにする
+type statusPrompt struct {
+	message string     // 問い合わせの文
+	hint    string     // 2行目に表示する説明
+	warning bool       // 2行目の説明を警告として目立たせるかどうか
+	input   *lineInput // 文字列を入力する場合
+	choices []string   // 選択肢から選ぶ場合（←→で選ぶ）
+	choice  int        // 選択中の選択肢
+	accept  func(p *statusPrompt)
+}
+
+// 文字列を入力する問い合わせを作成
+func newInputPrompt(message, text string, accept func(text string)) *statusPrompt {
+	input := &lineInput{}
+	input.SetText(text)
+	return &statusPrompt{message: message, input: input, accept: func(p *statusPrompt) { accept(p.input.String()) }}
+}
+
+// 選択肢から選ぶ問い合わせを作成
+func newChoicePrompt(message string, choices []string, accept func(choice int)) *statusPrompt {
+	return &statusPrompt{message: message, choices: choices, accept: func(p *statusPrompt) { accept(p.choice) }}
+}
+
+// y/nで確認する問い合わせを作成
+func newConfirmPrompt(message string, accept func()) *statusPrompt {
+	return &statusPrompt{message: message, accept: func(*statusPrompt) { accept() }}
+}
+
+// 2行目に表示する説明を設定
+func (p *statusPrompt) SetHint(hint string) *statusPrompt {
+	p.hint = hint
+	return p
+}
+
+// 2行目に警告を表示する（取り消せない操作の確認に使う）
+func (p *statusPrompt) SetWarning(warning string) *statusPrompt {
+	p.hint = warning
+	p.warning = true
+	return p
+}
+
+// キー入力を処理する
+// 確定したときは呼び出し側が問い合わせを閉じてからAcceptを呼ぶ（acceptで次の問い合わせを開けるように）
+func (p *statusPrompt) HandleKey(event *tcell.EventKey) inputResult {
+	switch {
+	case p.input != nil:
+		return p.input.HandleKey(event)
+	case p.choices != nil:
+		switch event.Key() {
+		case tcell.KeyLeft:
+			if p.choice > 0 {
+				p.choice--
+			}
+		case tcell.KeyRight:
+			if p.choice < len(p.choices)-1 {
+				p.choice++
+			}
+		case tcell.KeyEnter:
+			return inputAccepted
+		case tcell.KeyEscape:
+			return inputCanceled
+		}
+		return inputEditing
+	}
+
+	// その他のキーは無視する
+	switch {
+	case event.Rune() == 'y' || event.Rune() == 'Y':
+		return inputAccepted
+	case event.Rune() == 'n' || event.Rune() == 'N' || event.Key() == tcell.KeyEscape:
+		return inputCanceled
+	}
+	return inputEditing
+}
+
+// 確定した内容で処理を行う
+func (p *statusPrompt) Accept() {
+	p.accept(p)
+}
+
+// ステータス領域に表示する文字列
+func (p *statusPrompt) Render() string {
+	var text string
+	switch {
+	case p.input != nil:
+		text = p.input.Render(tview.Escape(p.message))
+	case p.choices != nil:
+		text = tview.Escape(p.message)
+		for i, choice := range p.choices {
+			if i == p.choice {
+				// 選択中の選択肢は強調表示
+				text += fmt.Sprintf(" [black:white]%s[-:-]", tview.Escape(choice))
+			} else {
+				text += " " + tview.Escape(choice)
+			}
+		}
+	default:
+		text = tview.Escape(p.message) + " [y/n]"
+	}
+	if p.hint != "" && p.warning {
+		text += "\n[red::b]" + tview.Escape(p.hint) + "[-::-]"
+	} else if p.hint != "" {
+		text += "\n[gray]" + tview.Escape(p.hint) + "[-]"
+	}
+	return text
+}
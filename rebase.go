@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// リベースでコミットをどう扱うか
+type RebaseAction int
+
+const (
+	rebasePick   RebaseAction = iota // そのまま適用する
+	rebaseReword                     // 適用してメッセージを書き換える
+	rebaseEdit                       // 適用した後で止まる
+	rebaseSquash                     // 前のコミットにまとめ、メッセージもつなげる
+	rebaseFixup                      // 前のコミットにまとめ、メッセージは捨てる
+	rebaseDrop                       // 適用しない
+)
+
+func (a RebaseAction) String() string {
+	switch a {
+	case rebaseReword:
+		return "reword"
+	case rebaseEdit:
+		return "edit"
+	case rebaseSquash:
+		return "squash"
+	case rebaseFixup:
+		return "fixup"
+	case rebaseDrop:
+		return "drop"
+	}
+	return "pick"
+}
+
+// リベースの手順の1行
+type RebaseStep struct {
+	Action  RebaseAction
+	Hash    string
+	Subject string
+	Message string // rewordで使う新しいメッセージ
+}
+
+// コミットの一覧から、baseより後の現在のHEADまでのコミットをリベースの手順にする
+// HEADから最初の親をたどってbaseまでのコミットを集め、古い順（適用する順）に並べる
+// 読み込んでいないコミットやマージコミットがあれば手順を作れない
+func planRebase(commits []Commit, head, base string) ([]RebaseStep, error) {
+	byHash := make(map[string]Commit, len(commits))
+	for _, commit := range commits {
+		if !commit.IsUncommitted {
+			byHash[commit.Hash] = commit
+		}
+	}
+
+	var steps []RebaseStep
+	for hash := head; hash != base; {
+		commit, ok := byHash[hash]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("%s is not an ancestor of HEAD in the loaded commits", base[:7])
+		case len(commit.Parents) > 1:
+			return nil, fmt.Errorf("cannot rebase over the merge commit %s", commit.Hash[:7])
+		case len(commit.Parents) == 0:
+			return nil, fmt.Errorf("%s is not an ancestor of HEAD", base[:7])
+		}
+		steps = append(steps, RebaseStep{Action: rebasePick, Hash: commit.Hash, Subject: commit.Message})
+		hash = commit.Parents[0]
+	}
+	if len(steps) == 0 {
+		return nil, errors.New("there are no commits above the base")
+	}
+
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return steps, nil
+}
+
+// 手順を実行できるか確認する
+func checkRebaseSteps(steps []RebaseStep) error {
+	picked := false // squashとfixupでまとめる先のコミットがあるかどうか
+	for _, step := range steps {
+		switch step.Action {
+		case rebaseSquash, rebaseFixup:
+			if !picked {
+				return fmt.Errorf("cannot %s %s without a previous commit", step.Action, step.Hash[:7])
+			}
+		case rebaseReword:
+			if cleanupMessage(step.Message) == "" {
+				return fmt.Errorf("the new message of %s is empty", step.Hash[:7])
+			}
+			picked = true
+		case rebaseDrop:
+		default:
+			picked = true
+		}
+	}
+	return nil
+}
+
+// git rebase -iの手順のファイルの内容を作る
+// rewordはエディタを開かずに済むよう、pickした後でexecからメッセージを書き換える
+func rebaseTodo(steps []RebaseStep) string {
+	var b strings.Builder
+	for _, step := range steps {
+		action := step.Action
+		if action == rebaseReword {
+			action = rebasePick
+		}
+		fmt.Fprintf(&b, "%s %s %s\n", action, step.Hash, step.Subject)
+		if step.Action == rebaseReword {
+			fmt.Fprintf(&b, "exec %s | git commit --amend --allow-empty --cleanup=strip --file=-\n", shellPrintf(step.Message))
+		}
+	}
+	return b.String()
+}
+
+// 複数行の文字列を出力するシェルのコマンドを1行で作る
+func shellPrintf(text string) string {
+	args := []string{"printf", shellQuote("%s\\n")}
+	for _, line := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+		args = append(args, shellQuote(line))
+	}
+	return strings.Join(args, " ")
+}
+
+// シェルの引数として単一引用符で囲む
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
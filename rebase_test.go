@@ -0,0 +1,83 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlanRebase(t *testing.T) {
+	hash := func(c byte) string { return strings.Repeat(string(c), 40) }
+	// 新しい順の一覧（uncommittedの行と別のブランチのコミットも含む）
+	commits := []Commit{
+		{IsUncommitted: true, Parents: []string{hash('d')}},
+		{Hash: hash('x'), Message: "other branch", Parents: []string{hash('b')}},
+		{Hash: hash('d'), Message: "fourth", Parents: []string{hash('c')}},
+		{Hash: hash('c'), Message: "third", Parents: []string{hash('b')}},
+		{Hash: hash('m'), Message: "merge", Parents: []string{hash('b'), hash('x')}},
+		{Hash: hash('b'), Message: "second", Parents: []string{hash('a')}},
+		{Hash: hash('a'), Message: "first"},
+	}
+
+	steps, err := planRebase(commits, hash('d'), hash('a'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, step := range steps {
+		got = append(got, step.Action.String()+" "+step.Subject)
+	}
+	want := []string{"pick second", "pick third", "pick fourth"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("planRebase() = %q; want %q", got, want)
+	}
+
+	for _, tt := range []struct {
+		head, base byte
+		want       string
+	}{
+		{'d', 'd', "no commits"},
+		{'d', 'x', "not an ancestor"},
+		{'m', 'a', "merge commit"},
+	} {
+		if _, err := planRebase(commits, hash(tt.head), hash(tt.base)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("planRebase(%c, %c) error = %v; want %q", tt.head, tt.base, err, tt.want)
+		}
+	}
+}
+
+func TestCheckRebaseSteps(t *testing.T) {
+	step := func(action RebaseAction, message string) RebaseStep {
+		return RebaseStep{Action: action, Hash: "0123456789", Message: message}
+	}
+	tests := []struct {
+		steps []RebaseStep
+		ok    bool
+	}{
+		{[]RebaseStep{step(rebasePick, ""), step(rebaseSquash, ""), step(rebaseFixup, "")}, true},
+		{[]RebaseStep{step(rebaseDrop, ""), step(rebaseFixup, "")}, false},
+		{[]RebaseStep{step(rebaseEdit, ""), step(rebaseDrop, ""), step(rebaseSquash, "")}, true},
+		{[]RebaseStep{step(rebaseReword, "# comment only\n")}, false},
+		{[]RebaseStep{step(rebaseReword, "new subject")}, true},
+	}
+	for i, tt := range tests {
+		if err := checkRebaseSteps(tt.steps); (err == nil) != tt.ok {
+			t.Errorf("%d: checkRebaseSteps() error = %v", i, err)
+		}
+	}
+}
+
+func TestRebaseTodo(t *testing.T) {
+	steps := []RebaseStep{
+		{Action: rebaseFixup, Hash: "aaa", Subject: "fix"},
+		{Action: rebaseReword, Hash: "bbb", Subject: "old", Message: "it's new\n\nbody\n"},
+		{Action: rebaseDrop, Hash: "ccc", Subject: "gone"},
+	}
+	want := "fixup aaa fix\n" +
+		"pick bbb old\n" +
+		`exec printf '%s\n' 'it'\''s new' '' 'body' | git commit --amend --allow-empty --cleanup=strip --file=-` + "\n" +
+		"drop ccc gone\n"
+	if got := rebaseTodo(steps); got != want {
+		t.Errorf("rebaseTodo() =\n%s\nwant\n%s", got, want)
+	}
+}
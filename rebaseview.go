@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// リベースの手順を編集して実行するビュー
+// 手順は適用する順（古いコミットが上）に並べる
+type rebasePlanner struct {
+	*tview.Flex
+	list   *tview.TextView
+	editor *tview.TextArea // rewordの新しいメッセージの入力欄（編集中だけ表示する）
+	footer *tview.TextView // 操作の結果と使えるキーを表示する2行の領域
+	repo   Repository
+	queue  func(func())          // 別のゴルーチンの結果をUIのゴルーチンで処理する（app.QueueUpdateDraw）
+	focus  func(tview.Primitive) // 入力欄の開閉でフォーカスを移す（app.SetFocus）
+
+	base         string       // この後のコミットをリベースする
+	steps        []RebaseStep // 編集中の手順
+	current      int          // 選択中の行
+	scrollOffset int          // 先頭に表示している行
+	editing      bool         // rewordのメッセージを編集中かどうか
+	running      bool         // リベース中かどうか
+	generation   int          // 開き直すたびに増やし、古いメッセージの読み込み結果を捨てる
+	message      string       // 操作の結果
+
+	closeFunc  func()                         // ビューを閉じるときに呼ぶ関数
+	rebaseFunc func(output string, err error) // リベースを実行したときに呼ぶ関数
+}
+
+// リベースの手順の編集画面を作成
+func newRebasePlanner(repo Repository, queue func(func()), focus func(tview.Primitive)) *rebasePlanner {
+	p := &rebasePlanner{
+		list: tview.NewTextView().
+			SetDynamicColors(true).
+			SetScrollable(true).
+			SetWrap(false),
+		editor: tview.NewTextArea(),
+		footer: tview.NewTextView().
+			SetDynamicColors(true),
+		repo:  repo,
+		queue: queue,
+		focus: focus,
+	}
+	p.editor.SetBorder(true)
+	p.list.SetInputCapture(p.handleKey)
+	p.editor.SetInputCapture(p.handleEditorKey)
+	p.Flex = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(p.list, 0, 1, true).
+		AddItem(p.editor, 0, 0, false).
+		AddItem(p.footer, 2, 0, false)
+	return p
+}
+
+// ビューを閉じるときに呼ぶ関数を設定
+func (p *rebasePlanner) SetCloseFunc(handler func()) *rebasePlanner {
+	p.closeFunc = handler
+	return p
+}
+
+// リベースを実行したときに呼ぶ関数を設定（コマンドの出力とエラーを渡す）
+func (p *rebasePlanner) SetRebaseFunc(handler func(output string, err error)) *rebasePlanner {
+	p.rebaseFunc = handler
+	return p
+}
+
+// 手順を設定して編集を始める
+func (p *rebasePlanner) Open(base string, steps []RebaseStep) {
+	p.generation++
+	p.base = base
+	p.steps = steps
+	p.current, p.scrollOffset = 0, 0
+	p.message = ""
+	p.closeEditor()
+	p.list.SetTitle(fmt.Sprintf(" Rebase onto %s (applied from the top) ", base[:7]))
+	p.list.SetBorder(true)
+	p.render()
+}
+
+// 手順を描画する
+func (p *rebasePlanner) render() {
+	_, _, _, height := p.list.GetInnerRect()
+
+	var b strings.Builder
+	for i, step := range p.steps {
+		subject := step.Subject
+		if step.Action == rebaseReword && step.Message != "" {
+			subject, _, _ = strings.Cut(step.Message, "\n")
+		}
+		line := fmt.Sprintf("%-6s  %s  %s", step.Action, step.Hash[:7], subject)
+		switch {
+		case i == p.current:
+			fmt.Fprintf(&b, "[black:white]%s[-:-]\n", tview.Escape(line))
+		case step.Action == rebaseDrop:
+			fmt.Fprintf(&b, "[gray::s]%s[-::-]\n", tview.Escape(line))
+		case step.Action == rebaseReword || step.Action == rebaseEdit:
+			fmt.Fprintf(&b, "[yellow]%s[-]\n", tview.Escape(line))
+		case step.Action == rebaseSquash || step.Action == rebaseFixup:
+			fmt.Fprintf(&b, "[aqua]%s[-]\n", tview.Escape(line))
+		default:
+			fmt.Fprintf(&b, "%s\n", tview.Escape(line))
+		}
+	}
+	p.list.SetText(b.String())
+
+	// 選択中の行が画面に表示されるようにスクロールする
+	if p.current < p.scrollOffset {
+		p.scrollOffset = p.current
+	} else if height > 0 && p.current >= p.scrollOffset+height {
+		p.scrollOffset = p.current - height + 1
+	}
+	p.list.ScrollTo(p.scrollOffset, 0)
+
+	p.footer.Clear()
+	p.footer.Write([]byte(fmt.Sprintf("%d commits", len(p.steps))))
+	if p.message != "" {
+		p.footer.Write([]byte("  " + p.message))
+	}
+	if p.editing {
+		p.footer.Write([]byte("\n[gray]Ctrl-S: keep the new message  Esc: cancel[-]"))
+	} else {
+		p.footer.Write([]byte("\n[gray]p/r/e/s/f/d: pick/reword/edit/squash/fixup/drop  Shift-↑↓ or K/J: move  Enter: rebase  q: cancel[-]"))
+	}
+}
+
+// 選択中の手順を1つ上または下に移動する
+func (p *rebasePlanner) move(delta int) {
+	target := p.current + delta
+	if target < 0 || target >= len(p.steps) {
+		return
+	}
+	p.steps[p.current], p.steps[target] = p.steps[target], p.steps[p.current]
+	p.current = target
+}
+
+// 選択中のコミットのメッセージを書き換える入力欄を開く
+// まだ書き換えていなければコミットのメッセージ全文を読み込んで初期値にする
+func (p *rebasePlanner) openEditor() {
+	step := p.steps[p.current]
+	p.editing = true
+	p.editor.SetTitle(fmt.Sprintf(" New message for %s ", step.Hash[:7]))
+	p.ResizeItem(p.editor, 0, 1)
+	p.focus(p.editor)
+	if step.Message != "" {
+		p.editor.SetText(step.Message, false)
+		return
+	}
+
+	p.editor.SetText(step.Subject, false)
+	generation, hash := p.generation, step.Hash
+	go func() {
+		detail, err := p.repo.CommitDetail(hash)
+		p.queue(func() {
+			if generation != p.generation || !p.editing || p.editor.GetText() != step.Subject {
+				return
+			}
+			if err != nil {
+				p.message = fmt.Sprintf("[red]Failed to load the message: %s[-]", tview.Escape(formatMessage(err.Error())))
+			} else {
+				p.editor.SetText(detail.Message, false)
+			}
+			p.render()
+		})
+	}()
+}
+
+// メッセージの入力欄を閉じる
+func (p *rebasePlanner) closeEditor() {
+	p.editing = false
+	p.ResizeItem(p.editor, 0, 0)
+	p.focus(p.list)
+}
+
+// 手順を確認してリベースを実行する
+func (p *rebasePlanner) run() {
+	if err := checkRebaseSteps(p.steps); err != nil {
+		p.message = fmt.Sprintf("[red]%s[-]", tview.Escape(err.Error()))
+		return
+	}
+	p.running = true
+	p.message = "Rebasing…"
+	base, steps := p.base, append([]RebaseStep(nil), p.steps...)
+	go func() {
+		output, err := p.repo.Rebase(base, steps)
+		p.queue(func() {
+			p.running = false
+			p.message = ""
+			p.render()
+			if p.rebaseFunc != nil {
+				p.rebaseFunc(output, err)
+			}
+		})
+	}()
+}
+
+// 手順の一覧のキー入力のハンドリング
+func (p *rebasePlanner) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	if p.running {
+		return nil
+	}
+	p.message = ""
+	_, _, _, height := p.list.GetInnerRect()
+	actions := map[rune]RebaseAction{
+		'p': rebasePick, 'r': rebaseReword, 'e': rebaseEdit,
+		's': rebaseSquash, 'f': rebaseFixup, 'd': rebaseDrop,
+	}
+
+	switch event.Key() {
+	case tcell.KeyUp:
+		if event.Modifiers()&tcell.ModShift != 0 {
+			p.move(-1)
+		} else if p.current > 0 {
+			p.current--
+		}
+	case tcell.KeyDown:
+		if event.Modifiers()&tcell.ModShift != 0 {
+			p.move(1)
+		} else if p.current < len(p.steps)-1 {
+			p.current++
+		}
+	case tcell.KeyPgUp:
+		p.current = max(p.current-max(height-1, 1), 0)
+	case tcell.KeyPgDn:
+		p.current = max(min(p.current+max(height-1, 1), len(p.steps)-1), 0)
+	case tcell.KeyEnter:
+		p.run()
+	case tcell.KeyEscape:
+		if p.closeFunc != nil {
+			p.closeFunc()
+		}
+		return nil
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case 'K':
+			p.move(-1)
+		case 'J':
+			p.move(1)
+		case 'q':
+			if p.closeFunc != nil {
+				p.closeFunc()
+			}
+			return nil
+		default:
+			action, ok := actions[event.Rune()]
+			if !ok {
+				return event
+			}
+			p.steps[p.current].Action = action
+			if action == rebaseReword {
+				p.openEditor()
+			}
+		}
+	default:
+		return event
+	}
+	p.render()
+	return nil
+}
+
+// メッセージの入力欄のキー入力のハンドリング
+func (p *rebasePlanner) handleEditorKey(event *tcell.EventKey) *tcell.EventKey {
+	switch event.Key() {
+	case tcell.KeyCtrlS:
+		text := p.editor.GetText()
+		if cleanupMessage(text) == "" {
+			p.message = "[yellow]The message is empty[-]"
+			break
+		}
+		p.steps[p.current].Message = text
+		p.closeEditor()
+	case tcell.KeyEscape:
+		// 書き換えたメッセージがなければpickに戻す
+		if p.steps[p.current].Message == "" {
+			p.steps[p.current].Action = rebasePick
+		}
+		p.closeEditor()
+	default:
+		return event
+	}
+	p.render()
+	return nil
+}
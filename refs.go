@@ -0,0 +1,108 @@
+package main
+
+import "strings"
+
+// refの種類
+type RefKind int
+
+const (
+	RefLocalBranch  RefKind = iota // refs/heads/
+	RefRemoteBranch                // refs/remotes/
+	RefTag                         // refs/tags/
+)
+
+// ブランチやタグなどの参照
+type Ref struct {
+	Name     string  // 短い名前（main、origin/main、v1.0など）
+	FullName string  // 完全な名前（refs/heads/mainなど）
+	Kind     RefKind // refの種類
+	Hash     string  // 指しているコミット（注釈付きタグは展開したもの）
+	Upstream string  // ローカルブランチの上流（origin/mainなど、設定されていなければ空）
+}
+
+// 完全なref名から種類と短い名前を判定する
+// ブランチとタグ以外（refs/stashなど）やリモートのHEADはfalseを返す
+func classifyRef(fullName string) (RefKind, string, bool) {
+	switch {
+	case strings.HasPrefix(fullName, "refs/heads/"):
+		return RefLocalBranch, strings.TrimPrefix(fullName, "refs/heads/"), true
+	case strings.HasPrefix(fullName, "refs/remotes/"):
+		if strings.HasSuffix(fullName, "/HEAD") {
+			return 0, "", false
+		}
+		return RefRemoteBranch, strings.TrimPrefix(fullName, "refs/remotes/"), true
+	case strings.HasPrefix(fullName, "refs/tags/"):
+		return RefTag, strings.TrimPrefix(fullName, "refs/tags/"), true
+	}
+	return 0, "", false
+}
+
+// リモートブランチ（origin/featureなど）を追跡するローカルブランチの名前（リモート名を除いたもの）
+func trackingBranchName(remoteBranch string) string {
+	if _, name, ok := strings.Cut(remoteBranch, "/"); ok {
+		return name
+	}
+	return remoteBranch
+}
+
+// ある時点のrefをコミットハッシュから引けるようにまとめたもの
+// 作成後は変更しないので、複数のゴルーチンから参照できる
+type RefIndex struct {
+	Head       string           // HEADが指しているコミットのハッシュ
+	HeadBranch string           // HEADが指しているブランチ（detached HEADの場合は空）
+	byCommit   map[string][]Ref // コミットハッシュ -> そのコミットを指しているref
+}
+
+// リポジトリのrefを一度に読み込んでRefIndexを作る
+func loadRefIndex(repo Repository) (*RefIndex, error) {
+	refs, err := repo.Refs()
+	if err != nil {
+		return nil, err
+	}
+
+	index := &RefIndex{byCommit: make(map[string][]Ref)}
+	for _, ref := range refs {
+		index.byCommit[ref.Hash] = append(index.byCommit[ref.Hash], ref)
+	}
+
+	// HEADがなくても（コミットがない場合など）refの表示はできるようにする
+	index.Head, _ = repo.HeadCommitHash()
+	index.HeadBranch, _ = repo.CurrentBranchName()
+
+	return index, nil
+}
+
+// コミットを指しているrefを取得
+func (x *RefIndex) RefsAt(hash string) []Ref {
+	if x == nil {
+		return nil
+	}
+	return x.byCommit[hash]
+}
+
+// コミットを指している指定した種類のrefの名前を取得
+func (x *RefIndex) NamesAt(hash string, kind RefKind) []string {
+	var names []string
+	for _, ref := range x.RefsAt(hash) {
+		if ref.Kind == kind {
+			names = append(names, ref.Name)
+		}
+	}
+	return names
+}
+
+// 前のスナップショットと比べて、refが新しく指すようになったコミットと
+// どのrefにも指されなくなったコミットを返す
+func (x *RefIndex) ChangedTargets(old *RefIndex) (added, removed []string) {
+	for hash := range x.byCommit {
+		if _, ok := old.byCommit[hash]; !ok {
+			added = append(added, hash)
+		}
+	}
+	for hash := range old.byCommit {
+		if _, ok := x.byCommit[hash]; !ok {
+			removed = append(removed, hash)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestClassifyRef(t *testing.T) {
+	tests := []struct {
+		fullName string
+		kind     RefKind
+		name     string
+		ok       bool
+	}{
+		{"refs/heads/main", RefLocalBranch, "main", true},
+		{"refs/heads/feature/x", RefLocalBranch, "feature/x", true},
+		{"refs/remotes/origin/main", RefRemoteBranch, "origin/main", true},
+		{"refs/remotes/origin/HEAD", 0, "", false},
+		{"refs/tags/v1.0", RefTag, "v1.0", true},
+		{"refs/stash", 0, "", false},
+		{"HEAD", 0, "", false},
+	}
+
+	for _, test := range tests {
+		kind, name, ok := classifyRef(test.fullName)
+		if kind != test.kind || name != test.name || ok != test.ok {
+			t.Errorf("classifyRef(%q) = %v, %q, %v; want %v, %q, %v",
+				test.fullName, kind, name, ok, test.kind, test.name, test.ok)
+		}
+	}
+}
+
+func TestTrackingBranchName(t *testing.T) {
+	tests := map[string]string{
+		"origin/main":      "main",
+		"origin/feature/x": "feature/x",
+		"main":             "main",
+	}
+	for remote, want := range tests {
+		if got := trackingBranchName(remote); got != want {
+			t.Errorf("trackingBranchName(%q) = %q; want %q", remote, got, want)
+		}
+	}
+}
+
+func TestLoadRefIndex(t *testing.T) {
+	repo := &fakeRepository{
+		head:   "c2",
+		branch: "main",
+		refs: []Ref{
+			{Name: "main", FullName: "refs/heads/main", Kind: RefLocalBranch, Hash: "c2"},
+			{Name: "topic", FullName: "refs/heads/topic", Kind: RefLocalBranch, Hash: "c2"},
+			{Name: "origin/main", FullName: "refs/remotes/origin/main", Kind: RefRemoteBranch, Hash: "c1"},
+			{Name: "v1", FullName: "refs/tags/v1", Kind: RefTag, Hash: "c1"},
+		},
+	}
+	index, err := loadRefIndex(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if index.Head != "c2" || index.HeadBranch != "main" {
+		t.Errorf("Head = %q, HeadBranch = %q", index.Head, index.HeadBranch)
+	}
+	if got := index.NamesAt("c2", RefLocalBranch); !reflect.DeepEqual(got, []string{"main", "topic"}) {
+		t.Errorf("NamesAt(c2, local) = %v", got)
+	}
+	if got := index.NamesAt("c1", RefTag); !reflect.DeepEqual(got, []string{"v1"}) {
+		t.Errorf("NamesAt(c1, tag) = %v", got)
+	}
+	if got := index.NamesAt("c3", RefLocalBranch); got != nil {
+		t.Errorf("NamesAt(c3, local) = %v", got)
+	}
+
+	// mainがc3に移動し、c1を指すrefがなくなった場合
+	repo.refs = []Ref{
+		{Name: "main", FullName: "refs/heads/main", Kind: RefLocalBranch, Hash: "c3"},
+		{Name: "topic", FullName: "refs/heads/topic", Kind: RefLocalBranch, Hash: "c2"},
+	}
+	newIndex, err := loadRefIndex(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	added, removed := newIndex.ChangedTargets(index)
+	sort.Strings(added)
+	sort.Strings(removed)
+	if !reflect.DeepEqual(added, []string{"c3"}) || !reflect.DeepEqual(removed, []string{"c1"}) {
+		t.Errorf("ChangedTargets() = %v, %v", added, removed)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// pullで上流の変更を取り込む方法
+type PullMode int
+
+const (
+	pullFastForward PullMode = iota // 早送りできるときだけ取り込む（--ff-only）
+	pullMerge                       // マージする（--no-rebase）
+	pullRebase                      // 上流の上にリベースする（--rebase）
+)
+
+// 端末で行の残りを消す制御文字（gitが進捗を上書きするときに出力する）
+const clearLine = "\x1b[K"
+
+func (m PullMode) String() string {
+	switch m {
+	case pullMerge:
+		return "merge"
+	case pullRebase:
+		return "rebase"
+	}
+	return "ff-only"
+}
+
+// 環境変数CIT_PULLの値からpullの方法を決める（空ならff-only）
+func parsePullMode(value string) (PullMode, error) {
+	switch value {
+	case "", "ff-only":
+		return pullFastForward, nil
+	case "merge":
+		return pullMerge, nil
+	case "rebase":
+		return pullRebase, nil
+	}
+	return pullFastForward, fmt.Errorf("unknown pull mode %q (use ff-only, merge or rebase)", value)
+}
+
+// fetchやpushの出力を受け取り、進捗を1行ずつ渡すio.Writer
+// gitは進捗を\rで同じ行に上書きしながら出力するので、\rと\nのどちらでも区切る
+// \nで終わった行だけを出力として残し、上書きされる進捗の行は渡すだけにする
+// 上書きする前に行を消す制御文字（ESC [K）は取り除く
+type progressWriter struct {
+	progress func(line string) // 進捗の行を受け取る関数（nilなら渡さない）
+	partial  []byte            // まだ区切られていない出力
+	lines    []string          // 残す出力の行
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	for _, c := range p {
+		if c != '\r' && c != '\n' {
+			w.partial = append(w.partial, c)
+			continue
+		}
+		line := strings.TrimSpace(strings.ReplaceAll(string(w.partial), clearLine, ""))
+		w.partial = w.partial[:0]
+		if line == "" {
+			continue
+		}
+		if c == '\n' {
+			w.lines = append(w.lines, line)
+		}
+		if w.progress != nil {
+			w.progress(line)
+		}
+	}
+	return len(p), nil
+}
+
+// 残した出力（区切られていない最後の行も含む）
+func (w *progressWriter) String() string {
+	lines := w.lines
+	if line := strings.TrimSpace(strings.ReplaceAll(string(w.partial), clearLine, "")); line != "" {
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// 結果として表示する出力の最後の行（助言の行は除く）
+func lastOutputLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if line := strings.TrimSpace(lines[i]); line != "" && !strings.HasPrefix(line, "hint:") {
+			return line
+		}
+	}
+	return "done"
+}
+
+// 失敗したgitコマンド（fetch、pull、push、rebaseなど）の出力からエラーを作る
+// 進捗や助言の行は除き、エラーを表す行があればそれだけにする
+func commandError(output string, err error) error {
+	var errorLines, otherLines []string
+	for _, line := range strings.Split(output, "\n") {
+		switch {
+		case strings.HasPrefix(line, "fatal:"), strings.HasPrefix(line, "error:"), strings.HasPrefix(line, "! "):
+			errorLines = append(errorLines, line)
+		case line != "" && !strings.HasPrefix(line, "hint:"):
+			otherLines = append(otherLines, line)
+		}
+	}
+	if len(errorLines) > 0 {
+		return errors.New(strings.Join(errorLines, "\n"))
+	}
+	if len(otherLines) > 0 {
+		return errors.New(strings.Join(otherLines, "\n"))
+	}
+	return err
+}
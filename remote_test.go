@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePullMode(t *testing.T) {
+	tests := []struct {
+		value string
+		want  PullMode
+		ok    bool
+	}{
+		{"", pullFastForward, true},
+		{"ff-only", pullFastForward, true},
+		{"merge", pullMerge, true},
+		{"rebase", pullRebase, true},
+		{"squash", pullFastForward, false},
+	}
+	for _, tt := range tests {
+		got, err := parsePullMode(tt.value)
+		if got != tt.want || (err == nil) != tt.ok {
+			t.Errorf("parsePullMode(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestProgressWriter(t *testing.T) {
+	var progress []string
+	w := &progressWriter{progress: func(line string) { progress = append(progress, line) }}
+	// gitは進捗を\rで上書きし、書き込みは行の途中で分かれることもある
+	for _, chunk := range []string{"Fetching origin\n", "Receiving objects:  50% (1/2)\r", "\x1b[KReceiving objects: 100% (2/2), done.\n", "From /tmp/re", "mote\n * branch"} {
+		w.Write([]byte(chunk))
+	}
+
+	wantProgress := []string{"Fetching origin", "Receiving objects:  50% (1/2)", "Receiving objects: 100% (2/2), done.", "From /tmp/remote"}
+	if !reflect.DeepEqual(progress, wantProgress) {
+		t.Errorf("progress = %q; want %q", progress, wantProgress)
+	}
+	want := "Fetching origin\nReceiving objects: 100% (2/2), done.\nFrom /tmp/remote\n* branch"
+	if got := w.String(); got != want {
+		t.Errorf("String() = %q; want %q", got, want)
+	}
+}
+
+func TestCommandError(t *testing.T) {
+	exitErr := errors.New("exit status 1")
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"To /tmp/remote\n! [rejected]        master -> master (fetch first)\nerror: failed to push some refs to '/tmp/remote'\nhint: Updates were rejected",
+			"! [rejected]        master -> master (fetch first)\nerror: failed to push some refs to '/tmp/remote'"},
+		{"hint: Diverging branches can't be fast-forwarded\nfatal: Not possible to fast-forward, aborting.",
+			"fatal: Not possible to fast-forward, aborting."},
+		{"Could not resolve host", "Could not resolve host"},
+		{"", "exit status 1"},
+	}
+	for _, tt := range tests {
+		if got := commandError(tt.output, exitErr).Error(); got != tt.want {
+			t.Errorf("commandError(%q) = %q; want %q", tt.output, got, tt.want)
+		}
+	}
+}
+
+func TestLastOutputLine(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"To /tmp/remote\n   1234567..89abcde  master -> master\n", "1234567..89abcde  master -> master"},
+		{"Already up to date.\nhint: something\n", "Already up to date."},
+		{"", "done"},
+	}
+	for _, tt := range tests {
+		if got := lastOutputLine(tt.output); got != tt.want {
+			t.Errorf("lastOutputLine(%q) = %q; want %q", tt.output, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// Gitリポジトリへのアクセスを抽象化するインターフェース
+// UIはこのインターフェースだけを通してGitを操作する
+// 読み込みは別のゴルーチンで行うので、複数のゴルーチンから同時に呼び出せるようにする
+type Repository interface {
+	// 現在のHEADのコミットハッシュを取得
+	HeadCommitHash() (string, error)
+
+	// 現在のHEADが指しているブランチ名を取得（detached HEADの場合はfalse）
+	CurrentBranchName() (string, bool)
+
+	// すべてのローカルブランチ、リモートブランチ、タグを取得
+	Refs() ([]Ref, error)
+
+	// ローカルブランチとリモートブランチを、上流との差や先頭のコミットの情報と一緒に取得
+	Branches() ([]BranchInfo, error)
+
+	// 未コミットの変更があるか確認
+	HasUncommittedChanges() bool
+
+	// 未コミットの変更の概要を取得
+	UncommittedChangesSummary() (string, error)
+
+	// 未コミットのファイルの状態を取得
+	Status() ([]FileStatus, error)
+
+	// ファイルの変更をインデックスに追加する（削除したファイルは削除を追加する）
+	StageFile(file FileStatus) error
+
+	// インデックスに追加した変更を取り消す（作業ツリーの変更は残す）
+	UnstageFile(file FileStatus) error
+
+	// 作業ツリーの変更を捨ててインデックスの内容に戻す（追跡していないファイルは削除する）
+	DiscardFile(file FileStatus) error
+
+	// ファイルの差分をunified形式で取得（stagedならHEADとインデックス、そうでなければインデックスと作業ツリーの差分）
+	FileDiff(file FileStatus, staged bool) (string, error)
+
+	// unified形式のパッチを適用する（cachedならインデックス、そうでなければ作業ツリーに適用する）
+	ApplyPatch(patch string, cached, reverse bool) error
+
+	// コミットメッセージのひな形（commit.templateのファイルの内容、設定がなければ空）を取得
+	CommitTemplate() (string, error)
+
+	// HEADのコミットメッセージを取得（amendするときの初期値にする）
+	HeadCommitMessage() (string, error)
+
+	// インデックスの内容をコミットする（amendなら直前のコミットを作り直す）
+	// フックの出力を含むコマンドの出力を返す
+	Commit(message string, amend bool) (string, error)
+
+	// 設定されているユーザー名を取得
+	UserName() string
+
+	// コミットログを新しい順に少しずつ読み込む（条件が空ならすべてのブランチ）
+	Log(filter LogFilter) (LogReader, error)
+
+	// コミットの詳細情報（メッセージ全文、親、ref、変更ファイル）を取得
+	CommitDetail(hash string) (CommitDetail, error)
+
+	// タグの情報を取得
+	TagDetail(name string) (TagDetail, error)
+
+	// コミットの差分をunified形式で取得（マージコミットは最初の親との差分）
+	CommitDiff(hash string) (string, error)
+
+	// ブランチに切り替える
+	SwitchBranch(branchName string) (string, error)
+
+	// コミットをハッシュ値でチェックアウトする（detached HEAD）
+	CheckoutDetached(hash string) (string, error)
+
+	// リモートブランチを追跡するローカルブランチを作成して切り替える
+	SwitchTrackingBranch(name, remoteBranch string) (string, error)
+
+	// コミットを指すブランチを作成する
+	CreateBranch(name, hash string) error
+
+	// ブランチの名前を変える（上流の設定も引き継ぐ）
+	RenameBranch(oldName, newName string) error
+
+	// コミットの変更を現在のHEADに適用する（マージコミットは最初の親との差分を適用する）
+	CherryPick(hash string) (string, error)
+
+	// コミットの変更を打ち消すコミットを作成する（マージコミットは最初の親に戻す）
+	Revert(hash string) (string, error)
+
+	// 現在のブランチ（detached HEADならHEAD）をコミットに移動する
+	Reset(hash string, mode ResetMode) (string, error)
+
+	// sourceを現在のHEADにマージしたときに取り込まれるコミットの数と、早送りできるかどうかを取得
+	MergePreview(source string) (MergePreview, error)
+
+	// sourceを現在のHEADにマージする（messageはマージコミットかsquashしたコミットのメッセージ）
+	// 競合で止まった場合も、そこまでの出力を返す
+	Merge(source string, mode MergeMode, message string) (string, error)
+
+	// 途中で止まっているマージやリベースなどの操作と、衝突が解決されていないファイルを取得
+	Operation() (Operation, error)
+
+	// 途中で止まっている操作を続ける、中止する、または今のコミットを飛ばす
+	FinishOperation(kind OperationKind, action OperationAction) (string, error)
+
+	// baseより後のコミットを手順のとおりにリベースする
+	// editの手順や競合で止まった場合も、そこまでの出力を返す
+	Rebase(base string, steps []RebaseStep) (string, error)
+
+	// ブランチを削除する
+	// forceでなければ、HEADにマージされていないブランチは削除せずにerrBranchNotMergedを返す
+	DeleteBranch(name string, force bool) error
+
+	// ブランチの上流を設定する（upstreamが空なら設定を外す）
+	SetUpstream(branch, upstream string) error
+
+	// コミットにタグを作成する（messageが空なら軽量タグ、そうでなければ注釈付きタグ）
+	CreateTag(name, hash, message string) error
+
+	// タグを削除する
+	DeleteTag(name string) error
+
+	// スタッシュを新しい順に取得
+	Stashes() ([]Stash, error)
+
+	// 未コミットの変更をスタッシュする（messageが空なら既定のメッセージ、includeUntrackedなら追跡していないファイルも含む）
+	StashChanges(message string, includeUntracked bool) (string, error)
+
+	// スタッシュした変更を作業ツリーに適用する（popなら適用できたスタッシュを削除する）
+	ApplyStash(name string, pop bool) (string, error)
+
+	// スタッシュを削除する
+	DropStash(name string) (string, error)
+
+	// スタッシュしたときのコミットからブランチを作成して切り替え、スタッシュを適用して削除する
+	StashBranch(name, branch string) (string, error)
+
+	// すべてのリモートからfetchする（削除されたリモートブランチも消す）
+	// 進捗の行をprogressに渡し、コマンドの出力を返す
+	Fetch(progress func(line string)) (string, error)
+
+	// 現在のブランチに上流の変更を取り込む
+	Pull(mode PullMode, progress func(line string)) (string, error)
+
+	// 現在のブランチを上流にpushする（forceならリモートが取得したときのままの場合だけ上書きする）
+	Push(force bool, progress func(line string)) (string, error)
+}
+
+// マージされていないブランチを削除しようとしたときのエラー
+var errBranchNotMerged = errors.New("the branch is not fully merged")
+
+// resetでインデックスと作業ツリーをどこまで戻すか
+type ResetMode int
+
+const (
+	resetSoft  ResetMode = iota // HEADだけを移動する
+	resetMixed                  // インデックスも戻す（作業ツリーの変更は残す）
+	resetHard                   // 作業ツリーの変更も捨てる
+)
+
+func (m ResetMode) String() string {
+	switch m {
+	case resetSoft:
+		return "soft"
+	case resetHard:
+		return "hard"
+	}
+	return "mixed"
+}
+
+// コミットログを少しずつ読み込むためのインターフェース
+type LogReader interface {
+	// 最大n件のコミットを読み込む
+	// 最後まで読み込んだときは、残りのコミットとio.EOFを返す
+	Next(n int) ([]Commit, error)
+
+	// 読み込みを中止して後始末をする
+	Close() error
+}
+
+// リポジトリを開く
+// 環境変数CIT_BACKENDで実装を選択できる（"exec"または"go"）
+// 指定がない場合、gitコマンドが見つかればそれを使い、なければgo-gitで直接読む
+func openRepository(dir string) (Repository, error) {
+	switch os.Getenv("CIT_BACKEND") {
+	case "exec":
+		return newExecRepository(dir), nil
+	case "go":
+		return newGoGitRepository(dir)
+	}
+
+	if _, err := exec.LookPath("git"); err == nil {
+		return newExecRepository(dir), nil
+	}
+	return newGoGitRepository(dir)
+}
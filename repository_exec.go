@@ -0,0 +1,838 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// gitコマンドを実行してリポジトリを操作するRepositoryの実装
+type execRepository struct {
+	dir string // gitコマンドを実行するディレクトリ
+}
+
+// gitコマンドを使うRepositoryを作成
+func newExecRepository(dir string) *execRepository {
+	return &execRepository{dir: dir}
+}
+
+// gitコマンドを作成
+func (r *execRepository) command(args ...string) *exec.Cmd {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.dir
+	// git statusがインデックスを書き換えると、それを変更として検出して読み込み直し続けてしまう
+	cmd.Env = append(os.Environ(), "GIT_OPTIONAL_LOCKS=0")
+	return cmd
+}
+
+// gitコマンドを実行して標準出力を返す
+// 失敗した場合は標準エラー出力の内容をエラーにする
+func (r *execRepository) output(args ...string) ([]byte, error) {
+	output, err := r.command(args...).Output()
+	if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+		err = fmt.Errorf("%s", strings.TrimSpace(string(exitErr.Stderr)))
+	}
+	return output, err
+}
+
+// gitコマンドを実行して標準出力と標準エラー出力をまとめて返す
+// 失敗した場合は出力の内容をエラーにする（チェックアウトできない理由などが出力される）
+func (r *execRepository) combinedOutput(args ...string) (string, error) {
+	output, err := r.command(args...).CombinedOutput()
+	if _, ok := err.(*exec.ExitError); ok && len(bytes.TrimSpace(output)) > 0 {
+		err = fmt.Errorf("%s", strings.TrimSpace(string(output)))
+	}
+	return string(output), err
+}
+
+// fetchなどのリモートと通信するgitコマンドを実行し、進捗を1行ずつprogressに渡す
+// 認証を求められても入力できないので、プロンプトを出さずに失敗させる
+func (r *execRepository) streamOutput(progress func(line string), args ...string) (string, error) {
+	cmd := r.command(args...)
+	cmd.Env = append(cmd.Env, "GIT_TERMINAL_PROMPT=0")
+	return runWithProgress(cmd, progress)
+}
+
+// 進捗を\rで上書きしながら出力するコマンドを実行し、出力を1行ずつに分けて返す
+// 進捗の行はprogressに渡す（nilなら渡さない）
+func runWithProgress(cmd *exec.Cmd, progress func(line string)) (string, error) {
+	writer := &progressWriter{progress: progress}
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+	err := cmd.Run()
+	output := writer.String()
+	if _, ok := err.(*exec.ExitError); ok {
+		err = commandError(output, err)
+	}
+	return output, err
+}
+
+// 現在のHEADのコミットハッシュを取得
+func (r *execRepository) HeadCommitHash() (string, error) {
+	output, err := r.output("rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(output)), nil
+}
+
+// 現在のHEADが指しているブランチ名を取得する
+func (r *execRepository) CurrentBranchName() (string, bool) {
+	// git symbolic-ref --short HEAD でブランチ名を取得
+	output, err := r.output("symbolic-ref", "--short", "HEAD")
+
+	// エラーの場合はdetached HEAD状態
+	if err != nil {
+		return "", false
+	}
+
+	// ブランチ名を返す
+	return strings.TrimSpace(string(output)), true
+}
+
+// すべてのブランチとタグを一度に取得
+func (r *execRepository) Refs() ([]Ref, error) {
+	// 注釈付きタグは%(*objectname)に指しているコミットが入る
+	output, err := r.output("for-each-ref", "--format=%(objectname)%00%(*objectname)%00%(refname)%00%(upstream:short)")
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []Ref
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.Split(line, "\x00")
+		if len(parts) != 4 {
+			continue
+		}
+		kind, name, ok := classifyRef(parts[2])
+		if !ok {
+			continue
+		}
+		hash := parts[0]
+		if parts[1] != "" {
+			hash = parts[1]
+		}
+		refs = append(refs, Ref{Name: name, FullName: parts[2], Kind: kind, Hash: hash, Upstream: parts[3]})
+	}
+
+	return refs, nil
+}
+
+// ブランチの一覧を取得
+func (r *execRepository) Branches() ([]BranchInfo, error) {
+	output, err := r.output("for-each-ref",
+		"--format=%(refname)%00%(objectname)%00%(upstream:short)%00%(upstream:track,nobracket)%00%(committerdate:unix)%00%(contents:subject)",
+		"refs/heads", "refs/remotes")
+	if err != nil {
+		return nil, err
+	}
+
+	// HEADにマージされているブランチ（コミットがなければ空）
+	merged := map[string]bool{}
+	if output, err := r.output("for-each-ref", "--merged=HEAD", "--format=%(refname)", "refs/heads", "refs/remotes"); err == nil {
+		for _, name := range strings.Fields(string(output)) {
+			merged[name] = true
+		}
+	}
+
+	var branches []BranchInfo
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.Split(line, "\x00")
+		if len(parts) != 6 {
+			continue
+		}
+		kind, name, ok := classifyRef(parts[0])
+		if !ok {
+			continue
+		}
+		branch := BranchInfo{
+			Ref:     Ref{Name: name, FullName: parts[0], Kind: kind, Hash: parts[1], Upstream: parts[2]},
+			Subject: parts[5],
+			Merged:  merged[parts[0]],
+		}
+		branch.Ahead, branch.Behind, branch.UpstreamGone = parseUpstreamTrack(parts[3])
+		if unix, err := strconv.ParseInt(parts[4], 10, 64); err == nil {
+			branch.Date = time.Unix(unix, 0)
+		}
+		branches = append(branches, branch)
+	}
+	return branches, nil
+}
+
+// 未コミットの変更があるか確認
+func (r *execRepository) HasUncommittedChanges() bool {
+	// git status --porcelain で未コミットの変更を確認
+	output, err := r.output("status", "--porcelain")
+
+	// エラーまたは出力が空の場合は未コミットの変更なし
+	if err != nil || len(output) == 0 {
+		return false
+	}
+
+	return true
+}
+
+// 未コミットの変更の概要を取得
+func (r *execRepository) UncommittedChangesSummary() (string, error) {
+	// 変更されたファイルの数を取得
+	statusOutput, err := r.output("status", "--porcelain")
+	if err != nil {
+		return "", err
+	}
+
+	// 行ごとに分割して数をカウント
+	status := strings.TrimSpace(string(statusOutput))
+	if status == "" {
+		return "", nil // 変更なし
+	}
+	numChanges := len(strings.Split(status, "\n"))
+
+	return fmt.Sprintf("%d files changed", numChanges), nil
+}
+
+// 未コミットのファイルの状態を取得
+func (r *execRepository) Status() ([]FileStatus, error) {
+	// ファイルごとにステージングできるように、追跡していないディレクトリの中も1ファイルずつ取得する
+	output, err := r.output("status", "--porcelain=v2", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+	return parseStatusV2(string(output)), nil
+}
+
+// 名前を変更したファイルは元の名前も対象にする
+func statusPaths(file FileStatus) []string {
+	paths := []string{"--", file.Path}
+	if file.OrigPath != "" {
+		paths = append(paths, file.OrigPath)
+	}
+	return paths
+}
+
+// ファイルの変更をインデックスに追加する
+func (r *execRepository) StageFile(file FileStatus) error {
+	// -Aで削除したファイルも削除としてインデックスに追加する
+	_, err := r.output(append([]string{"add", "-A"}, statusPaths(file)...)...)
+	return err
+}
+
+// インデックスに追加した変更を取り消す
+func (r *execRepository) UnstageFile(file FileStatus) error {
+	// git restore --stagedと違い、最初のコミットの前でも使える
+	_, err := r.output(append([]string{"reset", "-q"}, statusPaths(file)...)...)
+	return err
+}
+
+// 作業ツリーの変更を捨てる
+func (r *execRepository) DiscardFile(file FileStatus) error {
+	if file.Untracked {
+		return os.Remove(filepath.Join(r.dir, file.Path))
+	}
+	_, err := r.output("restore", "--worktree", "--", file.Path)
+	return err
+}
+
+// ファイルの差分を取得
+func (r *execRepository) FileDiff(file FileStatus, staged bool) (string, error) {
+	var args []string
+	switch {
+	case file.Untracked:
+		// 追跡していないファイルは空のファイルとの差分にする
+		args = []string{"diff", "--no-color", "--no-index", "--", os.DevNull, file.Path}
+	case staged:
+		args = append([]string{"diff", "--no-color", "--cached", "-M"}, statusPaths(file)...)
+	default:
+		args = []string{"diff", "--no-color", "--", file.Path}
+	}
+	output, err := r.output(args...)
+	if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+		// --no-indexは差分があると終了コード1になる
+		err = nil
+	}
+	return string(output), err
+}
+
+// パッチを適用する
+func (r *execRepository) ApplyPatch(patch string, cached, reverse bool) error {
+	args := []string{"apply", "--whitespace=nowarn"}
+	if cached {
+		args = append(args, "--cached")
+	}
+	if reverse {
+		args = append(args, "--reverse")
+	}
+	cmd := r.command(append(args, "-")...)
+	cmd.Stdin = strings.NewReader(patch)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		if message := strings.TrimSpace(string(output)); message != "" {
+			return fmt.Errorf("%s", message)
+		}
+		return err
+	}
+	return nil
+}
+
+// コミットメッセージのひな形を取得
+func (r *execRepository) CommitTemplate() (string, error) {
+	// 設定されていなければ終了コード1になる
+	output, err := r.output("config", "--path", "commit.template")
+	path := strings.TrimSpace(string(output))
+	if err != nil || path == "" {
+		return "", nil
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(r.dir, path)
+	}
+	content, err := os.ReadFile(path)
+	return string(content), err
+}
+
+// HEADのコミットメッセージを取得
+func (r *execRepository) HeadCommitMessage() (string, error) {
+	output, err := r.output("log", "-1", "--format=%B", "HEAD")
+	return strings.TrimRight(string(output), "\n") + "\n", err
+}
+
+// インデックスの内容をコミットする
+func (r *execRepository) Commit(message string, amend bool) (string, error) {
+	// ひな形のコメント行はエディタで書いたときと同じように取り除く
+	args := []string{"commit", "--cleanup=strip", "--file=-"}
+	if amend {
+		args = append(args, "--amend")
+	}
+	cmd := r.command(args...)
+	cmd.Stdin = strings.NewReader(message)
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+// 設定されているユーザー名を取得
+func (r *execRepository) UserName() string {
+	output, _ := r.output("config", "user.name")
+	return strings.TrimSpace(string(output))
+}
+
+// git logの出力をパイプから少しずつ読み込むLogReader
+type execLogReader struct {
+	cmd      *exec.Cmd
+	scanner  *bufio.Scanner
+	stderr   bytes.Buffer
+	finished bool            // gitコマンドの終了を待ったかどうか
+	shown    map[string]bool // 表示するコミット（nilならすべての親を表示する）
+}
+
+// git logの出力の1行をコミットに変換する
+func parseLogLine(line string) (Commit, bool) {
+	parts := strings.SplitN(line, "|", 5)
+	if len(parts) != 5 {
+		return Commit{}, false
+	}
+	return Commit{
+		Hash:    parts[0],
+		Parents: strings.Fields(parts[1]),
+		Author:  parts[2],
+		Date:    formatDate(parts[3]),
+		Message: formatMessage(parts[4]),
+	}, true
+}
+
+// 最大n件のコミットを読み込む
+func (l *execLogReader) Next(n int) ([]Commit, error) {
+	var commits []Commit
+	for len(commits) < n && l.scanner.Scan() {
+		if commit, ok := parseLogLine(l.scanner.Text()); ok {
+			if l.shown != nil {
+				commit.Parents = shownParents(commit.Parents, l.shown)
+			}
+			commits = append(commits, commit)
+		}
+	}
+	if len(commits) == n {
+		return commits, nil
+	}
+
+	// 出力の終わりに達したらgitコマンドの終了を待つ
+	if err := l.scanner.Err(); err != nil {
+		return commits, err
+	}
+	l.finished = true
+	if err := l.cmd.Wait(); err != nil {
+		if message := strings.TrimSpace(l.stderr.String()); message != "" {
+			return commits, fmt.Errorf("%s", message)
+		}
+		return commits, err
+	}
+	return commits, io.EOF
+}
+
+// 読み込みを中止する（gitコマンドが実行中なら終了させる）
+func (l *execLogReader) Close() error {
+	if l.finished {
+		return nil
+	}
+	l.finished = true
+	l.cmd.Process.Kill()
+	l.cmd.Wait()
+	return nil
+}
+
+// git logとgit rev-listに渡す、表示するコミットを選ぶ引数
+func logRevisionArgs(filter LogFilter) []string {
+	var args []string
+	if filter.Ref == "" {
+		// スタッシュのコミットは一覧に表示しない
+		args = append(args, "--exclude="+stashRef, "--all")
+	}
+	if filter.Author != "" {
+		args = append(args, "--author="+filter.Author)
+	}
+	if filter.Since != "" {
+		args = append(args, "--since="+filter.Since)
+	}
+	if filter.Until != "" {
+		args = append(args, "--until="+filter.Until)
+	}
+	if filter.Ref != "" {
+		// "-"で始まるrefがオプションとして解釈されないように、オプションの後に置く
+		args = append(args, "--end-of-options", filter.Ref)
+	}
+	if filter.Path != "" {
+		args = append(args, "--", filter.Path)
+	}
+	return args
+}
+
+// コミットログの読み込みを開始
+func (r *execRepository) Log(filter LogFilter) (LogReader, error) {
+	// 日時をGitの標準形式で取得
+	// グラフを描けるように、親より先に子が並ぶ順序（--date-order）で取得する
+	args := []string{"log", "--date-order", "--pretty=format:%H|%P|%an|%ad|%s"}
+	if filter.Path != "" {
+		// 親をパスに関係するコミットに書き換えてグラフがつながるようにする
+		args = append(args, "--parents")
+	}
+	args = append(args, logRevisionArgs(filter)...)
+
+	reader := &execLogReader{cmd: r.command(args...)}
+	if filter.HidesParents() {
+		// 作者や日時の条件では親が書き換えられないので、表示されない親にグラフの線を
+		// 伸ばさないように、表示するコミットを先にgit rev-listでまとめて取得しておく
+		output, err := r.output(append([]string{"rev-list"}, logRevisionArgs(filter)...)...)
+		if err != nil {
+			return nil, err
+		}
+		reader.shown = make(map[string]bool)
+		for _, hash := range strings.Fields(string(output)) {
+			reader.shown[hash] = true
+		}
+	}
+
+	// 出力をすべて待たずに、パイプから読めた分だけ処理する
+	reader.cmd.Stderr = &reader.stderr
+	stdout, err := reader.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := reader.cmd.Start(); err != nil {
+		return nil, err
+	}
+	reader.scanner = bufio.NewScanner(stdout)
+	reader.scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // 長い件名にも対応
+
+	return reader, nil
+}
+
+// コミットの詳細情報を取得
+func (r *execRepository) CommitDetail(hash string) (CommitDetail, error) {
+	// 各項目をNUL文字で区切って取得
+	output, err := r.output("show", "-s", "--format=%H%x00%P%x00%D%x00%an%x00%ae%x00%ad%x00%cn%x00%ce%x00%cd%x00%B", hash)
+	if err != nil {
+		return CommitDetail{}, err
+	}
+	fields := strings.SplitN(string(output), "\x00", 10)
+	if len(fields) != 10 {
+		return CommitDetail{}, fmt.Errorf("unexpected git show output for %s", hash)
+	}
+
+	detail := CommitDetail{
+		Hash:           fields[0],
+		Parents:        strings.Fields(fields[1]),
+		Author:         fields[3],
+		AuthorEmail:    fields[4],
+		AuthorDate:     formatDate(fields[5]),
+		Committer:      fields[6],
+		CommitterEmail: fields[7],
+		CommitterDate:  formatDate(fields[8]),
+		Message:        fields[9],
+	}
+	if fields[2] != "" {
+		detail.Refs = strings.Split(fields[2], ", ")
+	}
+
+	// 変更されたファイルの行数を取得（マージコミットは最初の親との差分）
+	output, err = r.output("show", "--numstat", "--format=", "--diff-merges=first-parent", hash)
+	if err != nil {
+		return CommitDetail{}, err
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		parts := strings.SplitN(line, "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		file := FileStat{Path: parts[2]}
+		if parts[0] == "-" {
+			// バイナリファイルは行数が"-"になる
+			file.Binary = true
+		} else {
+			fmt.Sscan(parts[0], &file.Additions)
+			fmt.Sscan(parts[1], &file.Deletions)
+		}
+		detail.Files = append(detail.Files, file)
+	}
+
+	return detail, nil
+}
+
+// タグの情報を取得
+func (r *execRepository) TagDetail(name string) (TagDetail, error) {
+	output, err := r.output("for-each-ref",
+		"--format=%(objecttype)%00%(taggername)%00%(taggeremail)%00%(taggerdate:format:%Y-%m-%d %H:%M:%S)%00%(contents)",
+		"refs/tags/"+name)
+	if err != nil {
+		return TagDetail{}, err
+	}
+	fields := strings.SplitN(string(output), "\x00", 5)
+	if len(fields) != 5 {
+		return TagDetail{}, fmt.Errorf("tag '%s' not found", name)
+	}
+	tag := TagDetail{Name: name, Annotated: fields[0] == "tag"}
+	if tag.Annotated {
+		tag.Tagger = fields[1]
+		tag.TaggerEmail = strings.Trim(fields[2], "<>")
+		tag.TaggerDate = fields[3]
+		// for-each-refは各refの後に改行を出力する
+		tag.Message = strings.TrimSuffix(fields[4], "\n")
+	}
+	return tag, nil
+}
+
+// コミットの差分を取得
+func (r *execRepository) CommitDiff(hash string) (string, error) {
+	output, err := r.output("show", "--format=", "--patch", "--no-color", "--diff-merges=first-parent", hash)
+	if err != nil {
+		return "", err
+	}
+	return string(output), nil
+}
+
+// ブランチに切り替える
+func (r *execRepository) SwitchBranch(branchName string) (string, error) {
+	return r.combinedOutput("switch", branchName)
+}
+
+// コミットをハッシュ値でチェックアウトする（detached HEAD）
+func (r *execRepository) CheckoutDetached(hash string) (string, error) {
+	return r.combinedOutput("checkout", hash)
+}
+
+// リモートブランチを追跡するローカルブランチを作成して切り替える
+func (r *execRepository) SwitchTrackingBranch(name, remoteBranch string) (string, error) {
+	return r.combinedOutput("switch", "--track", "-c", name, "--end-of-options", "refs/remotes/"+remoteBranch)
+}
+
+// コミットを指すブランチを作成する
+func (r *execRepository) CreateBranch(name, hash string) error {
+	_, err := r.output("branch", "--end-of-options", name, hash)
+	return err
+}
+
+// ブランチの名前を変える
+func (r *execRepository) RenameBranch(oldName, newName string) error {
+	_, err := r.output("branch", "-m", "--end-of-options", oldName, newName)
+	return err
+}
+
+// ブランチを削除する
+func (r *execRepository) DeleteBranch(name string, force bool) error {
+	if !force {
+		// git branch -dは上流にマージされていれば削除するが、どちらの実装でも同じになるようにHEADだけを見る
+		_, err := r.output("merge-base", "--is-ancestor", "refs/heads/"+name, "HEAD")
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+			return errBranchNotMerged
+		} else if err != nil {
+			return err
+		}
+	}
+	_, err := r.output("branch", "-D", "--end-of-options", name)
+	return err
+}
+
+// ブランチの上流を設定する
+func (r *execRepository) SetUpstream(branch, upstream string) error {
+	var err error
+	if upstream == "" {
+		_, err = r.output("branch", "--unset-upstream", "--end-of-options", branch)
+	} else {
+		_, err = r.output("branch", "--set-upstream-to="+upstream, "--end-of-options", branch)
+	}
+	return err
+}
+
+// コミットにタグを作成する
+func (r *execRepository) CreateTag(name, hash, message string) error {
+	args := []string{"tag"}
+	if message != "" {
+		args = append(args, "-a", "-m", message)
+	}
+	_, err := r.output(append(args, "--end-of-options", name, hash)...)
+	return err
+}
+
+// タグを削除する
+func (r *execRepository) DeleteTag(name string) error {
+	_, err := r.output("tag", "-d", "--end-of-options", name)
+	return err
+}
+
+// スタッシュを新しい順に取得
+func (r *execRepository) Stashes() ([]Stash, error) {
+	output, err := r.output("stash", "list", "--format=%H%x00%P%x00%gd%x00%gs%x00%ct")
+	if err != nil {
+		return nil, err
+	}
+	var stashes []Stash
+	for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+		fields := strings.Split(line, "\x00")
+		if len(fields) != 5 {
+			continue
+		}
+		parents := strings.Fields(fields[1])
+		stash := Stash{Name: fields[2], Hash: fields[0], Untracked: len(parents) > 2}
+		if len(parents) > 0 {
+			stash.Base = parents[0]
+		}
+		stash.Branch, stash.Message = parseStashSubject(fields[3])
+		if seconds, err := strconv.ParseInt(fields[4], 10, 64); err == nil {
+			stash.Date = time.Unix(seconds, 0)
+		}
+		stashes = append(stashes, stash)
+	}
+	return stashes, nil
+}
+
+// 未コミットの変更をスタッシュする
+func (r *execRepository) StashChanges(message string, includeUntracked bool) (string, error) {
+	args := []string{"stash", "push"}
+	if includeUntracked {
+		args = append(args, "--include-untracked")
+	}
+	if message != "" {
+		args = append(args, "--message="+message)
+	}
+	return r.combinedOutput(args...)
+}
+
+// スタッシュした変更を作業ツリーに適用する
+func (r *execRepository) ApplyStash(name string, pop bool) (string, error) {
+	action := "apply"
+	if pop {
+		action = "pop"
+	}
+	return r.combinedOutput("stash", action, name)
+}
+
+// スタッシュを削除する
+func (r *execRepository) DropStash(name string) (string, error) {
+	return r.combinedOutput("stash", "drop", name)
+}
+
+// スタッシュからブランチを作成する
+func (r *execRepository) StashBranch(name, branch string) (string, error) {
+	return r.combinedOutput("stash", "branch", branch, name)
+}
+
+// すべてのリモートからfetchする
+func (r *execRepository) Fetch(progress func(line string)) (string, error) {
+	return r.streamOutput(progress, "fetch", "--all", "--prune", "--progress")
+}
+
+// 現在のブランチに上流の変更を取り込む
+func (r *execRepository) Pull(mode PullMode, progress func(line string)) (string, error) {
+	args := []string{"pull", "--progress"}
+	switch mode {
+	case pullMerge:
+		args = append(args, "--no-rebase", "--no-edit")
+	case pullRebase:
+		args = append(args, "--rebase")
+	default:
+		args = append(args, "--ff-only")
+	}
+	return r.streamOutput(progress, args...)
+}
+
+// 現在のブランチを上流にpushする
+func (r *execRepository) Push(force bool, progress func(line string)) (string, error) {
+	args := []string{"push", "--progress"}
+	if force {
+		args = append(args, "--force-with-lease")
+	}
+	return r.streamOutput(progress, args...)
+}
+
+// マージコミットかどうか（2番目の親があるかどうか）
+func (r *execRepository) isMergeCommit(hash string) bool {
+	_, err := r.output("rev-parse", "--verify", "--quiet", hash+"^2")
+	return err == nil
+}
+
+// コミットの変更を現在のHEADに適用する
+func (r *execRepository) CherryPick(hash string) (string, error) {
+	args := []string{"cherry-pick"}
+	if r.isMergeCommit(hash) {
+		args = append(args, "-m", "1")
+	}
+	return r.combinedOutput(append(args, hash)...)
+}
+
+// コミットの変更を打ち消すコミットを作成する
+func (r *execRepository) Revert(hash string) (string, error) {
+	args := []string{"revert", "--no-edit"}
+	if r.isMergeCommit(hash) {
+		args = append(args, "-m", "1")
+	}
+	return r.combinedOutput(append(args, hash)...)
+}
+
+// 現在のブランチをコミットに移動する
+func (r *execRepository) Reset(hash string, mode ResetMode) (string, error) {
+	return r.combinedOutput("reset", "--"+mode.String(), hash, "--")
+}
+
+// sourceを現在のHEADにマージしたときに取り込まれるものを見積もる
+func (r *execRepository) MergePreview(source string) (MergePreview, error) {
+	output, err := r.output("rev-list", "--count", "HEAD.."+source, "--")
+	if err != nil {
+		return MergePreview{}, err
+	}
+	var preview MergePreview
+	if _, err := fmt.Sscan(string(output), &preview.Commits); err != nil {
+		return MergePreview{}, err
+	}
+	// 祖先でなければ終了コード1で失敗する
+	preview.FastForward = r.command("merge-base", "--is-ancestor", "HEAD", source).Run() == nil
+	return preview, nil
+}
+
+// sourceを現在のHEADにマージする
+// git mergeは標準入力からメッセージを読めないので、マージコミットのメッセージは一時ファイルで渡す
+// squashはマージした結果をインデックスに置くだけなので、続けてコミットする
+func (r *execRepository) Merge(source string, mode MergeMode, message string) (string, error) {
+	var cmd *exec.Cmd
+	switch mode {
+	case mergeFastForward:
+		cmd = r.command("merge", "--ff-only", source)
+	case mergeNoFastForward:
+		file, err := os.CreateTemp("", "cit-merge-msg-")
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(file.Name())
+		_, err = file.WriteString(message)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", err
+		}
+		cmd = r.command("merge", "--no-ff", "--cleanup=strip", "--file="+file.Name(), source)
+	default:
+		cmd = r.command("merge", "--squash", source)
+	}
+	output, err := runWithProgress(cmd, nil)
+	if err != nil || mode != mergeSquash {
+		return output, err
+	}
+	commitOutput, err := r.Commit(message, false)
+	if err != nil {
+		return output, commandError(commitOutput, err)
+	}
+	return output + "\n" + commitOutput, nil
+}
+
+// baseより後のコミットを手順のとおりにリベースする
+// 作った手順のファイルをGIT_SEQUENCE_EDITORでgitの手順のファイルに上書きし、
+// squashで開くエディタは何もせずに閉じる（つなげたメッセージのままにする）
+func (r *execRepository) Rebase(base string, steps []RebaseStep) (string, error) {
+	todo, err := os.CreateTemp("", "cit-rebase-todo-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(todo.Name())
+	_, err = todo.WriteString(rebaseTodo(steps))
+	if closeErr := todo.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	cmd := r.command("rebase", "--interactive", base)
+	cmd.Env = append(cmd.Env, "GIT_SEQUENCE_EDITOR=cp "+shellQuote(todo.Name()), "GIT_EDITOR=true")
+	return runWithProgress(cmd, nil)
+}
+
+// 途中で止まっている操作を取得
+func (r *execRepository) Operation() (Operation, error) {
+	output, err := r.output("rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return Operation{}, err
+	}
+	operation := readOperation(strings.TrimSpace(string(output)))
+	if operation.Kind == operationNone {
+		return operation, nil
+	}
+	files, err := r.Status()
+	if err != nil {
+		return Operation{}, err
+	}
+	for _, file := range files {
+		if file.Conflicted {
+			operation.Conflicts = append(operation.Conflicts, file.Path)
+		}
+	}
+	return operation, nil
+}
+
+// 途中で止まっている操作を続ける、中止する、または今のコミットを飛ばす
+// 続けるときにコミットメッセージのエディタが開く場合は、用意されたメッセージのままにする
+func (r *execRepository) FinishOperation(kind OperationKind, action OperationAction) (string, error) {
+	var args []string
+	switch {
+	case kind == operationNone:
+		return "", errors.New("no operation is in progress")
+	case kind == operationBisect && action == operationAbort:
+		args = []string{"bisect", "reset"}
+	case kind == operationBisect && action == operationSkip:
+		args = []string{"bisect", "skip"}
+	case kind == operationBisect || (kind == operationMerge && action == operationSkip):
+		return "", fmt.Errorf("cannot %s a %s", action, kind)
+	case kind == operationMerge && action == operationAbort:
+		// merge --abortはreset --mergeと同じだが、MERGE_HEADのないsquashの競合も中止できるようにする
+		args = []string{"reset", "--merge"}
+	default:
+		args = []string{kind.String(), "--" + action.String()}
+	}
+
+	cmd := r.command(args...)
+	cmd.Env = append(cmd.Env, "GIT_EDITOR=true")
+	return runWithProgress(cmd, nil)
+}
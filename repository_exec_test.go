@@ -0,0 +1,45 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseLogLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want Commit
+		ok   bool
+	}{
+		{
+			line: "abc123|def456|Alice|Mon Jan 2 15:04:05 2006 +0900|Fix bug",
+			want: Commit{Hash: "abc123", Parents: []string{"def456"}, Author: "Alice", Date: "2006-01-02 15:04:05", Message: "Fix bug"},
+			ok:   true,
+		},
+		{
+			// マージコミットは親が複数、件名に区切り文字を含んでもよい
+			line: "abc123|p1 p2|Bob|Tue Feb 3 04:05:06 2009 -0700|Merge a|b",
+			want: Commit{Hash: "abc123", Parents: []string{"p1", "p2"}, Author: "Bob", Date: "2009-02-03 04:05:06", Message: "Merge a|b"},
+			ok:   true,
+		},
+		{
+			// ルートコミットは親がない
+			line: "abc123||Carol|bad date|Initial",
+			want: Commit{Hash: "abc123", Parents: []string{}, Author: "Carol", Date: "bad date", Message: "Initial"},
+			ok:   true,
+		},
+		{line: "abc123|p1|Alice", ok: false},
+		{line: "", ok: false},
+	}
+
+	for _, test := range tests {
+		got, ok := parseLogLine(test.line)
+		if ok != test.ok {
+			t.Errorf("parseLogLine(%q) ok = %v; want %v", test.line, ok, test.ok)
+			continue
+		}
+		if ok && !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseLogLine(%q) = %+v; want %+v", test.line, got, test.want)
+		}
+	}
+}
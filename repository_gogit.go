@@ -0,0 +1,1857 @@
+package main
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	gitdiff "github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// go-gitでは行えない操作のエラー
+var errNotSupported = errors.New("not supported without the git command (set CIT_BACKEND=exec)")
+
+// gitコマンドを使わず、オブジェクトデータベースやrefを直接読むRepositoryの実装
+// go-gitのリポジトリは複数のゴルーチンから同時に使えないので、muで排他制御する
+type goGitRepository struct {
+	repo *git.Repository
+	dir  string // fetchなどの時間のかかる操作で別に開くためのディレクトリ
+	mu   sync.Mutex
+}
+
+// go-gitを使うRepositoryを作成
+func newGoGitRepository(dir string) (*goGitRepository, error) {
+	repo, err := git.PlainOpen(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &goGitRepository{repo: repo, dir: dir}, nil
+}
+
+// 現在のHEADのコミットハッシュを取得
+func (r *goGitRepository) HeadCommitHash() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	head, err := r.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return head.Hash().String(), nil
+}
+
+// 現在のHEADが指しているブランチ名を取得する
+func (r *goGitRepository) CurrentBranchName() (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.currentBranchName()
+}
+
+// CurrentBranchNameの本体（muをロックした状態で呼び出す）
+func (r *goGitRepository) currentBranchName() (string, bool) {
+	head, err := r.repo.Reference(plumbing.HEAD, false)
+	if err != nil || head.Type() != plumbing.SymbolicReference {
+		// シンボリック参照でなければdetached HEAD状態
+		return "", false
+	}
+	return head.Target().Short(), true
+}
+
+// すべてのブランチとタグを一度に取得
+func (r *goGitRepository) Refs() ([]Ref, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	iter, err := r.repo.References()
+	if err != nil {
+		return nil, err
+	}
+
+	// ローカルブランチの上流はリポジトリの設定から読む
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []Ref
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		kind, name, ok := classifyRef(ref.Name().String())
+		if !ok {
+			return nil
+		}
+		target := ref.Hash()
+		if tag, err := r.repo.TagObject(target); err == nil {
+			// 注釈付きタグは指しているコミットにする
+			target = tag.Target
+		}
+		upstream := ""
+		if kind == RefLocalBranch {
+			upstream = upstreamName(cfg.Branches[name])
+		}
+		refs = append(refs, Ref{Name: name, FullName: ref.Name().String(), Kind: kind, Hash: target.String(), Upstream: upstream})
+		return nil
+	})
+	return refs, err
+}
+
+// 作業ツリーの状態を取得
+func (r *goGitRepository) status() (git.Status, error) {
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+	return worktree.Status()
+}
+
+// ブランチの一覧を取得
+func (r *goGitRepository) Branches() ([]BranchInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	// 上流との差を数えるために、辿れるコミットの集合を覚えておく
+	reachable := map[plumbing.Hash]map[plumbing.Hash]bool{}
+	ancestors := func(hash plumbing.Hash) (map[plumbing.Hash]bool, error) {
+		if set, ok := reachable[hash]; ok {
+			return set, nil
+		}
+		set, err := r.ancestors(hash)
+		reachable[hash] = set
+		return set, err
+	}
+	var headAncestors map[plumbing.Hash]bool
+	if head, err := r.repo.Head(); err == nil {
+		if headAncestors, err = ancestors(head.Hash()); err != nil {
+			return nil, err
+		}
+	}
+
+	iter, err := r.repo.References()
+	if err != nil {
+		return nil, err
+	}
+	var branches []BranchInfo
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		kind, name, ok := classifyRef(ref.Name().String())
+		if !ok || kind == RefTag {
+			return nil
+		}
+		commit, err := r.repo.CommitObject(ref.Hash())
+		if err != nil {
+			return err
+		}
+		branch := BranchInfo{
+			Ref:     Ref{Name: name, FullName: ref.Name().String(), Kind: kind, Hash: ref.Hash().String()},
+			Subject: strings.ReplaceAll(commitSubject(commit.Message), "\n", " "),
+			Date:    commit.Committer.When,
+			Merged:  headAncestors[ref.Hash()],
+		}
+		if kind == RefLocalBranch {
+			branchConfig := cfg.Branches[name]
+			branch.Upstream = upstreamName(branchConfig)
+			if branch.Upstream != "" {
+				upstreamRef := plumbing.ReferenceName("refs/remotes/" + branch.Upstream)
+				if branchConfig.Remote == "" || branchConfig.Remote == "." {
+					upstreamRef = branchConfig.Merge
+				}
+				upstream, err := r.repo.Reference(upstreamRef, true)
+				if err != nil {
+					branch.UpstreamGone = true
+				} else {
+					mine, err := ancestors(ref.Hash())
+					if err != nil {
+						return err
+					}
+					theirs, err := ancestors(upstream.Hash())
+					if err != nil {
+						return err
+					}
+					branch.Ahead, branch.Behind = countMissing(mine, theirs), countMissing(theirs, mine)
+				}
+			}
+		}
+		branches = append(branches, branch)
+		return nil
+	})
+	return branches, err
+}
+
+// コミットから辿れるコミット（自身を含む）の集合
+func (r *goGitRepository) ancestors(hash plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	commit, err := r.repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	set := map[plumbing.Hash]bool{}
+	err = object.NewCommitPreorderIter(commit, nil, nil).ForEach(func(c *object.Commit) error {
+		set[c.Hash] = true
+		return nil
+	})
+	return set, err
+}
+
+// aにあってbにないコミットの数
+func countMissing(a, b map[plumbing.Hash]bool) int {
+	count := 0
+	for hash := range a {
+		if !b[hash] {
+			count++
+		}
+	}
+	return count
+}
+
+// 未コミットの変更があるか確認
+func (r *goGitRepository) HasUncommittedChanges() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status, err := r.status()
+	if err != nil {
+		return false
+	}
+	return !status.IsClean()
+}
+
+// 未コミットの変更の概要を取得
+func (r *goGitRepository) UncommittedChangesSummary() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status, err := r.status()
+	if err != nil {
+		return "", err
+	}
+
+	// 変更のあるファイルだけを数える
+	numChanges := 0
+	for _, fileStatus := range status {
+		if fileStatus.Staging != git.Unmodified || fileStatus.Worktree != git.Unmodified {
+			numChanges++
+		}
+	}
+	if numChanges == 0 {
+		return "", nil // 変更なし
+	}
+
+	return fmt.Sprintf("%d files changed", numChanges), nil
+}
+
+// go-gitの状態をgit status --porcelain=v2と同じ文字にする
+func statusCode(code git.StatusCode) byte {
+	if code == git.Unmodified {
+		return '.'
+	}
+	return byte(code)
+}
+
+// 未コミットのファイルの状態を取得
+func (r *goGitRepository) Status() ([]FileStatus, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	status, err := r.status()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []FileStatus
+	for name, fileStatus := range status {
+		file := FileStatus{
+			Path:       name,
+			Index:      statusCode(fileStatus.Staging),
+			Worktree:   statusCode(fileStatus.Worktree),
+			Untracked:  fileStatus.Worktree == git.Untracked,
+			Conflicted: fileStatus.Staging == git.UpdatedButUnmerged || fileStatus.Worktree == git.UpdatedButUnmerged,
+		}
+		if file.Index == '.' && file.Worktree == '.' {
+			continue
+		}
+		files = append(files, file)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// ファイルの変更をインデックスに追加する
+func (r *goGitRepository) StageFile(file FileStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	// 作業ツリーにないファイルはインデックスからも削除される
+	_, err = worktree.Add(file.Path)
+	return err
+}
+
+// インデックスに追加した変更を取り消す
+func (r *goGitRepository) UnstageFile(file FileStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	return worktree.Restore(&git.RestoreOptions{Staged: true, Files: []string{file.Path}})
+}
+
+// 作業ツリーの変更を捨てる
+// go-gitのRestoreはインデックスも戻してしまうので、インデックスの内容を直接書き出す
+func (r *goGitRepository) DiscardFile(file FileStatus) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		return err
+	}
+	if file.Untracked {
+		return worktree.Filesystem.Remove(file.Path)
+	}
+
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return err
+	}
+	entry, err := idx.Entry(file.Path)
+	if err != nil {
+		return err
+	}
+	content, err := r.blobContent(entry.Hash)
+	if err != nil {
+		return err
+	}
+
+	fs := worktree.Filesystem
+	fs.Remove(file.Path) // 種類が変わった場合（シンボリックリンクなど）に備えて作り直す
+	if entry.Mode == filemode.Symlink {
+		return fs.Symlink(content, file.Path)
+	}
+	mode, err := entry.Mode.ToOSFileMode()
+	if err != nil {
+		return err
+	}
+	if err := fs.MkdirAll(path.Dir(file.Path), 0o755); err != nil {
+		return err
+	}
+	f, err := fs.OpenFile(file.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(f, content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// blobの内容を取得
+func (r *goGitRepository) blobContent(hash plumbing.Hash) (string, error) {
+	blob, err := r.repo.BlobObject(hash)
+	if err != nil {
+		return "", err
+	}
+	reader, err := blob.Reader()
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	return string(content), err
+}
+
+// ファイルの差分を取得
+func (r *goGitRepository) FileDiff(file FileStatus, staged bool) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return "", err
+	}
+	indexFile := func() (*patchFile, error) {
+		entry, err := idx.Entry(file.Path)
+		if err == index.ErrEntryNotFound {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		content, err := r.blobContent(entry.Hash)
+		return &patchFile{path: file.Path, hash: entry.Hash, mode: entry.Mode, content: content}, err
+	}
+
+	var from, to *patchFile
+	if staged {
+		// HEADのツリーとインデックスを比べる（最初のコミットの前はHEADがない）
+		if head, err := r.repo.Head(); err == nil {
+			commit, err := r.repo.CommitObject(head.Hash())
+			if err != nil {
+				return "", err
+			}
+			if treeFile, err := commit.File(file.Path); err == nil {
+				content, err := treeFile.Contents()
+				if err != nil {
+					return "", err
+				}
+				from = &patchFile{path: file.Path, hash: treeFile.Hash, mode: treeFile.Mode, content: content}
+			}
+		}
+		if to, err = indexFile(); err != nil {
+			return "", err
+		}
+	} else {
+		// インデックス（追跡していないファイルは空）と作業ツリーを比べる
+		if !file.Untracked {
+			if from, err = indexFile(); err != nil {
+				return "", err
+			}
+		}
+		worktree, err := r.repo.Worktree()
+		if err != nil {
+			return "", err
+		}
+		f, err := worktree.Filesystem.Open(file.Path)
+		if err == nil {
+			content, err := io.ReadAll(f)
+			f.Close()
+			if err != nil {
+				return "", err
+			}
+			to = &patchFile{path: file.Path, mode: filemode.Regular, content: string(content)}
+			if from != nil {
+				to.mode = from.mode
+			}
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	var b strings.Builder
+	patch := filePatch{from: from, to: to}
+	if err := diff.NewUnifiedEncoder(&b, diff.DefaultContextLines).Encode(patch); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// パッチを適用する
+// go-gitにはパッチを適用する機能がない
+func (r *goGitRepository) ApplyPatch(patch string, cached, reverse bool) error {
+	return errNotSupported
+}
+
+// コミットメッセージのひな形を取得
+func (r *goGitRepository) CommitTemplate() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cfg, err := r.repo.ConfigScoped(config.GlobalScope)
+	if err != nil {
+		return "", err
+	}
+	path := cfg.Raw.Section("commit").Option("template")
+	if path == "" {
+		return "", nil
+	}
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, rest)
+	} else if !filepath.IsAbs(path) {
+		worktree, err := r.repo.Worktree()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(worktree.Filesystem.Root(), path)
+	}
+	content, err := os.ReadFile(path)
+	return string(content), err
+}
+
+// HEADのコミットメッセージを取得
+func (r *goGitRepository) HeadCommitMessage() (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	head, err := r.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	commit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return "", err
+	}
+	return commit.Message, nil
+}
+
+// コミットメッセージからコメント行と余分な空行を取り除く（git commit --cleanup=stripと同じ）
+func cleanupMessage(message string) string {
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" && (len(lines) == 0 || lines[len(lines)-1] == "") {
+			// 先頭の空行と連続する空行はまとめる
+			continue
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// インデックスの内容をコミットする
+// go-gitではフックは実行されない
+func (r *goGitRepository) Commit(message string, amend bool) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	message = cleanupMessage(message)
+	if message == "" {
+		return "", errors.New("aborting commit due to empty commit message")
+	}
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	hash, err := worktree.Commit(message, &git.CommitOptions{Amend: amend})
+	if err != nil {
+		return "", err
+	}
+
+	branch, ok := r.currentBranchName()
+	if !ok {
+		branch = "detached HEAD"
+	}
+	return fmt.Sprintf("[%s %s] %s\n", branch, hash.String()[:7], commitSubject(message)), nil
+}
+
+// 設定されているユーザー名を取得
+func (r *goGitRepository) UserName() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// リポジトリの設定にグローバル設定をマージしたものを参照
+	cfg, err := r.repo.ConfigScoped(config.GlobalScope)
+	if err != nil {
+		return ""
+	}
+	return cfg.User.Name
+}
+
+// 日付の条件を解析する（go-gitでは"2006-01-02"形式のみ対応）
+func parseFilterDate(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported date (use yyyy-MM-dd): %s", value)
+	}
+	return &t, nil
+}
+
+// 並べ替えたコミットを少しずつ返すLogReader
+// 読み込みはLogの中で終わっていて、ここでは結果をページに分けて返すだけ（gitコマンドのように流しながら読まない）
+type goGitLogReader struct {
+	commits []Commit // 表示する順に並べたコミット
+}
+
+// 最大n件のコミットを読み込む
+func (l *goGitLogReader) Next(n int) ([]Commit, error) {
+	if n >= len(l.commits) {
+		commits := l.commits
+		l.commits = nil
+		return commits, io.EOF
+	}
+	commits := l.commits[:n]
+	l.commits = l.commits[n:]
+	return commits, nil
+}
+
+// 読み込みを中止する
+func (l *goGitLogReader) Close() error {
+	l.commits = nil
+	return nil
+}
+
+// コミット日時の新しい順に取り出す優先度付きキュー
+// 日時が同じ場合は先に入れたものを先に取り出す（git logと同じ）
+type commitQueue []queuedCommit
+
+type queuedCommit struct {
+	commit *object.Commit
+	seq    int // キューに入れた順番
+}
+
+func (q commitQueue) Len() int { return len(q) }
+func (q commitQueue) Less(i, j int) bool {
+	ti, tj := q[i].commit.Committer.When, q[j].commit.Committer.When
+	if !ti.Equal(tj) {
+		return ti.After(tj)
+	}
+	return q[i].seq < q[j].seq
+}
+func (q commitQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+func (q *commitQueue) Push(x any)   { *q = append(*q, x.(queuedCommit)) }
+func (q *commitQueue) Pop() any {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// startsから辿れるすべてのコミットを、git log --date-orderと同じ順序に並べる
+// 子は必ず親より先に並び、それ以外はコミット日時の新しい順になる（Kahnのアルゴリズム）
+// 履歴はparentsOfが返す親を辿る（パスの絞り込みで辿る親を減らすため）
+// muをロックした状態で呼び出す
+func (r *goGitRepository) dateOrderCommits(starts []plumbing.Hash, parentsOf func(*object.Commit) ([]plumbing.Hash, error)) ([]*object.Commit, error) {
+	commits := make(map[plumbing.Hash]*object.Commit)
+	parents := make(map[plumbing.Hash][]plumbing.Hash) // 辿る親
+	children := make(map[plumbing.Hash]int)            // まだ並べていない子の数
+
+	// 辿れるコミットをすべて読み込み、子の数を数える
+	var roots []*object.Commit
+	var stack []plumbing.Hash
+	for _, hash := range starts {
+		if _, ok := commits[hash]; ok {
+			continue
+		}
+		c, err := r.repo.CommitObject(hash)
+		if err != nil {
+			return nil, err
+		}
+		commits[hash] = c
+		roots = append(roots, c)
+		stack = append(stack, hash)
+	}
+	for len(stack) > 0 {
+		hash := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		hashes, err := parentsOf(commits[hash])
+		if err != nil {
+			return nil, err
+		}
+		parents[hash] = hashes
+		for _, parent := range hashes {
+			children[parent]++
+			if _, ok := commits[parent]; ok {
+				continue
+			}
+			c, err := r.repo.CommitObject(parent)
+			if err == plumbing.ErrObjectNotFound {
+				continue // shallow cloneなどで親がない
+			}
+			if err != nil {
+				return nil, err
+			}
+			commits[parent] = c
+			stack = append(stack, parent)
+		}
+	}
+
+	// 子がすべて並んだコミットから、日時の新しい順に取り出す
+	queue := &commitQueue{}
+	seq := 0
+	push := func(c *object.Commit) {
+		heap.Push(queue, queuedCommit{commit: c, seq: seq})
+		seq++
+	}
+	for _, c := range roots {
+		if children[c.Hash] == 0 {
+			push(c)
+		}
+	}
+	ordered := make([]*object.Commit, 0, len(commits))
+	for queue.Len() > 0 {
+		c := heap.Pop(queue).(queuedCommit).commit
+		ordered = append(ordered, c)
+		for _, parent := range parents[c.Hash] {
+			children[parent]--
+			if p, ok := commits[parent]; ok && children[parent] == 0 {
+				push(p)
+			}
+		}
+	}
+	return ordered, nil
+}
+
+// git log --allと同じく、HEADとすべてのrefが指すコミットを集める
+// muをロックした状態で呼び出す
+func (r *goGitRepository) allRefCommits() ([]plumbing.Hash, error) {
+	var starts []plumbing.Hash
+	if head, err := r.repo.Head(); err == nil {
+		starts = append(starts, head.Hash())
+	}
+	iter, err := r.repo.References()
+	if err != nil {
+		return nil, err
+	}
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		// スタッシュのコミットは一覧に表示しない
+		if ref.Type() != plumbing.HashReference || ref.Name() == plumbing.HEAD || ref.Name() == stashRef {
+			return nil
+		}
+		hash := ref.Hash()
+		if tag, err := r.repo.TagObject(hash); err == nil {
+			// 注釈付きタグは指しているコミットにする（コミット以外を指すタグは除く）
+			if tag.TargetType != plumbing.CommitObject {
+				return nil
+			}
+			hash = tag.Target
+		}
+		starts = append(starts, hash)
+		return nil
+	})
+	return starts, err
+}
+
+// パスで絞り込むときに、git logの履歴の単純化と同じようにコミットを判定する
+type pathSimplifier struct {
+	repo    *git.Repository
+	path    string
+	entries map[plumbing.Hash]plumbing.Hash // コミット -> パスのオブジェクト（なければZeroHash）
+}
+
+// コミットのツリーでのパスのオブジェクトを取得
+func (s *pathSimplifier) entry(hash plumbing.Hash) (plumbing.Hash, error) {
+	if entry, ok := s.entries[hash]; ok {
+		return entry, nil
+	}
+	c, err := s.repo.CommitObject(hash)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	tree, err := c.Tree()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	entry := plumbing.ZeroHash
+	if e, err := tree.FindEntry(s.path); err == nil {
+		entry = e.Hash
+	}
+	s.entries[hash] = entry
+	return entry, nil
+}
+
+// 履歴を辿る親と、コミットを表示するかどうかを判定する
+// パスの内容が同じ親（TREESAME）があれば、その親だけを辿ってコミットは表示しない
+// マージコミットも同じで、どれかの親と同じなら他の親から来た履歴は辿らない
+func (s *pathSimplifier) simplify(c *object.Commit) ([]plumbing.Hash, bool, error) {
+	entry, err := s.entry(c.Hash)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(c.ParentHashes) == 0 {
+		// ルートコミットはパスが存在すれば表示する
+		return nil, entry != plumbing.ZeroHash, nil
+	}
+	for _, parent := range c.ParentHashes {
+		parentEntry, err := s.entry(parent)
+		if err == plumbing.ErrObjectNotFound {
+			continue // shallow cloneなどで親がない
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if parentEntry == entry {
+			return []plumbing.Hash{parent}, false, nil
+		}
+	}
+	return c.ParentHashes, true, nil
+}
+
+// コミットログの読み込みを開始
+// --date-orderでは子をすべて並べるまで親を出せず、子を数えるには辿れる履歴をすべて読む必要があるので、
+// go-gitでは最初のページを返す前に履歴全体を読み込んで並べ替え、すべてのコミットをメモリに持つ
+// （gitコマンドもcommit-graphがなければ同じく全体を辿る）。巨大なリポジトリではexecのバックエンドを使う
+func (r *goGitRepository) Log(filter LogFilter) (LogReader, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var starts []plumbing.Hash
+	if filter.Ref != "" {
+		hash, err := r.repo.ResolveRevision(plumbing.Revision(filter.Ref))
+		if err != nil {
+			return nil, err
+		}
+		starts = []plumbing.Hash{*hash}
+	} else {
+		var err error
+		if starts, err = r.allRefCommits(); err != nil {
+			return nil, err
+		}
+	}
+
+	since, err := parseFilterDate(filter.Since)
+	if err != nil {
+		return nil, err
+	}
+	until, err := parseFilterDate(filter.Until)
+	if err != nil {
+		return nil, err
+	}
+	if until != nil {
+		// 指定した日の終わりまでを含める
+		end := until.AddDate(0, 0, 1)
+		until = &end
+	}
+	// git log --authorと同じく、作者は"名前 <メール>"に対する正規表現として扱う
+	var author *regexp.Regexp
+	if filter.Author != "" {
+		if author, err = regexp.Compile(filter.Author); err != nil {
+			return nil, fmt.Errorf("invalid author pattern: %w", err)
+		}
+	}
+
+	// パスを指定した場合は、パスを変更したコミットだけを表示し、
+	// その間のコミットを飛ばして親をつなぎ直す（git log --parents -- pathと同じ）
+	parents := make(map[plumbing.Hash][]plumbing.Hash) // 辿る親
+	hidden := make(map[plumbing.Hash]bool)             // パスを変更していないコミット
+	parentsOf := func(c *object.Commit) ([]plumbing.Hash, error) {
+		return c.ParentHashes, nil
+	}
+	if filter.Path != "" {
+		simplifier := &pathSimplifier{
+			repo:    r.repo,
+			path:    strings.Trim(filter.Path, "/"),
+			entries: make(map[plumbing.Hash]plumbing.Hash),
+		}
+		parentsOf = func(c *object.Commit) ([]plumbing.Hash, error) {
+			hashes, shown, err := simplifier.simplify(c)
+			parents[c.Hash] = hashes
+			hidden[c.Hash] = !shown
+			return hashes, err
+		}
+	}
+
+	// git log --date-orderと同じ順序に並べてから条件に合うものを残す
+	ordered, err := r.dateOrderCommits(starts, parentsOf)
+	if err != nil {
+		return nil, err
+	}
+
+	// 表示しないコミットを飛ばした先の親を探す
+	// 表示しないコミットが辿る親は1つだけなので、表示するコミットに着くまで順に辿る
+	rewriteParent := func(hash plumbing.Hash) (plumbing.Hash, bool) {
+		for hidden[hash] {
+			if len(parents[hash]) == 0 {
+				return plumbing.ZeroHash, false
+			}
+			hash = parents[hash][0]
+		}
+		return hash, true
+	}
+
+	var commits []Commit
+	for _, c := range ordered {
+		if hidden[c.Hash] {
+			continue
+		}
+		if author != nil && !author.MatchString(c.Author.Name+" <"+c.Author.Email+">") {
+			continue
+		}
+		// git logと同じくコミット日時で絞り込む
+		if since != nil && c.Committer.When.Before(*since) {
+			continue
+		}
+		if until != nil && !c.Committer.When.Before(*until) {
+			continue
+		}
+
+		var parentHashes []string
+		seen := make(map[plumbing.Hash]bool)
+		for _, parent := range c.ParentHashes {
+			if filter.Path != "" {
+				var ok bool
+				if parent, ok = rewriteParent(parent); !ok || seen[parent] {
+					continue
+				}
+				seen[parent] = true
+			}
+			parentHashes = append(parentHashes, parent.String())
+		}
+		commits = append(commits, Commit{
+			Hash:    c.Hash.String(),
+			Parents: parentHashes,
+			Author:  c.Author.Name,
+			Date:    c.Author.When.Format("2006-01-02 15:04:05"),
+			Message: formatMessage(commitSubject(c.Message)),
+		})
+	}
+	if filter.HidesParents() {
+		// 条件に合わず表示しない親にはグラフの線を伸ばさない
+		shown := make(map[string]bool, len(commits))
+		for _, commit := range commits {
+			shown[commit.Hash] = true
+		}
+		for i := range commits {
+			commits[i].Parents = shownParents(commits[i].Parents, shown)
+		}
+	}
+	return &goGitLogReader{commits: commits}, nil
+}
+
+// コミットの詳細情報を取得
+func (r *goGitRepository) CommitDetail(hash string) (CommitDetail, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	commit, err := r.repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return CommitDetail{}, err
+	}
+
+	detail := CommitDetail{
+		Hash:           commit.Hash.String(),
+		Author:         commit.Author.Name,
+		AuthorEmail:    commit.Author.Email,
+		AuthorDate:     commit.Author.When.Format("2006-01-02 15:04:05"),
+		Committer:      commit.Committer.Name,
+		CommitterEmail: commit.Committer.Email,
+		CommitterDate:  commit.Committer.When.Format("2006-01-02 15:04:05"),
+		Message:        commit.Message,
+	}
+	for _, parent := range commit.ParentHashes {
+		detail.Parents = append(detail.Parents, parent.String())
+	}
+
+	// このコミットを指しているrefを集める（git log --decorateと同じ形式）
+	headBranch := ""
+	if head, err := r.repo.Head(); err == nil && head.Hash() == commit.Hash {
+		if branch, isAttached := r.currentBranchName(); isAttached {
+			headBranch = branch
+			detail.Refs = append(detail.Refs, "HEAD -> "+branch)
+		} else {
+			detail.Refs = append(detail.Refs, "HEAD")
+		}
+	}
+	if iter, err := r.repo.References(); err == nil {
+		iter.ForEach(func(ref *plumbing.Reference) error {
+			if ref.Type() != plumbing.HashReference || ref.Name() == plumbing.HEAD {
+				return nil
+			}
+			if ref.Name().IsBranch() && ref.Name().Short() == headBranch {
+				return nil // HEAD -> branch として追加済み
+			}
+			target := ref.Hash()
+			if tag, err := r.repo.TagObject(target); err == nil {
+				// 注釈付きタグは指しているコミットで比較する
+				target = tag.Target
+			}
+			if target != commit.Hash {
+				return nil
+			}
+			if ref.Name().IsTag() {
+				detail.Refs = append(detail.Refs, "tag: "+ref.Name().Short())
+			} else {
+				detail.Refs = append(detail.Refs, ref.Name().Short())
+			}
+			return nil
+		})
+	}
+
+	// 変更されたファイルの行数を取得（マージコミットは最初の親との差分）
+	stats, err := commit.Stats()
+	if err != nil {
+		return CommitDetail{}, err
+	}
+	for _, stat := range stats {
+		detail.Files = append(detail.Files, FileStat{
+			Path:      stat.Name,
+			Additions: stat.Addition,
+			Deletions: stat.Deletion,
+		})
+	}
+
+	return detail, nil
+}
+
+// コミットの差分を取得
+func (r *goGitRepository) CommitDiff(hash string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	commit, err := r.repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return "", err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return "", err
+	}
+
+	// 最初の親のツリーと比較する（ルートコミットは空のツリーと比較）
+	var parentTree *object.Tree
+	if commit.NumParents() > 0 {
+		parent, err := commit.Parent(0)
+		if err != nil {
+			return "", err
+		}
+		if parentTree, err = parent.Tree(); err != nil {
+			return "", err
+		}
+	}
+
+	changes, err := object.DiffTree(parentTree, tree)
+	if err != nil {
+		return "", err
+	}
+	patch, err := changes.Patch()
+	if err != nil {
+		return "", err
+	}
+	return patch.String(), nil
+}
+
+// コミットメッセージから件名（最初の段落）を取り出す
+func commitSubject(message string) string {
+	subject, _, _ := strings.Cut(strings.TrimLeft(message, "\n"), "\n\n")
+	return strings.TrimSpace(subject)
+}
+
+// ブランチに切り替える
+func (r *goGitRepository) SwitchBranch(branchName string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	err = worktree.Checkout(&git.CheckoutOptions{
+		Branch: plumbing.NewBranchReferenceName(branchName),
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Switched to branch '%s'", branchName), nil
+}
+
+// コミットをハッシュ値でチェックアウトする（detached HEAD）
+func (r *goGitRepository) CheckoutDetached(hash string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	err = worktree.Checkout(&git.CheckoutOptions{
+		Hash: plumbing.NewHash(hash),
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("HEAD is now at %s", hash[:7]), nil
+}
+
+// リモートブランチを追跡するローカルブランチを作成して切り替える
+func (r *goGitRepository) SwitchTrackingBranch(name, remoteBranch string) (string, error) {
+	r.mu.Lock()
+	remote, err := r.repo.Reference(plumbing.ReferenceName("refs/remotes/"+remoteBranch), true)
+	r.mu.Unlock()
+	if err != nil {
+		return "", fmt.Errorf("remote branch '%s' not found", remoteBranch)
+	}
+
+	if err := r.CreateBranch(name, remote.Hash().String()); err != nil {
+		return "", err
+	}
+	if err := r.SetUpstream(name, remoteBranch); err != nil {
+		return "", err
+	}
+	output, err := r.SwitchBranch(name)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("branch '%s' set up to track '%s'. %s", name, remoteBranch, output), nil
+}
+
+// ローカルブランチの参照を取得する（なければgitコマンドと同じ文言のエラーにする）
+func (r *goGitRepository) branchReference(name string) (*plumbing.Reference, error) {
+	ref, err := r.repo.Reference(plumbing.NewBranchReferenceName(name), false)
+	if err != nil {
+		return nil, fmt.Errorf("branch '%s' not found", name)
+	}
+	return ref, nil
+}
+
+// 新しいブランチの名前を確認する
+func (r *goGitRepository) checkNewBranchName(name string) (plumbing.ReferenceName, error) {
+	refName := plumbing.NewBranchReferenceName(name)
+	if err := refName.Validate(); err != nil || strings.HasPrefix(name, "-") {
+		return "", fmt.Errorf("'%s' is not a valid branch name", name)
+	}
+	if _, err := r.repo.Reference(refName, false); err == nil {
+		return "", fmt.Errorf("a branch named '%s' already exists", name)
+	}
+	return refName, nil
+}
+
+// コミットを指すブランチを作成する
+func (r *goGitRepository) CreateBranch(name, hash string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	refName, err := r.checkNewBranchName(name)
+	if err != nil {
+		return err
+	}
+	commit, err := r.repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return err
+	}
+	return r.repo.Storer.SetReference(plumbing.NewHashReference(refName, commit.Hash))
+}
+
+// ブランチの名前を変える
+func (r *goGitRepository) RenameBranch(oldName, newName string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ref, err := r.branchReference(oldName)
+	if err != nil {
+		return err
+	}
+	refName, err := r.checkNewBranchName(newName)
+	if err != nil {
+		return err
+	}
+	if err := r.repo.Storer.SetReference(plumbing.NewHashReference(refName, ref.Hash())); err != nil {
+		return err
+	}
+	if current, ok := r.currentBranchName(); ok && current == oldName {
+		// 現在のブランチならHEADも付け替える
+		if err := r.repo.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, refName)); err != nil {
+			return err
+		}
+	}
+	if err := r.repo.Storer.RemoveReference(ref.Name()); err != nil {
+		return err
+	}
+
+	// 上流などのブランチの設定を引き継ぐ
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return err
+	}
+	if branch, ok := cfg.Branches[oldName]; ok {
+		delete(cfg.Branches, oldName)
+		branch.Name = newName
+		cfg.Branches[newName] = branch
+		return r.repo.SetConfig(cfg)
+	}
+	return nil
+}
+
+// ブランチを削除する
+func (r *goGitRepository) DeleteBranch(name string, force bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ref, err := r.branchReference(name)
+	if err != nil {
+		return err
+	}
+	if current, ok := r.currentBranchName(); ok && current == name {
+		return fmt.Errorf("cannot delete branch '%s' checked out", name)
+	}
+	if !force {
+		merged, err := r.isMergedIntoHead(ref.Hash())
+		if err != nil {
+			return err
+		}
+		if !merged {
+			return errBranchNotMerged
+		}
+	}
+	if err := r.repo.Storer.RemoveReference(ref.Name()); err != nil {
+		return err
+	}
+
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return err
+	}
+	if _, ok := cfg.Branches[name]; ok {
+		delete(cfg.Branches, name)
+		return r.repo.SetConfig(cfg)
+	}
+	return nil
+}
+
+// コミットがHEADから辿れるかどうか
+func (r *goGitRepository) isMergedIntoHead(hash plumbing.Hash) (bool, error) {
+	head, err := r.repo.Head()
+	if err != nil {
+		return false, err
+	}
+	if head.Hash() == hash {
+		return true, nil
+	}
+	commit, err := r.repo.CommitObject(hash)
+	if err != nil {
+		return false, err
+	}
+	headCommit, err := r.repo.CommitObject(head.Hash())
+	if err != nil {
+		return false, err
+	}
+	return commit.IsAncestor(headCommit)
+}
+
+// ブランチの上流を設定する
+func (r *goGitRepository) SetUpstream(branch, upstream string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.branchReference(branch); err != nil {
+		return err
+	}
+	cfg, err := r.repo.Config()
+	if err != nil {
+		return err
+	}
+	current := cfg.Branches[branch]
+	if current == nil {
+		current = &config.Branch{Name: branch}
+	}
+
+	if upstream == "" {
+		if current.Merge == "" {
+			return fmt.Errorf("branch '%s' has no upstream information", branch)
+		}
+		current.Remote, current.Merge = "", ""
+	} else {
+		remote, merge, err := r.resolveUpstream(cfg, upstream)
+		if err != nil {
+			return err
+		}
+		if remote == "." && merge.Short() == branch {
+			return fmt.Errorf("not setting branch '%s' as its own upstream", branch)
+		}
+		current.Remote, current.Merge = remote, merge
+	}
+	cfg.Branches[branch] = current
+	return r.repo.SetConfig(cfg)
+}
+
+// 上流の名前（origin/mainやmain）をbranch.<name>.remoteとbranch.<name>.mergeの値にする
+// リモートのブランチはリモート名の最も長いものに一致させ、なければローカルブランチとする
+func (r *goGitRepository) resolveUpstream(cfg *config.Config, upstream string) (string, plumbing.ReferenceName, error) {
+	if _, err := r.repo.Reference(plumbing.ReferenceName("refs/remotes/"+upstream), false); err == nil {
+		remote := ""
+		for name := range cfg.Remotes {
+			if strings.HasPrefix(upstream, name+"/") && len(name) > len(remote) {
+				remote = name
+			}
+		}
+		if remote != "" {
+			return remote, plumbing.NewBranchReferenceName(strings.TrimPrefix(upstream, remote+"/")), nil
+		}
+	}
+	if _, err := r.repo.Reference(plumbing.NewBranchReferenceName(upstream), false); err == nil {
+		return ".", plumbing.NewBranchReferenceName(upstream), nil
+	}
+	return "", "", fmt.Errorf("the requested upstream branch '%s' does not exist", upstream)
+}
+
+// branch.<name>.remoteとbranch.<name>.mergeの値から上流の短い名前を作る（設定がなければ空）
+func upstreamName(branch *config.Branch) string {
+	if branch == nil || branch.Merge == "" {
+		return ""
+	}
+	name := strings.TrimPrefix(string(branch.Merge), "refs/heads/")
+	if branch.Remote == "" || branch.Remote == "." {
+		return name
+	}
+	return branch.Remote + "/" + name
+}
+
+// タグの情報を取得
+func (r *goGitRepository) TagDetail(name string) (TagDetail, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ref, err := r.repo.Tag(name)
+	if err != nil {
+		return TagDetail{}, fmt.Errorf("tag '%s' not found", name)
+	}
+	tag := TagDetail{Name: name}
+	object, err := r.repo.TagObject(ref.Hash())
+	if errors.Is(err, plumbing.ErrObjectNotFound) {
+		// 軽量タグ
+		return tag, nil
+	} else if err != nil {
+		return TagDetail{}, err
+	}
+	tag.Annotated = true
+	tag.Tagger = object.Tagger.Name
+	tag.TaggerEmail = object.Tagger.Email
+	tag.TaggerDate = object.Tagger.When.Format("2006-01-02 15:04:05")
+	tag.Message = object.Message
+	return tag, nil
+}
+
+// コミットにタグを作成する
+func (r *goGitRepository) CreateTag(name, hash, message string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if strings.HasPrefix(name, "-") {
+		return fmt.Errorf("'%s' is not a valid tag name", name)
+	}
+	var opts *git.CreateTagOptions
+	if message != "" {
+		opts = &git.CreateTagOptions{Message: message}
+	}
+	_, err := r.repo.CreateTag(name, plumbing.NewHash(hash), opts)
+	if errors.Is(err, git.ErrTagExists) {
+		return fmt.Errorf("tag '%s' already exists", name)
+	}
+	return err
+}
+
+// タグを削除する
+func (r *goGitRepository) DeleteTag(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.repo.DeleteTag(name); err != nil {
+		return fmt.Errorf("tag '%s' not found", name)
+	}
+	return nil
+}
+
+// 差分を作るファイルの内容（go-gitのdiff.Fileの実装）
+type patchFile struct {
+	path    string
+	hash    plumbing.Hash
+	mode    filemode.FileMode
+	content string
+}
+
+func (f *patchFile) Hash() plumbing.Hash     { return f.hash }
+func (f *patchFile) Mode() filemode.FileMode { return f.mode }
+func (f *patchFile) Path() string            { return f.path }
+
+// 1つのファイルの変更前と変更後の内容から作る差分（go-gitのdiff.Patchとdiff.FilePatchの実装）
+// 変更前がnilなら追加、変更後がnilなら削除
+type filePatch struct {
+	from, to *patchFile
+}
+
+func (p filePatch) FilePatches() []diff.FilePatch {
+	if p.from == nil && p.to == nil {
+		return nil
+	}
+	return []diff.FilePatch{p}
+}
+
+func (p filePatch) Message() string { return "" }
+
+// NULを含むファイルはgitと同じくバイナリとみなす
+func (p filePatch) IsBinary() bool {
+	for _, f := range []*patchFile{p.from, p.to} {
+		if f != nil && strings.Contains(f.content[:min(len(f.content), 8000)], "\x00") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p filePatch) Files() (diff.File, diff.File) {
+	var from, to diff.File
+	if p.from != nil {
+		from = p.from
+	}
+	if p.to != nil {
+		// 作業ツリーのファイルはハッシュを計算しておく
+		if p.to.hash.IsZero() {
+			p.to.hash = plumbing.ComputeHash(plumbing.BlobObject, []byte(p.to.content))
+		}
+		to = p.to
+	}
+	return from, to
+}
+
+func (p filePatch) Chunks() []diff.Chunk {
+	var src, dst string
+	if p.from != nil {
+		src = p.from.content
+	}
+	if p.to != nil {
+		dst = p.to.content
+	}
+
+	var chunks []diff.Chunk
+	for _, d := range gitdiff.Do(src, dst) {
+		operation := diff.Equal
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			operation = diff.Add
+		case diffmatchpatch.DiffDelete:
+			operation = diff.Delete
+		}
+		chunks = append(chunks, patchChunk{content: d.Text, operation: operation})
+	}
+	return chunks
+}
+
+// 差分の一部分（go-gitのdiff.Chunkの実装）
+type patchChunk struct {
+	content   string
+	operation diff.Operation
+}
+
+func (c patchChunk) Content() string      { return c.content }
+func (c patchChunk) Type() diff.Operation { return c.operation }
+
+// リモートと通信する操作のためにリポジトリを別に開く
+// 通信の間ロックを持ち続けると、その間ログなどを読み込めなくなるため
+func (r *goGitRepository) openForNetwork() (*git.Repository, error) {
+	return git.PlainOpen(r.dir)
+}
+
+// 現在のブランチと、その上流のリモートとブランチを取得する
+func currentUpstream(repo *git.Repository) (plumbing.ReferenceName, *config.Branch, error) {
+	head, err := repo.Head()
+	if err != nil {
+		return "", nil, err
+	}
+	if !head.Name().IsBranch() {
+		return "", nil, errors.New("you are not currently on a branch")
+	}
+	cfg, err := repo.Config()
+	if err != nil {
+		return "", nil, err
+	}
+	branch := cfg.Branches[head.Name().Short()]
+	if branch == nil || branch.Merge == "" || branch.Remote == "" || branch.Remote == "." {
+		return "", nil, fmt.Errorf("the current branch %s has no upstream branch on a remote", head.Name().Short())
+	}
+	return head.Name(), branch, nil
+}
+
+// すべてのリモートからfetchする
+func (r *goGitRepository) Fetch(progress func(line string)) (string, error) {
+	repo, err := r.openForNetwork()
+	if err != nil {
+		return "", err
+	}
+	remotes, err := repo.Remotes()
+	if err != nil {
+		return "", err
+	}
+
+	writer := &progressWriter{progress: progress}
+	for _, remote := range remotes {
+		name := remote.Config().Name
+		fmt.Fprintf(writer, "Fetching %s\n", name)
+		err := remote.Fetch(&git.FetchOptions{
+			RemoteName: name,
+			Progress:   writer,
+			Prune:      true,
+		})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return writer.String(), fmt.Errorf("could not fetch %s: %w", name, err)
+		}
+	}
+	return writer.String(), nil
+}
+
+// 現在のブランチに上流の変更を取り込む（go-gitでは早送りだけ）
+func (r *goGitRepository) Pull(mode PullMode, progress func(line string)) (string, error) {
+	if mode != pullFastForward {
+		return "", errNotSupported
+	}
+	repo, err := r.openForNetwork()
+	if err != nil {
+		return "", err
+	}
+	_, branch, err := currentUpstream(repo)
+	if err != nil {
+		return "", err
+	}
+	before, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	writer := &progressWriter{progress: progress}
+	err = worktree.Pull(&git.PullOptions{
+		RemoteName:    branch.Remote,
+		ReferenceName: branch.Merge,
+		Progress:      writer,
+	})
+	switch {
+	case errors.Is(err, git.NoErrAlreadyUpToDate):
+		return "Already up to date.", nil
+	case errors.Is(err, git.ErrNonFastForwardUpdate):
+		return writer.String(), errors.New("not possible to fast-forward, aborting")
+	case err != nil:
+		return writer.String(), err
+	}
+	after, err := repo.Head()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Updating %s..%s\nFast-forward", before.Hash().String()[:7], after.Hash().String()[:7]), nil
+}
+
+// 現在のブランチを上流にpushする
+func (r *goGitRepository) Push(force bool, progress func(line string)) (string, error) {
+	repo, err := r.openForNetwork()
+	if err != nil {
+		return "", err
+	}
+	name, branch, err := currentUpstream(repo)
+	if err != nil {
+		return "", err
+	}
+	// gitコマンドのpush.default=simpleと同じく、上流のブランチ名が同じ場合だけpushする
+	if branch.Merge != name {
+		return "", fmt.Errorf("the upstream branch of your current branch does not match the name of your current branch")
+	}
+
+	writer := &progressWriter{progress: progress}
+	options := &git.PushOptions{
+		RemoteName: branch.Remote,
+		RefSpecs:   []config.RefSpec{config.RefSpec(fmt.Sprintf("%s:%s", name, branch.Merge))},
+		Progress:   writer,
+	}
+	if force {
+		// リモート追跡ブランチが指しているコミットのままの場合だけ上書きする
+		options.ForceWithLease = &git.ForceWithLease{}
+	}
+	err = repo.Push(options)
+	if errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return "Everything up-to-date", nil
+	} else if err != nil {
+		return writer.String(), err
+	}
+	return fmt.Sprintf("%s -> %s/%s", name.Short(), branch.Remote, branch.Merge.Short()), nil
+}
+
+// スタッシュを新しい順に取得
+// go-gitはreflogを読めないので、スタッシュのreflogのファイルを直接読む
+func (r *goGitRepository) Stashes() ([]Stash, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	storage, ok := r.repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return nil, nil
+	}
+	data, err := os.ReadFile(filepath.Join(storage.Filesystem().Root(), "logs", stashRef))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	stashes := parseStashLog(string(data))
+	for i, stash := range stashes {
+		commit, err := r.repo.CommitObject(plumbing.NewHash(stash.Hash))
+		if err != nil {
+			return nil, err
+		}
+		if len(commit.ParentHashes) > 0 {
+			stashes[i].Base = commit.ParentHashes[0].String()
+		}
+		stashes[i].Untracked = len(commit.ParentHashes) > 2
+	}
+	return stashes, nil
+}
+
+// 未コミットの変更をスタッシュする
+// go-gitにはスタッシュの機能がない
+func (r *goGitRepository) StashChanges(message string, includeUntracked bool) (string, error) {
+	return "", errNotSupported
+}
+
+// スタッシュした変更を作業ツリーに適用する
+// go-gitには3-wayマージの機能がない
+func (r *goGitRepository) ApplyStash(name string, pop bool) (string, error) {
+	return "", errNotSupported
+}
+
+// スタッシュを削除する
+// go-gitはreflogを書き換えられない
+func (r *goGitRepository) DropStash(name string) (string, error) {
+	return "", errNotSupported
+}
+
+// スタッシュからブランチを作成する
+// go-gitには3-wayマージの機能がない
+func (r *goGitRepository) StashBranch(name, branch string) (string, error) {
+	return "", errNotSupported
+}
+
+// コミットの変更を現在のHEADに適用する
+// go-gitには3-wayマージの機能がない
+func (r *goGitRepository) CherryPick(hash string) (string, error) {
+	return "", errNotSupported
+}
+
+// コミットの変更を打ち消すコミットを作成する
+// go-gitには3-wayマージの機能がない
+func (r *goGitRepository) Revert(hash string) (string, error) {
+	return "", errNotSupported
+}
+
+// 現在のブランチをコミットに移動する
+func (r *goGitRepository) Reset(hash string, mode ResetMode) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	commit, err := r.repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return "", err
+	}
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	if mode == resetSoft {
+		return "", worktree.Reset(&git.ResetOptions{Commit: commit.Hash, Mode: git.SoftReset})
+	}
+
+	// go-gitのHardResetは追跡していないファイルも削除してしまうので、
+	// インデックスを戻してから、前から追跡していなかったファイルを除いて作業ツリーを戻す
+	before, err := worktree.Status()
+	if err != nil {
+		return "", err
+	}
+	if err := worktree.Reset(&git.ResetOptions{Commit: commit.Hash, Mode: git.MixedReset}); err != nil {
+		return "", err
+	}
+	if mode == resetMixed {
+		return "", nil
+	}
+	after, err := worktree.Status()
+	if err != nil {
+		return "", err
+	}
+	var files []string
+	for file, status := range after {
+		if status.Worktree == git.Unmodified || before.IsUntracked(file) {
+			continue
+		}
+		files = append(files, file)
+	}
+	if len(files) > 0 {
+		if err := worktree.Reset(&git.ResetOptions{Commit: commit.Hash, Mode: git.HardReset, Files: files}); err != nil {
+			return "", err
+		}
+	}
+	subject, _, _ := strings.Cut(commit.Message, "\n")
+	return fmt.Sprintf("HEAD is now at %s %s", hash[:7], subject), nil
+}
+
+// sourceを現在のHEADにマージしたときに取り込まれるものを見積もる
+func (r *goGitRepository) MergePreview(source string) (MergePreview, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(source))
+	if err != nil {
+		return MergePreview{}, err
+	}
+	head, err := r.repo.Head()
+	if err != nil {
+		return MergePreview{}, err
+	}
+	sourceAncestors, err := r.ancestors(*hash)
+	if err != nil {
+		return MergePreview{}, err
+	}
+	headAncestors, err := r.ancestors(head.Hash())
+	if err != nil {
+		return MergePreview{}, err
+	}
+	return MergePreview{
+		Commits:     countMissing(sourceAncestors, headAncestors),
+		FastForward: sourceAncestors[head.Hash()],
+	}, nil
+}
+
+// sourceを現在のHEADにマージする（go-gitでは早送りだけ）
+// go-gitのMergeResetは追跡していないファイルを削除し、ステージしていない変更があると失敗するので、
+// gitコマンドと同じく、HEADとの間で変わるファイルに変更がないことを確かめてからそのファイルだけを更新する
+func (r *goGitRepository) Merge(source string, mode MergeMode, message string) (string, error) {
+	if mode != mergeFastForward {
+		return "", errNotSupported
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	hash, err := r.repo.ResolveRevision(plumbing.Revision(source))
+	if err != nil {
+		return "", err
+	}
+	head, err := r.repo.Head()
+	if err != nil {
+		return "", err
+	}
+	if head.Hash() == *hash {
+		return "Already up to date.", nil
+	}
+	ancestors, err := r.ancestors(*hash)
+	if err != nil {
+		return "", err
+	}
+	if !ancestors[head.Hash()] {
+		return "", errors.New("not possible to fast-forward, aborting")
+	}
+
+	files, err := r.changedFiles(head.Hash(), *hash)
+	if err != nil {
+		return "", err
+	}
+	worktree, err := r.repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return "", err
+	}
+	var dirty []string
+	for _, file := range files {
+		if s, ok := status[file]; ok && (s.Staging != git.Unmodified || s.Worktree != git.Unmodified) {
+			dirty = append(dirty, file)
+		}
+	}
+	if len(dirty) > 0 {
+		return "", fmt.Errorf("your local changes to the following files would be overwritten by merge: %s", strings.Join(dirty, ", "))
+	}
+
+	options := &git.ResetOptions{Commit: *hash, Mode: git.HardReset, Files: files}
+	if len(files) == 0 {
+		// Filesが空だとすべてのファイルを戻してしまう
+		options.Mode = git.SoftReset
+	}
+	if err := worktree.Reset(options); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Updating %s..%s\nFast-forward", head.Hash().String()[:7], hash.String()[:7]), nil
+}
+
+// 2つのコミットの間で変わったファイル
+func (r *goGitRepository) changedFiles(from, to plumbing.Hash) ([]string, error) {
+	var trees []*object.Tree
+	for _, hash := range []plumbing.Hash{from, to} {
+		commit, err := r.repo.CommitObject(hash)
+		if err != nil {
+			return nil, err
+		}
+		tree, err := commit.Tree()
+		if err != nil {
+			return nil, err
+		}
+		trees = append(trees, tree)
+	}
+	changes, err := object.DiffTree(trees[0], trees[1])
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, change := range changes {
+		if change.From.Name != "" {
+			files = append(files, change.From.Name)
+		}
+		if change.To.Name != "" && change.To.Name != change.From.Name {
+			files = append(files, change.To.Name)
+		}
+	}
+	return files, nil
+}
+
+// baseより後のコミットを手順のとおりにリベースする
+// go-gitには3-wayマージの機能がない
+func (r *goGitRepository) Rebase(base string, steps []RebaseStep) (string, error) {
+	return "", errNotSupported
+}
+
+// 途中で止まっている操作を取得
+// 衝突したファイルはインデックスでステージ番号が付いているエントリから取得する
+func (r *goGitRepository) Operation() (Operation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	storage, ok := r.repo.Storer.(*filesystem.Storage)
+	if !ok {
+		return Operation{}, nil
+	}
+	operation := readOperation(storage.Filesystem().Root())
+	if operation.Kind == operationNone {
+		return operation, nil
+	}
+	idx, err := r.repo.Storer.Index()
+	if err != nil {
+		return Operation{}, err
+	}
+	for _, entry := range idx.Entries {
+		// go-gitのindex.Mergedは1と定義されているが、衝突していないエントリのステージ番号は0
+		if entry.Stage != 0 && !slices.Contains(operation.Conflicts, entry.Name) {
+			operation.Conflicts = append(operation.Conflicts, entry.Name)
+		}
+	}
+	return operation, nil
+}
+
+// 途中で止まっている操作を続ける、中止する、または今のコミットを飛ばす
+// go-gitにはマージやリベースの機能がない
+func (r *goGitRepository) FinishOperation(kind OperationKind, action OperationAction) (string, error) {
+	return "", errNotSupported
+}
@@ -0,0 +1,1355 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// テスト用にメモリ上のデータを返すRepositoryの実装
+// テストで使わない操作は埋め込んだnilのRepositoryに任せる（呼び出すとpanicする）
+type fakeRepository struct {
+	Repository
+	head        string   // HEADが指しているコミット
+	branch      string   // HEADが指しているブランチ（空ならdetached HEAD）
+	refs        []Ref    // Refsで返すref
+	commits     []Commit // Logで返すコミット（新しい順）
+	uncommitted string   // 未コミットの変更の概要（空なら変更なし）
+	logErr      error    // Logで返すエラー
+}
+
+func (r *fakeRepository) HeadCommitHash() (string, error) {
+	if r.head == "" {
+		return "", fmt.Errorf("no HEAD")
+	}
+	return r.head, nil
+}
+
+func (r *fakeRepository) CurrentBranchName() (string, bool) {
+	return r.branch, r.branch != ""
+}
+
+func (r *fakeRepository) Refs() ([]Ref, error) {
+	return r.refs, nil
+}
+
+func (r *fakeRepository) HasUncommittedChanges() bool {
+	return r.uncommitted != ""
+}
+
+func (r *fakeRepository) UncommittedChangesSummary() (string, error) {
+	return r.uncommitted, nil
+}
+
+func (r *fakeRepository) UserName() string {
+	return "Tester"
+}
+
+func (r *fakeRepository) Log(filter LogFilter) (LogReader, error) {
+	if r.logErr != nil {
+		return nil, r.logErr
+	}
+	var commits []Commit
+	for _, commit := range r.commits {
+		if filter.Author == "" || strings.Contains(commit.Author, filter.Author) {
+			commits = append(commits, commit)
+		}
+	}
+	return &fakeLogReader{commits: commits}, nil
+}
+
+func (r *fakeRepository) CommitDetail(hash string) (CommitDetail, error) {
+	for _, commit := range r.commits {
+		if commit.Hash == hash {
+			return CommitDetail{Hash: hash, Parents: commit.Parents, Author: commit.Author, Message: commit.Message}, nil
+		}
+	}
+	return CommitDetail{}, fmt.Errorf("unknown commit: %s", hash)
+}
+
+func (r *fakeRepository) CommitDiff(hash string) (string, error) {
+	return "", nil
+}
+
+func (r *fakeRepository) SwitchBranch(branchName string) (string, error) {
+	r.branch = branchName
+	return "", nil
+}
+
+func (r *fakeRepository) CheckoutDetached(hash string) (string, error) {
+	r.head, r.branch = hash, ""
+	return "", nil
+}
+
+// fakeRepositoryのコミットを順に返すLogReader
+type fakeLogReader struct {
+	commits []Commit
+}
+
+func (l *fakeLogReader) Next(n int) ([]Commit, error) {
+	if n >= len(l.commits) {
+		page := l.commits
+		l.commits = nil
+		return page, io.EOF
+	}
+	page := l.commits[:n]
+	l.commits = l.commits[n:]
+	return page, nil
+}
+
+func (l *fakeLogReader) Close() error {
+	return nil
+}
+
+// テスト用の一時的なGitリポジトリ
+// コミットの日時を固定して、どちらの実装でも同じ結果になるようにする
+type testRepo struct {
+	t    *testing.T
+	dir  string
+	time int // 次のコミットの日時（秒）
+}
+
+// 空のリポジトリを作成する（gitコマンドがなければテストをスキップ）
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	r := &testRepo{t: t, dir: t.TempDir(), time: 1700000000}
+	r.git("init", "-q", "-b", "master")
+	r.git("config", "user.name", "Tester")
+	r.git("config", "user.email", "tester@example.com")
+	r.git("config", "commit.gpgsign", "false")
+	return r
+}
+
+// gitコマンドを実行して出力を返す
+func (r *testRepo) git(args ...string) string {
+	r.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = r.dir
+	date := fmt.Sprintf("@%d +0000", r.time)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_DATE="+date, "GIT_COMMITTER_DATE="+date,
+		"GIT_CONFIG_GLOBAL=/dev/null", "GIT_CONFIG_NOSYSTEM=1")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// ファイルを書き込む
+func (r *testRepo) write(path, content string) {
+	r.t.Helper()
+	full := filepath.Join(r.dir, path)
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		r.t.Fatal(err)
+	}
+	if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+		r.t.Fatal(err)
+	}
+}
+
+// ファイルを書き込んでコミットし、コミットのハッシュを返す
+func (r *testRepo) commit(path, content, message string) string {
+	r.t.Helper()
+	r.write(path, content)
+	r.git("add", "--", path)
+	r.git("commit", "-q", "-m", message)
+	r.time += 60
+	return r.git("rev-parse", "HEAD")
+}
+
+// 両方の実装でリポジトリを開く
+func (r *testRepo) backends() map[string]Repository {
+	r.t.Helper()
+	goRepo, err := newGoGitRepository(r.dir)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return map[string]Repository{
+		"exec": newExecRepository(r.dir),
+		"go":   goRepo,
+	}
+}
+
+// コミットログをすべて読み込んでハッシュのリストにする
+func logHashes(t *testing.T, repo Repository, filter LogFilter) []string {
+	t.Helper()
+	reader, err := repo.Log(filter)
+	if err != nil {
+		t.Fatalf("Log(%+v): %v", filter, err)
+	}
+	defer reader.Close()
+
+	var hashes []string
+	for {
+		commits, err := reader.Next(2) // ページの境界も確認できるように小さく読む
+		for _, commit := range commits {
+			hashes = append(hashes, commit.Hash)
+		}
+		if err == io.EOF {
+			return hashes
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+	}
+}
+
+func TestBackendsReadRepository(t *testing.T) {
+	r := newTestRepo(t)
+	c1 := r.commit("a.txt", "one\n", "first")
+	r.git("branch", "feature")
+	c2 := r.commit("a.txt", "one\ntwo\n", "second\n\nbody line")
+	r.git("tag", "v1.0")
+	r.git("tag", "-a", "v1.0-annotated", "-m", "release")
+
+	for name, repo := range r.backends() {
+		t.Run(name, func(t *testing.T) {
+			if head, err := repo.HeadCommitHash(); err != nil || head != c2 {
+				t.Errorf("HeadCommitHash() = %q, %v; want %q", head, err, c2)
+			}
+			if branch, ok := repo.CurrentBranchName(); !ok || branch != "master" {
+				t.Errorf("CurrentBranchName() = %q, %v", branch, ok)
+			}
+
+			refs, err := repo.Refs()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]string{}
+			for _, ref := range refs {
+				got[ref.FullName] = ref.Hash
+			}
+			want := map[string]string{
+				"refs/heads/master":        c2,
+				"refs/heads/feature":       c1,
+				"refs/tags/v1.0":           c2,
+				"refs/tags/v1.0-annotated": c2,
+			}
+			for fullName, hash := range want {
+				if got[fullName] != hash {
+					t.Errorf("ref %s = %q; want %q", fullName, got[fullName], hash)
+				}
+			}
+
+			if hashes := logHashes(t, repo, LogFilter{}); strings.Join(hashes, " ") != c2+" "+c1 {
+				t.Errorf("Log() = %v; want [%s %s]", hashes, c2, c1)
+			}
+
+			detail, err := repo.CommitDetail(c2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if detail.Author != "Tester" || !strings.Contains(detail.Message, "body line") || len(detail.Parents) != 1 || detail.Parents[0] != c1 {
+				t.Errorf("CommitDetail() = %+v", detail)
+			}
+			if len(detail.Files) != 1 || detail.Files[0].Path != "a.txt" || detail.Files[0].Additions != 1 {
+				t.Errorf("CommitDetail().Files = %+v", detail.Files)
+			}
+
+			diff, err := repo.CommitDiff(c2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(diff, "\n+two\n") {
+				t.Errorf("CommitDiff() = %q", diff)
+			}
+		})
+	}
+}
+
+func TestBackendsUncommittedChanges(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("a.txt", "one\n", "first")
+
+	for name, repo := range r.backends() {
+		if repo.HasUncommittedChanges() {
+			t.Errorf("%s: clean tree reported as changed", name)
+		}
+	}
+
+	r.write("a.txt", "changed\n")
+	r.write("b.txt", "new\n")
+	for name, repo := range r.backends() {
+		if !repo.HasUncommittedChanges() {
+			t.Errorf("%s: changes not detected", name)
+		}
+		if summary, err := repo.UncommittedChangesSummary(); err != nil || summary != "2 files changed" {
+			t.Errorf("%s: UncommittedChangesSummary() = %q, %v", name, summary, err)
+		}
+	}
+}
+
+func TestBackendsLogDateOrder(t *testing.T) {
+	// rebaseやcherry-pickのように同じ秒に作られたコミットでも、子が親より先に並ぶ
+	r := newTestRepo(t)
+	r.commit("a.txt", "1\n", "c1")
+	r.time -= 60
+	r.git("checkout", "-q", "-b", "side")
+	r.commit("b.txt", "1\n", "s1")
+	r.time -= 60
+	r.commit("b.txt", "2\n", "s2")
+	r.time -= 60
+	r.git("checkout", "-q", "master")
+	for i := 2; i <= 5; i++ {
+		r.commit("a.txt", fmt.Sprintf("%d\n", i), fmt.Sprintf("c%d", i))
+		r.time -= 60
+	}
+	r.git("merge", "-q", "--no-ff", "-m", "merge", "side")
+
+	for name, repo := range r.backends() {
+		reader, err := repo.Log(LogFilter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		commits, _ := reader.Next(100)
+		reader.Close()
+		if len(commits) != 8 {
+			t.Fatalf("%s: Log() returned %d commits", name, len(commits))
+		}
+
+		position := map[string]int{}
+		for i, commit := range commits {
+			position[commit.Hash] = i
+		}
+		for i, commit := range commits {
+			for _, parent := range commit.Parents {
+				if position[parent] <= i {
+					t.Errorf("%s: parent %s of %s (%s) listed before it", name, parent[:7], commit.Hash[:7], commit.Message)
+				}
+			}
+		}
+	}
+}
+
+// コミットログをハッシュと親の組で比較できる文字列にする
+func logGraph(t *testing.T, repo Repository, filter LogFilter) []string {
+	t.Helper()
+	reader, err := repo.Log(filter)
+	if err != nil {
+		t.Fatalf("Log(%+v): %v", filter, err)
+	}
+	defer reader.Close()
+	commits, err := reader.Next(1000)
+	if err != nil && err != io.EOF {
+		t.Fatal(err)
+	}
+
+	var lines []string
+	for _, commit := range commits {
+		lines = append(lines, commit.Message+" <- "+strings.Join(commit.Parents, " "))
+	}
+	return lines
+}
+
+func TestBackendsLogFilters(t *testing.T) {
+	r := newTestRepo(t)
+	c1 := r.commit("f1", "1\n", "c1")
+	r.commit("f2", "2\n", "c2")
+	r.git("checkout", "-q", "-b", "side", c1)
+	r.commit("x", "x\n", "s1")
+	r.commit("f1", "changed\n", "s2")
+	r.git("checkout", "-q", "master")
+	r.commit("f5", "5\n", "c3")
+	r.git("merge", "-q", "--no-ff", "-m", "merge", "side")
+	r.time += 60
+	r.git("checkout", "-q", "-b", "other", "HEAD~1")
+	r.commit("f5", "other\n", "o1")
+	r.git("checkout", "-q", "master")
+	r.commit("f2", "changed\n", "c4")
+
+	filters := []LogFilter{
+		{},
+		{Path: "f5"},
+		{Path: "x"},
+		{Path: "f1"},
+		{Ref: "master", Path: "f5"},
+		{Ref: "side"},
+		{Author: "Tester", Path: "f2"},
+	}
+
+	repos := r.backends()
+	for _, filter := range filters {
+		want := logGraph(t, repos["exec"], filter)
+		got := logGraph(t, repos["go"], filter)
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("Log(%+v):\ngo:\n  %s\nexec:\n  %s", filter, strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+		}
+	}
+}
+
+func TestExecLogRejectsOptionLikeRef(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("a.txt", "1\n", "c1")
+
+	// refがオプションとして解釈されるとファイルが書き込まれてしまう
+	output := filepath.Join(t.TempDir(), "out")
+	reader, err := newExecRepository(r.dir).Log(LogFilter{Ref: "--output=" + output})
+	if err == nil {
+		_, err = reader.Next(10)
+		reader.Close()
+	}
+	if err == nil || err == io.EOF {
+		t.Error("Log() with an option-like ref succeeded")
+	}
+	if _, statErr := os.Stat(output); statErr == nil {
+		t.Errorf("ref was interpreted as an option and wrote %s", output)
+	}
+}
+
+func TestBackendsLogAuthorFilterGraph(t *testing.T) {
+	// 作者で絞り込むと親が表示されないので、グラフに線が残らないようにする
+	r := newTestRepo(t)
+	for i := 1; i <= 6; i++ {
+		author := "Alice <alice@example.com>"
+		if i%2 == 0 {
+			author = "Bob <bob@example.com>"
+		}
+		r.write("a.txt", fmt.Sprintf("%d\n", i))
+		r.git("add", "a.txt")
+		r.git("commit", "-q", "--author="+author, "-m", fmt.Sprintf("c%d", i))
+		r.time += 60
+	}
+
+	for name, repo := range r.backends() {
+		reader, err := repo.Log(LogFilter{Author: "Bob"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		commits, _ := reader.Next(100)
+		reader.Close()
+
+		newGraphBuilder(asciiGraphGlyphs).Add(commits)
+		if got := graphRows(commits); strings.Join(got, ",") != "*,*,*" {
+			t.Errorf("%s: graph = %q", name, got)
+		}
+	}
+}
+
+func TestBackendsLogAuthorRegexp(t *testing.T) {
+	// git log --authorと同じく、作者は"名前 <メール>"に対する正規表現として扱う
+	r := newTestRepo(t)
+	for i, author := range []string{"Alice <alice@example.com>", "Malice <malice@example.com>", "Bob <bob@example.org>"} {
+		r.write("a.txt", fmt.Sprintf("%d\n", i))
+		r.git("add", "a.txt")
+		r.git("commit", "-q", "--author="+author, "-m", author)
+		r.time += 60
+	}
+
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"^Alice", []string{"Alice <alice@example.com>"}},
+		{"lice <", []string{"Malice <malice@example.com>", "Alice <alice@example.com>"}},
+		{"example[.]org>$", []string{"Bob <bob@example.org>"}},
+	}
+	for _, tt := range tests {
+		for name, repo := range r.backends() {
+			reader, err := repo.Log(LogFilter{Author: tt.pattern})
+			if err != nil {
+				t.Fatalf("%s: Log(%q): %v", name, tt.pattern, err)
+			}
+			commits, _ := reader.Next(100)
+			reader.Close()
+			var got []string
+			for _, commit := range commits {
+				got = append(got, commit.Message)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("%s: Log(%q) = %q, want %q", name, tt.pattern, got, tt.want)
+			}
+		}
+	}
+}
+
+// ファイルの状態をパスと状態の文字の組にする
+func statusSummary(t *testing.T, repo Repository) []string {
+	t.Helper()
+	files, err := repo.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var summary []string
+	for _, file := range files {
+		summary = append(summary, fmt.Sprintf("%c%c %s", file.Index, file.Worktree, file.Path))
+	}
+	sort.Strings(summary)
+	return summary
+}
+
+func TestBackendsStatusAndStaging(t *testing.T) {
+	for _, backend := range []string{"exec", "go"} {
+		t.Run(backend, func(t *testing.T) {
+			r := newTestRepo(t)
+			r.commit("a.txt", "one\n", "first")
+			r.commit("d.txt", "delete me\n", "second")
+			repo := r.backends()[backend]
+
+			r.write("a.txt", "one\ntwo\n")
+			r.write("b.txt", "untracked\n")
+			r.write("c.txt", "staged\n")
+			r.git("add", "c.txt")
+			os.Remove(filepath.Join(r.dir, "d.txt"))
+
+			want := []string{".D d.txt", ".M a.txt", "?? b.txt", "A. c.txt"}
+			if got := statusSummary(t, repo); !reflect.DeepEqual(got, want) {
+				t.Fatalf("Status() = %q; want %q", got, want)
+			}
+
+			diff, err := repo.FileDiff(FileStatus{Path: "a.txt", Index: '.', Worktree: 'M'}, false)
+			if err != nil || !strings.Contains(diff, "\n+two\n") {
+				t.Errorf("FileDiff(a.txt) = %q, %v", diff, err)
+			}
+			diff, err = repo.FileDiff(FileStatus{Path: "c.txt", Index: 'A', Worktree: '.'}, true)
+			if err != nil || !strings.Contains(diff, "\n+staged\n") {
+				t.Errorf("FileDiff(c.txt, staged) = %q, %v", diff, err)
+			}
+			untracked := FileStatus{Path: "b.txt", Index: '?', Worktree: '?', Untracked: true}
+			diff, err = repo.FileDiff(untracked, false)
+			if err != nil || !strings.Contains(diff, "\n+untracked\n") {
+				t.Errorf("FileDiff(b.txt) = %q, %v", diff, err)
+			}
+
+			for _, path := range []string{"a.txt", "b.txt", "d.txt"} {
+				if err := repo.StageFile(FileStatus{Path: path}); err != nil {
+					t.Fatalf("StageFile(%s): %v", path, err)
+				}
+			}
+			if err := repo.UnstageFile(FileStatus{Path: "c.txt"}); err != nil {
+				t.Fatal(err)
+			}
+			want = []string{"?? c.txt", "A. b.txt", "D. d.txt", "M. a.txt"}
+			if got := statusSummary(t, repo); !reflect.DeepEqual(got, want) {
+				t.Fatalf("Status() after staging = %q; want %q", got, want)
+			}
+
+			// 作業ツリーの変更だけを捨て、インデックスの内容に戻す
+			r.write("a.txt", "one\ntwo\nthree\n")
+			if err := repo.DiscardFile(FileStatus{Path: "a.txt", Index: 'M', Worktree: 'M'}); err != nil {
+				t.Fatal(err)
+			}
+			if content, _ := os.ReadFile(filepath.Join(r.dir, "a.txt")); string(content) != "one\ntwo\n" {
+				t.Errorf("a.txt after discard = %q", content)
+			}
+			if err := repo.DiscardFile(FileStatus{Path: "c.txt", Untracked: true}); err != nil {
+				t.Fatal(err)
+			}
+			want = []string{"A. b.txt", "D. d.txt", "M. a.txt"}
+			if got := statusSummary(t, repo); !reflect.DeepEqual(got, want) {
+				t.Errorf("Status() after discard = %q; want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestBackendsCommit(t *testing.T) {
+	for _, backend := range []string{"exec", "go"} {
+		t.Run(backend, func(t *testing.T) {
+			r := newTestRepo(t)
+			r.commit("a.txt", "one\n", "first")
+			repo := r.backends()[backend]
+
+			if template, err := repo.CommitTemplate(); err != nil || template != "" {
+				t.Errorf("CommitTemplate() without config = %q, %v", template, err)
+			}
+			r.write(".gitmessage", "# Why?\n")
+			r.git("config", "commit.template", ".gitmessage")
+			if template, err := repo.CommitTemplate(); err != nil || template != "# Why?\n" {
+				t.Errorf("CommitTemplate() = %q, %v", template, err)
+			}
+
+			r.write("a.txt", "one\ntwo\n")
+			r.git("add", "a.txt")
+			if _, err := repo.Commit("Add two\n\n# comment\nBody\n", false); err != nil {
+				t.Fatal(err)
+			}
+			if got := r.git("log", "-1", "--format=%B"); got != "Add two\n\nBody" {
+				t.Errorf("message = %q", got)
+			}
+			if message, err := repo.HeadCommitMessage(); err != nil || message != "Add two\n\nBody\n" {
+				t.Errorf("HeadCommitMessage() = %q, %v", message, err)
+			}
+
+			// amendしてもコミットの数は増えない
+			if _, err := repo.Commit("Add line two\n", true); err != nil {
+				t.Fatal(err)
+			}
+			if got := r.git("log", "--format=%s"); got != "Add line two\nfirst" {
+				t.Errorf("log after amend = %q", got)
+			}
+		})
+	}
+}
+
+func TestCleanupMessage(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"", ""},
+		{"# only a comment\n", ""},
+		{"Subject", "Subject\n"},
+		{"\n\nSubject  \n\n\n\nBody\n# comment\n\n", "Subject\n\nBody\n"},
+		{"Subject\n#comment\n \nBody\t\n", "Subject\n\nBody\n"},
+	}
+	for _, tt := range tests {
+		if got := cleanupMessage(tt.message); got != tt.want {
+			t.Errorf("cleanupMessage(%q) = %q; want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+// ローカルブランチの名前 -> 指しているコミットと上流
+func localBranches(t *testing.T, repo Repository) map[string]string {
+	t.Helper()
+	refs, err := repo.Refs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	branches := map[string]string{}
+	for _, ref := range refs {
+		if ref.Kind == RefLocalBranch {
+			branches[ref.Name] = ref.Hash[:7] + " " + ref.Upstream
+		}
+	}
+	return branches
+}
+
+func TestBackendsBranchActions(t *testing.T) {
+	for _, backend := range []string{"exec", "go"} {
+		t.Run(backend, func(t *testing.T) {
+			r := newTestRepo(t)
+			c1 := r.commit("a.txt", "one\n", "first")
+			c2 := r.commit("a.txt", "two\n", "second")
+			r.git("update-ref", "refs/remotes/origin/master", c1)
+			r.git("config", "remote.origin.url", "https://example.com/repo.git")
+			r.git("config", "remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*")
+			repo := r.backends()[backend]
+
+			if err := repo.CreateBranch("topic", c1); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.CreateBranch("topic", c2); err == nil {
+				t.Error("CreateBranch() with an existing name succeeded")
+			}
+			if err := repo.CreateBranch("-x", c2); err == nil {
+				t.Error("CreateBranch() with an option-like name succeeded")
+			}
+			if err := repo.SetUpstream("topic", "origin/master"); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.RenameBranch("topic", "feature"); err != nil {
+				t.Fatal(err)
+			}
+			// 現在のブランチの名前を変えるとHEADも付いていく
+			if err := repo.RenameBranch("master", "main"); err != nil {
+				t.Fatal(err)
+			}
+			if branch, ok := repo.CurrentBranchName(); !ok || branch != "main" {
+				t.Errorf("CurrentBranchName() after rename = %q, %v", branch, ok)
+			}
+			if err := repo.SetUpstream("main", "feature"); err != nil {
+				t.Fatal(err)
+			}
+			want := map[string]string{
+				"feature": c1[:7] + " origin/master",
+				"main":    c2[:7] + " feature",
+			}
+			if got := localBranches(t, repo); !reflect.DeepEqual(got, want) {
+				t.Errorf("branches = %v; want %v", got, want)
+			}
+
+			if err := repo.SetUpstream("feature", ""); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.SetUpstream("feature", ""); err == nil {
+				t.Error("SetUpstream() without an upstream succeeded")
+			}
+			if err := repo.SetUpstream("feature", "origin/missing"); err == nil {
+				t.Error("SetUpstream() to a missing branch succeeded")
+			}
+
+			// HEADにマージされていないブランチは強制しなければ削除しない
+			r.git("branch", "unmerged", c2)
+			r.git("checkout", "-q", "unmerged")
+			r.commit("b.txt", "b\n", "unmerged work")
+			r.git("checkout", "-q", "main")
+			if err := repo.DeleteBranch("unmerged", false); !errors.Is(err, errBranchNotMerged) {
+				t.Errorf("DeleteBranch(unmerged) = %v; want errBranchNotMerged", err)
+			}
+			if err := repo.DeleteBranch("unmerged", true); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.DeleteBranch("feature", false); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.DeleteBranch("main", true); err == nil {
+				t.Error("DeleteBranch() of the current branch succeeded")
+			}
+			// 削除したブランチを上流にしている設定は残る（gitと同じ）
+			want = map[string]string{"main": c2[:7] + " feature"}
+			if got := localBranches(t, repo); !reflect.DeepEqual(got, want) {
+				t.Errorf("branches after delete = %v; want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestBackendsBranches(t *testing.T) {
+	r := newTestRepo(t)
+	c1 := r.commit("a.txt", "1\n", "first")
+	r.commit("a.txt", "2\n", "second")
+	r.git("update-ref", "refs/remotes/origin/master", c1)
+	r.git("config", "remote.origin.url", "https://example.com/repo.git")
+	r.git("config", "remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*")
+	r.git("config", "branch.master.remote", "origin")
+	r.git("config", "branch.master.merge", "refs/heads/master")
+	r.git("branch", "old", c1)
+	r.git("checkout", "-q", "-b", "topic", c1)
+	r.commit("b.txt", "b\n", "topic work")
+	r.git("config", "branch.topic.remote", ".")
+	r.git("config", "branch.topic.merge", "refs/heads/master")
+	r.git("config", "branch.old.remote", "origin")
+	r.git("config", "branch.old.merge", "refs/heads/removed")
+	r.git("checkout", "-q", "master")
+
+	for name, repo := range r.backends() {
+		t.Run(name, func(t *testing.T) {
+			branches, err := repo.Branches()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, branch := range sortBranches(branches, branchOrderName) {
+				got = append(got, fmt.Sprintf("%s [%s] %s merged=%v", branch.Name, branch.Tracking(), branch.Subject, branch.Merged))
+			}
+			want := []string{
+				"master [origin/master ↑1] second merged=true",
+				"old [origin/removed gone] first merged=true",
+				"topic [master ↑1 ↓1] topic work merged=false",
+				"origin/master [] first merged=true",
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Branches() =\n  %s\nwant\n  %s", strings.Join(got, "\n  "), strings.Join(want, "\n  "))
+			}
+		})
+	}
+}
+
+func TestBackendsTags(t *testing.T) {
+	for _, backend := range []string{"exec", "go"} {
+		t.Run(backend, func(t *testing.T) {
+			r := newTestRepo(t)
+			c1 := r.commit("a.txt", "1\n", "first")
+			c2 := r.commit("a.txt", "2\n", "second")
+			repo := r.backends()[backend]
+
+			if err := repo.CreateTag("v1.0", c1, ""); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.CreateTag("v2.0", c2, "Release 2.0\n\nNotes"); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.CreateTag("v2.0", c1, ""); err == nil {
+				t.Error("CreateTag() with an existing name succeeded")
+			}
+
+			// 注釈付きタグも指しているコミットに展開される
+			if got := r.git("rev-parse", "v2.0^{commit}"); got != c2 {
+				t.Errorf("v2.0 points to %s; want %s", got, c2)
+			}
+			tag, err := repo.TagDetail("v2.0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tag.Annotated || tag.Tagger != "Tester" || tag.TaggerEmail != "tester@example.com" || tag.Message != "Release 2.0\n\nNotes\n" || tag.TaggerDate == "" {
+				t.Errorf("TagDetail(v2.0) = %+v", tag)
+			}
+			if tag, err := repo.TagDetail("v1.0"); err != nil || tag.Annotated {
+				t.Errorf("TagDetail(v1.0) = %+v, %v", tag, err)
+			}
+
+			if err := repo.DeleteTag("v1.0"); err != nil {
+				t.Fatal(err)
+			}
+			if err := repo.DeleteTag("v1.0"); err == nil {
+				t.Error("DeleteTag() of a missing tag succeeded")
+			}
+			if got := r.git("tag", "--list"); got != "v2.0" {
+				t.Errorf("tags = %q", got)
+			}
+		})
+	}
+}
+
+func TestBackendsSwitchTrackingBranch(t *testing.T) {
+	for _, backend := range []string{"exec", "go"} {
+		t.Run(backend, func(t *testing.T) {
+			r := newTestRepo(t)
+			r.commit("a.txt", "1\n", "first")
+			c2 := r.commit("a.txt", "2\n", "second")
+			r.git("update-ref", "refs/remotes/origin/feature/x", c2)
+			r.git("config", "remote.origin.url", "https://example.com/repo.git")
+			r.git("config", "remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*")
+			r.git("reset", "-q", "--hard", "HEAD~1")
+			repo := r.backends()[backend]
+
+			if _, err := repo.SwitchTrackingBranch("feature/x", "origin/feature/x"); err != nil {
+				t.Fatal(err)
+			}
+			if branch, ok := repo.CurrentBranchName(); !ok || branch != "feature/x" {
+				t.Errorf("CurrentBranchName() = %q, %v", branch, ok)
+			}
+			if head, _ := repo.HeadCommitHash(); head != c2 {
+				t.Errorf("HEAD = %s; want %s", head, c2)
+			}
+			if got := r.git("rev-parse", "--abbrev-ref", "feature/x@{upstream}"); got != "origin/feature/x" {
+				t.Errorf("upstream = %q", got)
+			}
+			if _, err := repo.SwitchTrackingBranch("feature/x", "origin/feature/x"); err == nil {
+				t.Error("SwitchTrackingBranch() with an existing branch succeeded")
+			}
+		})
+	}
+}
+
+func TestBackendsFetchPullPush(t *testing.T) {
+	for _, backend := range []string{"exec", "go"} {
+		t.Run(backend, func(t *testing.T) {
+			bare := filepath.Join(t.TempDir(), "remote.git")
+			if output, err := exec.Command("git", "init", "-q", "--bare", "-b", "master", bare).CombinedOutput(); err != nil {
+				t.Fatalf("git init: %v\n%s", err, output)
+			}
+
+			// 自分のリポジトリと、同じリモートにpushする別のリポジトリ
+			r := newTestRepo(t)
+			r.commit("a.txt", "1\n", "first")
+			r.git("remote", "add", "origin", bare)
+			r.git("push", "-q", "-u", "origin", "master")
+			other := newTestRepo(t)
+			other.git("remote", "add", "origin", bare)
+			other.git("fetch", "-q", "origin")
+			other.git("reset", "-q", "--hard", "origin/master")
+			c2 := other.commit("b.txt", "2\n", "second")
+			other.git("push", "-q", "origin", "master")
+			repo := r.backends()[backend]
+
+			var lines []string
+			if _, err := repo.Fetch(func(line string) { lines = append(lines, line) }); err != nil {
+				t.Fatal(err)
+			}
+			if got := r.git("rev-parse", "origin/master"); got != c2 {
+				t.Errorf("origin/master after Fetch() = %s; want %s", got, c2)
+			}
+			if len(lines) == 0 {
+				t.Error("Fetch() reported no progress")
+			}
+
+			if _, err := repo.Pull(pullFastForward, nil); err != nil {
+				t.Fatal(err)
+			}
+			if head, _ := repo.HeadCommitHash(); head != c2 {
+				t.Errorf("HEAD after Pull() = %s; want %s", head, c2)
+			}
+
+			c3 := r.commit("a.txt", "3\n", "third")
+			if _, err := repo.Push(false, nil); err != nil {
+				t.Fatal(err)
+			}
+			if got := other.git("ls-remote", bare, "refs/heads/master"); !strings.HasPrefix(got, c3) {
+				t.Errorf("remote master after Push() = %q; want %s", got, c3)
+			}
+
+			// 相手がpushしたコミットを取得していなければ、force-with-leaseでも上書きしない
+			other.git("fetch", "-q", "origin")
+			other.git("reset", "-q", "--hard", "origin/master")
+			c4 := other.commit("b.txt", "4\n", "fourth")
+			other.git("push", "-q", "origin", "master")
+			r.git("commit", "-q", "--amend", "-m", "third (amended)")
+			if _, err := repo.Push(false, nil); err == nil {
+				t.Error("Push() of a diverged branch succeeded")
+			}
+			if _, err := repo.Push(true, nil); err == nil {
+				t.Error("Push(force) over an unfetched commit succeeded")
+			}
+			if _, err := repo.Pull(pullFastForward, nil); err == nil {
+				t.Error("Pull(ff-only) of a diverged branch succeeded")
+			}
+			if got := r.git("rev-parse", "origin/master"); got != c4 {
+				t.Errorf("origin/master after Pull() = %s; want %s", got, c4)
+			}
+			if _, err := repo.Push(true, nil); err != nil {
+				t.Fatal(err)
+			}
+			amended := r.git("rev-parse", "HEAD")
+			if got := other.git("ls-remote", bare, "refs/heads/master"); !strings.HasPrefix(got, amended) {
+				t.Errorf("remote master after Push(force) = %q; want %s", got, amended)
+			}
+		})
+	}
+}
+
+func TestBackendsReset(t *testing.T) {
+	for _, backend := range []string{"exec", "go"} {
+		t.Run(backend, func(t *testing.T) {
+			r := newTestRepo(t)
+			c1 := r.commit("a.txt", "1\n", "first")
+			r.write("b.txt", "b\n")
+			r.git("add", "b.txt")
+			c2 := r.commit("a.txt", "2\n", "second")
+			repo := r.backends()[backend]
+
+			// soft: インデックスと作業ツリーは2番目のコミットのまま
+			if _, err := repo.Reset(c1, resetSoft); err != nil {
+				t.Fatal(err)
+			}
+			if head, _ := repo.HeadCommitHash(); head != c1 {
+				t.Errorf("HEAD after soft reset = %s; want %s", head, c1)
+			}
+			if got := r.git("diff", "--cached", "--name-only"); got != "a.txt\nb.txt" {
+				t.Errorf("staged after soft reset = %q", got)
+			}
+
+			// mixed: インデックスも戻し、作業ツリーの変更は残す
+			if _, err := repo.Reset(c1, resetMixed); err != nil {
+				t.Fatal(err)
+			}
+			if got := r.git("status", "--porcelain"); got != "M a.txt\n?? b.txt" {
+				t.Errorf("status after mixed reset = %q", got)
+			}
+
+			// hard: 作業ツリーの変更も捨て、戻したコミットにないファイルは削除する
+			// 追跡していなかったファイルは残す
+			r.git("reset", "-q", "--hard", c2)
+			r.write("a.txt", "modified\n")
+			r.write("untracked.txt", "x\n")
+			if _, err := repo.Reset(c1, resetHard); err != nil {
+				t.Fatal(err)
+			}
+			if got := r.git("status", "--porcelain"); got != "?? untracked.txt" {
+				t.Errorf("status after hard reset = %q", got)
+			}
+			if got := r.git("ls-files"); got != "a.txt" {
+				t.Errorf("tracked files after hard reset = %q", got)
+			}
+			if content, _ := os.ReadFile(filepath.Join(r.dir, "a.txt")); string(content) != "1\n" {
+				t.Errorf("a.txt after hard reset = %q", content)
+			}
+		})
+	}
+}
+
+func TestExecCherryPickAndRevert(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("a.txt", "1\n", "first")
+	r.git("switch", "-q", "-c", "feature")
+	picked := r.commit("b.txt", "b\n", "add b")
+	r.git("switch", "-q", "master")
+	r.commit("c.txt", "c\n", "add c")
+	repo := newExecRepository(r.dir)
+
+	if _, err := repo.CherryPick(picked); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("log", "-1", "--format=%s"); got != "add b" {
+		t.Errorf("subject after cherry-pick = %q", got)
+	}
+	if _, err := repo.Revert("HEAD"); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("log", "-1", "--format=%s"); got != `Revert "add b"` {
+		t.Errorf("subject after revert = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(r.dir, "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("b.txt after revert: %v", err)
+	}
+
+	// マージコミットは最初の親との差分を適用する
+	r.git("merge", "-q", "--no-ff", "-m", "merge feature", "feature")
+	merge := r.git("rev-parse", "HEAD")
+	r.git("reset", "-q", "--hard", "HEAD~1")
+	if _, err := repo.CherryPick(merge); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("show", "--format=", "--name-only", "HEAD"); got != "b.txt" {
+		t.Errorf("files of the cherry-picked merge = %q", got)
+	}
+
+	goRepo := r.backends()["go"]
+	if _, err := goRepo.CherryPick(picked); !errors.Is(err, errNotSupported) {
+		t.Errorf("go CherryPick() error = %v", err)
+	}
+}
+
+func TestExecRebase(t *testing.T) {
+	r := newTestRepo(t)
+	base := r.commit("a.txt", "1\n", "base")
+	c1 := r.commit("b.txt", "b\n", "add b")
+	c2 := r.commit("c.txt", "c\n", "add c")
+	c3 := r.commit("b.txt", "b2\n", "fix b")
+	c4 := r.commit("d.txt", "d\n", "add d")
+	repo := newExecRepository(r.dir)
+
+	// 並べ替え、fixup、reword、dropをまとめて行う
+	steps := []RebaseStep{
+		{Action: rebasePick, Hash: c1, Subject: "add b"},
+		{Action: rebaseFixup, Hash: c3, Subject: "fix b"},
+		{Action: rebaseReword, Hash: c2, Subject: "add c", Message: "add c (it's reworded)\n\nwith a body\n"},
+		{Action: rebaseDrop, Hash: c4, Subject: "add d"},
+	}
+	if _, err := repo.Rebase(base, steps); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("log", "--format=%s", base+"..HEAD"); got != "add c (it's reworded)\nadd b" {
+		t.Errorf("subjects after Rebase() = %q", got)
+	}
+	if got := r.git("log", "-1", "--format=%b"); got != "with a body" {
+		t.Errorf("body after Rebase() = %q", got)
+	}
+	if got := r.git("show", "HEAD~1:b.txt"); got != "b2" {
+		t.Errorf("b.txt after fixup = %q", got)
+	}
+	if got := r.git("ls-files"); got != "a.txt\nb.txt\nc.txt" {
+		t.Errorf("files after drop = %q", got)
+	}
+
+	// squashはメッセージをつなげ、editはそこで止まる
+	head := r.git("rev-parse", "HEAD")
+	steps = []RebaseStep{
+		{Action: rebaseEdit, Hash: r.git("rev-parse", "HEAD~1"), Subject: "add b"},
+		{Action: rebaseSquash, Hash: head, Subject: "add c"},
+	}
+	output, err := repo.Rebase(base, steps)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(output, "Stopped at") {
+		t.Errorf("output of an edit step = %q", output)
+	}
+	r.git("-c", "core.editor=true", "rebase", "--continue")
+	if got := r.git("log", "--format=%s", base+"..HEAD"); got != "add b" {
+		t.Errorf("subjects after squash = %q", got)
+	}
+	if got := r.git("log", "-1", "--format=%B"); !strings.Contains(got, "add c (it's reworded)") {
+		t.Errorf("message after squash = %q", got)
+	}
+}
+
+func TestBackendsOperation(t *testing.T) {
+	for _, backend := range []string{"exec", "go"} {
+		t.Run(backend, func(t *testing.T) {
+			r := newTestRepo(t)
+			base := r.commit("a.txt", "1\n", "base")
+			r.git("switch", "-q", "-c", "side")
+			side := r.commit("a.txt", "2\n", "side")
+			r.git("switch", "-q", "master")
+			r.commit("a.txt", "3\n", "master")
+			r.commit("b.txt", "b\n", "add b")
+			execRepo := newExecRepository(r.dir)
+			repo := r.backends()[backend]
+
+			operation := func() Operation {
+				t.Helper()
+				operation, err := repo.Operation()
+				if err != nil {
+					t.Fatal(err)
+				}
+				return operation
+			}
+			// gitコマンドで操作を終える（go-gitでは終えられない）
+			finish := func(kind OperationKind, action OperationAction) {
+				t.Helper()
+				if backend == "go" {
+					if _, err := repo.FinishOperation(kind, action); !errors.Is(err, errNotSupported) {
+						t.Errorf("go FinishOperation() error = %v", err)
+					}
+					repo = execRepo
+				}
+				if _, err := repo.FinishOperation(kind, action); err != nil {
+					t.Fatal(err)
+				}
+				repo = r.backends()[backend]
+				if got := operation(); got.Kind != operationNone {
+					t.Errorf("operation after %s --%s = %v", kind, action, got.Kind)
+				}
+			}
+
+			if got := operation(); got.Kind != operationNone {
+				t.Errorf("Operation() = %+v; want none", got)
+			}
+
+			// 衝突したcherry-pickを中止する
+			if _, err := execRepo.CherryPick(side); err == nil {
+				t.Fatal("CherryPick() with a conflict succeeded")
+			}
+			if got := operation(); got.Kind != operationCherryPick || !reflect.DeepEqual(got.Conflicts, []string{"a.txt"}) {
+				t.Errorf("Operation() = %+v; want a cherry-pick with a conflict in a.txt", got)
+			}
+			finish(operationCherryPick, operationAbort)
+
+			// editで止まったリベースを続ける
+			head := r.git("rev-parse", "HEAD")
+			if _, err := execRepo.Rebase(base, []RebaseStep{
+				{Action: rebaseEdit, Hash: r.git("rev-parse", "HEAD~1"), Subject: "master"},
+				{Action: rebasePick, Hash: head, Subject: "add b"},
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if got := operation(); got.Kind != operationRebase || got.Step != 1 || got.Total != 2 || len(got.Conflicts) > 0 {
+				t.Errorf("Operation() = %+v; want a rebase at 1/2", got)
+			}
+			finish(operationRebase, operationContinue)
+
+			// 衝突したマージを中止する
+			cmd := exec.Command("git", "merge", "side")
+			cmd.Dir = r.dir
+			if err := cmd.Run(); err == nil {
+				t.Fatal("git merge with a conflict succeeded")
+			}
+			if got := operation(); got.Kind != operationMerge || !reflect.DeepEqual(got.Conflicts, []string{"a.txt"}) {
+				t.Errorf("Operation() = %+v; want a merge with a conflict in a.txt", got)
+			}
+			if _, err := execRepo.FinishOperation(operationMerge, operationSkip); err == nil {
+				t.Error("FinishOperation(merge, skip) succeeded")
+			}
+			finish(operationMerge, operationAbort)
+
+			r.git("bisect", "start")
+			if got := operation(); got.Kind != operationBisect {
+				t.Errorf("Operation() = %+v; want a bisect", got)
+			}
+			finish(operationBisect, operationAbort)
+		})
+	}
+}
+
+func TestBackendsMergeFastForward(t *testing.T) {
+	for _, backend := range []string{"exec", "go"} {
+		t.Run(backend, func(t *testing.T) {
+			r := newTestRepo(t)
+			r.commit("a.txt", "1\n", "first")
+			r.git("switch", "-q", "-c", "feature")
+			r.commit("b.txt", "b\n", "add b")
+			tip := r.commit("a.txt", "2\n", "change a")
+			r.git("switch", "-q", "master")
+			r.write("untracked.txt", "x\n")
+			repo := r.backends()[backend]
+
+			preview, err := repo.MergePreview("feature")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if preview != (MergePreview{Commits: 2, FastForward: true}) {
+				t.Errorf("MergePreview() = %+v; want 2 commits with fast-forward", preview)
+			}
+			// マージで変わるファイルの変更は上書きしない
+			r.write("a.txt", "local\n")
+			if _, err := repo.Merge("feature", mergeFastForward, ""); err == nil {
+				t.Error("Merge() overwriting a local change succeeded")
+			}
+			r.git("checkout", "--", "a.txt")
+			if _, err := repo.Merge("feature", mergeFastForward, ""); err != nil {
+				t.Fatal(err)
+			}
+			if head, _ := repo.HeadCommitHash(); head != tip {
+				t.Errorf("HEAD after merge = %s; want %s", head, tip)
+			}
+			// 作業ツリーも更新し、追跡していないファイルは残す
+			if got := r.git("status", "--porcelain"); got != "?? untracked.txt" {
+				t.Errorf("status after merge = %q", got)
+			}
+			if preview, err := repo.MergePreview("feature"); err != nil || preview.Commits != 0 {
+				t.Errorf("MergePreview() after merge = %+v, %v; want no commits", preview, err)
+			}
+
+			// 分岐していれば早送りできない
+			r.git("switch", "-q", "feature")
+			r.commit("c.txt", "c\n", "add c")
+			r.git("switch", "-q", "master")
+			r.commit("d.txt", "d\n", "add d")
+			preview, err = repo.MergePreview("feature")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if preview != (MergePreview{Commits: 1, FastForward: false}) {
+				t.Errorf("MergePreview() = %+v; want 1 commit without fast-forward", preview)
+			}
+			if _, err := repo.Merge("feature", mergeFastForward, ""); err == nil {
+				t.Error("Merge(ff-only) of a diverged branch succeeded")
+			}
+		})
+	}
+}
+
+func TestExecMerge(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("a.txt", "1\n", "first")
+	r.git("switch", "-q", "-c", "feature")
+	r.commit("b.txt", "b\n", "add b")
+	r.commit("c.txt", "c\n", "add c")
+	r.git("switch", "-q", "master")
+	repo := newExecRepository(r.dir)
+
+	// 早送りできてもマージコミットを作る
+	if _, err := repo.Merge("feature", mergeNoFastForward, "Merge feature\n\n# comment\nbody\n"); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("log", "-1", "--format=%P%n%B"); len(strings.Fields(strings.SplitN(got, "\n", 2)[0])) != 2 ||
+		!strings.HasSuffix(got, "Merge feature\n\nbody") {
+		t.Errorf("merge commit = %q; want two parents and the message without comments", got)
+	}
+
+	// squashは1つの親のコミットにまとめる
+	r.git("reset", "-q", "--hard", "HEAD~1")
+	if _, err := repo.Merge("feature", mergeSquash, "Squash feature"); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("log", "-1", "--format=%P%n%s"); len(strings.Fields(strings.SplitN(got, "\n", 2)[0])) != 1 ||
+		!strings.HasSuffix(got, "\nSquash feature") {
+		t.Errorf("squashed commit = %q; want one parent and the message", got)
+	}
+	if got := r.git("show", "--name-only", "--format=", "HEAD"); got != "b.txt\nc.txt" {
+		t.Errorf("files of the squashed commit = %q", got)
+	}
+
+	// 競合したsquashはMERGE_HEADがなくても中止できる
+	r.git("reset", "-q", "--hard", "HEAD~1")
+	r.git("switch", "-q", "feature")
+	r.commit("a.txt", "2\n", "change a on feature")
+	r.git("switch", "-q", "master")
+	r.commit("a.txt", "3\n", "change a on master")
+	head := r.git("rev-parse", "HEAD")
+	if _, err := repo.Merge("feature", mergeSquash, "Squash feature"); err == nil {
+		t.Fatal("Merge(squash) with a conflict succeeded")
+	}
+	if got := r.git("status", "--porcelain"); !strings.Contains(got, "AA a.txt") && !strings.Contains(got, "UU a.txt") {
+		t.Errorf("status after the conflict = %q", got)
+	}
+	if _, err := repo.FinishOperation(operationMerge, operationAbort); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("status", "--porcelain"); got != "" || r.git("rev-parse", "HEAD") != head {
+		t.Errorf("status after abort = %q", got)
+	}
+
+	goRepo := r.backends()["go"]
+	for _, mode := range []MergeMode{mergeNoFastForward, mergeSquash} {
+		if _, err := goRepo.Merge("feature", mode, "message"); !errors.Is(err, errNotSupported) {
+			t.Errorf("go Merge(%s) error = %v", mode, err)
+		}
+	}
+}
+
+func TestBackendsStashes(t *testing.T) {
+	for _, backend := range []string{"exec", "go"} {
+		t.Run(backend, func(t *testing.T) {
+			r := newTestRepo(t)
+			r.commit("a.txt", "1\n", "first")
+			base := r.commit("a.txt", "2\n", "second")
+			repo := r.backends()[backend]
+
+			if stashes, err := repo.Stashes(); err != nil || len(stashes) != 0 {
+				t.Fatalf("Stashes() without stashes = %+v, %v", stashes, err)
+			}
+
+			r.write("a.txt", "changed\n")
+			r.write("untracked.txt", "x\n")
+			r.git("stash", "push", "--include-untracked", "-m", "with untracked")
+			r.write("a.txt", "changed again\n")
+			r.git("stash")
+			stashes, err := repo.Stashes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(stashes) != 2 {
+				t.Fatalf("Stashes() = %+v; want 2 stashes", stashes)
+			}
+			if got := stashes[0]; got.Name != "stash@{0}" || got.Hash != r.git("rev-parse", "stash@{0}") || got.Base != base ||
+				got.Branch != "master" || got.Message != base[:7]+" second" || got.Untracked {
+				t.Errorf("stash@{0} = %+v", got)
+			}
+			if got := stashes[1]; got.Name != "stash@{1}" || got.Message != "with untracked" || !got.Untracked || got.Date.IsZero() {
+				t.Errorf("stash@{1} = %+v", got)
+			}
+
+			// スタッシュのコミットはコミットログに表示しない
+			for _, hash := range logHashes(t, repo, LogFilter{}) {
+				if hash == stashes[0].Hash || hash == stashes[1].Hash {
+					t.Errorf("Log() includes the stash commit %s", hash)
+				}
+			}
+		})
+	}
+}
+
+func TestExecStashActions(t *testing.T) {
+	r := newTestRepo(t)
+	r.commit("a.txt", "1\n", "first")
+	repo := newExecRepository(r.dir)
+	stashCount := func() int {
+		t.Helper()
+		stashes, err := repo.Stashes()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(stashes)
+	}
+
+	// 追跡していないファイルは指定したときだけスタッシュする
+	r.write("a.txt", "2\n")
+	r.write("untracked.txt", "x\n")
+	if _, err := repo.StashChanges("tracked only", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("status", "--porcelain"); got != "?? untracked.txt" {
+		t.Errorf("status after stashing tracked files = %q", got)
+	}
+	if _, err := repo.StashChanges("", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("status", "--porcelain"); got != "" || stashCount() != 2 {
+		t.Errorf("status after stashing untracked files = %q with %d stashes", got, stashCount())
+	}
+
+	// applyはスタッシュを残し、popは削除する
+	if _, err := repo.ApplyStash("stash@{0}", false); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("status", "--porcelain"); got != "?? untracked.txt" || stashCount() != 2 {
+		t.Errorf("status after apply = %q with %d stashes", got, stashCount())
+	}
+	if _, err := repo.DropStash("stash@{0}"); err != nil {
+		t.Fatal(err)
+	}
+	if stashCount() != 1 {
+		t.Errorf("%d stashes after drop; want 1", stashCount())
+	}
+
+	// スタッシュしたときのコミットからブランチを作成し、スタッシュを適用して削除する
+	r.commit("b.txt", "b\n", "second")
+	if _, err := repo.StashBranch("stash@{0}", "from-stash"); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.git("branch", "--show-current"); got != "from-stash" {
+		t.Errorf("branch after StashBranch() = %q", got)
+	}
+	if got := r.git("status", "--porcelain"); got != "M a.txt\n?? untracked.txt" || stashCount() != 0 {
+		t.Errorf("status after StashBranch() = %q with %d stashes", got, stashCount())
+	}
+
+	goRepo := r.backends()["go"]
+	if _, err := goRepo.StashChanges("", false); !errors.Is(err, errNotSupported) {
+		t.Errorf("go StashChanges() error = %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/rivo/tview"
+)
+
+// コミットが検索文字列に一致するか確認する（大文字小文字は区別しない）
+// 件名、作者、ハッシュの先頭部分、コミットを指しているブランチ名（リモートブランチを含む）とタグを対象にする
+func commitMatches(commit Commit, refs *RefIndex, query string) bool {
+	if query == "" || commit.IsUncommitted {
+		return false
+	}
+	query = strings.ToLower(query)
+
+	if strings.HasPrefix(commit.Hash, query) ||
+		strings.Contains(strings.ToLower(commit.Message), query) ||
+		strings.Contains(strings.ToLower(commit.Author), query) {
+		return true
+	}
+	for _, ref := range refs.RefsAt(commit.Hash) {
+		if strings.Contains(strings.ToLower(ref.Name), query) {
+			return true
+		}
+	}
+	return false
+}
+
+// startの次（または前）から検索して一致するコミットのインデックスを返す
+// 末尾（または先頭）まで行ったら反対側から続けて検索する。見つからなければ-1
+func findCommit(commits []Commit, refs *RefIndex, query string, start int, forward bool) int {
+	n := len(commits)
+	step := 1
+	if !forward {
+		step = -1
+	}
+	for i := 1; i <= n; i++ {
+		index := ((start+step*i)%n + n) % n
+		if commitMatches(commits[index], refs, query) {
+			return index
+		}
+	}
+	return -1
+}
+
+// 検索の条件（続きのページを読み込みながら検索するときに覚えておく）
+type searchRequest struct {
+	query   string
+	start   int  // 検索を始める位置（この次から探す）
+	forward bool // 下方向に検索するかどうか
+}
+
+// findCommitの結果が、読み込んだ範囲の端で折り返して見つけたもの（または見つからない）かどうか
+// 下方向なら折り返す前にまだ読み込んでいないコミットを、上方向なら折り返した先の
+// 最後のコミットまでを探す必要がある
+func (r searchRequest) wrapped(index int) bool {
+	if index < 0 {
+		return true
+	}
+	if r.forward {
+		return index <= r.start
+	}
+	return index >= r.start
+}
+
+// 一致するコミットの数を数える
+func countMatches(commits []Commit, refs *RefIndex, query string) int {
+	count := 0
+	for _, commit := range commits {
+		if commitMatches(commit, refs, query) {
+			count++
+		}
+	}
+	return count
+}
+
+// 文字列中の検索文字列に一致する部分を反転表示にする（タグ文字はエスケープする）
+func highlightMatches(text, query string) string {
+	if query == "" {
+		return tview.Escape(text)
+	}
+
+	var b strings.Builder
+	lower := strings.ToLower(text)
+	query = strings.ToLower(query)
+	for {
+		i := strings.Index(lower, query)
+		if i < 0 || len(lower) != len(text) {
+			// 小文字にすると長さが変わる文字を含む場合は強調しない
+			b.WriteString(tview.Escape(text))
+			break
+		}
+		b.WriteString(tview.Escape(text[:i]))
+		b.WriteString("[::r]" + tview.Escape(text[i:i+len(query)]) + "[::-]")
+		text, lower = text[i+len(query):], lower[i+len(query):]
+	}
+	return b.String()
+}
+
+// 短縮したハッシュの先頭が検索文字列に一致する場合、その部分を反転表示にする
+// commitMatchesと同じく、ハッシュは先頭部分だけを検索の対象にする
+func highlightHashPrefix(hash, query string) string {
+	query = strings.ToLower(query)
+	if query == "" || !strings.HasPrefix(hash, query[:min(len(query), len(hash))]) {
+		return tview.Escape(hash)
+	}
+	n := min(len(query), len(hash))
+	return "[::r]" + tview.Escape(hash[:n]) + "[::-]" + tview.Escape(hash[n:])
+}
@@ -0,0 +1,107 @@
+package main
+
+import "testing"
+
+func TestFindCommit(t *testing.T) {
+	commits := []Commit{
+		{Hash: "--------", Message: "Uncommitted Changes: fix", IsUncommitted: true},
+		{Hash: "aaa1111", Author: "Alice", Message: "Add parser"},
+		{Hash: "bbb2222", Author: "Bob", Message: "Fix parser bug"},
+		{Hash: "ccc3333", Author: "Alice", Message: "Update README"},
+	}
+	refs := &RefIndex{byCommit: map[string][]Ref{
+		"ccc3333": {{Name: "release", Kind: RefLocalBranch}},
+		"bbb2222": {{Name: "v1.2.0", Kind: RefTag}, {Name: "origin/hotfix", Kind: RefRemoteBranch}},
+	}}
+
+	tests := []struct {
+		query   string
+		start   int
+		forward bool
+		want    int
+	}{
+		{"parser", 0, true, 1},
+		{"parser", 1, true, 2},
+		{"parser", 2, true, 1},  // 末尾まで行ったら先頭から続ける
+		{"parser", 1, false, 2}, // 上方向でも反対側から続ける
+		{"PARSER", 0, true, 1},  // 大文字小文字を区別しない
+		{"alice", 1, true, 3},
+		{"bbb", 0, true, 2},     // ハッシュは先頭部分に一致
+		{"222", 0, true, -1},    // ハッシュの途中には一致しない
+		{"release", 0, true, 3}, // ブランチ名
+		{"v1.2", 0, true, 2},    // タグ
+		{"hotfix", 0, true, 2},  // リモートブランチ
+		{"fix", 2, true, 2},     // 未コミットの変更の行は対象外
+		{"missing", 0, true, -1},
+	}
+
+	for _, test := range tests {
+		if got := findCommit(commits, refs, test.query, test.start, test.forward); got != test.want {
+			t.Errorf("findCommit(%q, %d, %v) = %d; want %d", test.query, test.start, test.forward, got, test.want)
+		}
+	}
+}
+
+func TestHighlightMatches(t *testing.T) {
+	tests := []struct {
+		text  string
+		query string
+		want  string
+	}{
+		{"Fix parser bug", "", "Fix parser bug"},
+		{"Fix parser bug", "PARSER", "Fix [::r]parser[::-] bug"},
+		{"aa-aa", "aa", "[::r]aa[::-]-[::r]aa[::-]"},
+		{"no match", "xyz", "no match"},
+		{"[red] tag", "tag", "[red[] [::r]tag[::-]"}, // タグ文字はエスケープする
+	}
+
+	for _, test := range tests {
+		if got := highlightMatches(test.text, test.query); got != test.want {
+			t.Errorf("highlightMatches(%q, %q) = %q; want %q", test.text, test.query, got, test.want)
+		}
+	}
+}
+
+func TestHighlightHashPrefix(t *testing.T) {
+	tests := []struct {
+		hash  string
+		query string
+		want  string
+	}{
+		{"abc1234", "", "abc1234"},
+		{"abc1234", "ABC", "[::r]abc[::-]1234"},
+		{"abc1234", "abc1234def", "[::r]abc1234[::-]"}, // 完全なハッシュで検索した場合
+		{"abc1234", "123", "abc1234"},                  // 途中の一致は強調しない
+		{"abc1234", "2024", "abc1234"},
+	}
+
+	for _, test := range tests {
+		if got := highlightHashPrefix(test.hash, test.query); got != test.want {
+			t.Errorf("highlightHashPrefix(%q, %q) = %q; want %q", test.hash, test.query, got, test.want)
+		}
+	}
+}
+
+func TestSearchRequestWrapped(t *testing.T) {
+	tests := []struct {
+		forward bool
+		start   int
+		index   int
+		want    bool
+	}{
+		{true, 3, 5, false},
+		{true, 3, 1, true}, // 先頭に戻って見つけた
+		{true, 3, 3, true}, // 一周して自分自身
+		{true, 3, -1, true},
+		{false, 3, 1, false},
+		{false, 3, 5, true}, // 末尾に戻って見つけた
+		{false, 3, -1, true},
+	}
+
+	for _, test := range tests {
+		request := searchRequest{query: "x", start: test.start, forward: test.forward}
+		if got := request.wrapped(test.index); got != test.want {
+			t.Errorf("%+v.wrapped(%d) = %v; want %v", request, test.index, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// スタッシュを指すref（コミットログには表示しない）
+const stashRef = "refs/stash"
+
+// スタッシュした変更
+type Stash struct {
+	Name      string    // stash@{0}など
+	Hash      string    // スタッシュのコミット
+	Base      string    // スタッシュしたときのHEAD（スタッシュのコミットの最初の親）
+	Branch    string    // スタッシュしたときのブランチ（detached HEADなら"(no branch)"）
+	Message   string    // メッセージ（指定しなければHEADのコミットのハッシュと件名）
+	Date      time.Time // スタッシュした日時
+	Untracked bool      // 追跡していないファイルも含むかどうか（3番目の親がある）
+}
+
+// スタッシュのreflogのメッセージ（"WIP on main: abc1234 subject"や"On main: message"）から
+// スタッシュしたときのブランチとメッセージを取り出す
+func parseStashSubject(subject string) (branch, message string) {
+	for _, prefix := range []string{"WIP on ", "On "} {
+		if rest, ok := strings.CutPrefix(subject, prefix); ok {
+			if branch, message, ok := strings.Cut(rest, ": "); ok {
+				return branch, message
+			}
+		}
+	}
+	return "", subject
+}
+
+// スタッシュのreflog（.git/logs/refs/stash）の内容からスタッシュを新しい順に取り出す
+// 各行は"<前のハッシュ> <ハッシュ> <名前> <<メール>> <時刻> <タイムゾーン>\t<メッセージ>"の形式で、古い順に並んでいる
+// 親はreflogにないのでBaseとUntrackedは設定しない
+func parseStashLog(data string) []Stash {
+	var stashes []Stash
+	for _, line := range strings.Split(data, "\n") {
+		header, subject, ok := strings.Cut(line, "\t")
+		fields := strings.Fields(header)
+		if !ok || len(fields) < 4 {
+			continue
+		}
+		stash := Stash{Hash: fields[1]}
+		if seconds, err := strconv.ParseInt(fields[len(fields)-2], 10, 64); err == nil {
+			stash.Date = time.Unix(seconds, 0)
+		}
+		stash.Branch, stash.Message = parseStashSubject(subject)
+		stashes = append(stashes, stash)
+	}
+
+	for i, j := 0, len(stashes)-1; i < j; i, j = i+1, j-1 {
+		stashes[i], stashes[j] = stashes[j], stashes[i]
+	}
+	for i := range stashes {
+		stashes[i].Name = fmt.Sprintf("stash@{%d}", i)
+	}
+	return stashes
+}
+
+// チェックアウトなどがローカルの変更を上書きするために失敗したかどうか
+// gitコマンドのエラーは"Your local changes to the following files would be overwritten"、
+// 追跡していないファイルでは"untracked working tree files would be overwritten"になる
+func isOverwriteError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "would be overwritten")
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseStashSubject(t *testing.T) {
+	tests := []struct {
+		subject, branch, message string
+	}{
+		{"WIP on main: 0123456 add feature", "main", "0123456 add feature"},
+		{"On feature/x: half done", "feature/x", "half done"},
+		{"On (no branch): detached", "(no branch)", "detached"},
+		{"custom reflog message", "", "custom reflog message"},
+	}
+	for _, tt := range tests {
+		branch, message := parseStashSubject(tt.subject)
+		if branch != tt.branch || message != tt.message {
+			t.Errorf("parseStashSubject(%q) = %q, %q; want %q, %q", tt.subject, branch, message, tt.branch, tt.message)
+		}
+	}
+}
+
+func TestParseStashLog(t *testing.T) {
+	zero := "0000000000000000000000000000000000000000"
+	first := "1111111111111111111111111111111111111111"
+	second := "2222222222222222222222222222222222222222"
+	data := zero + " " + first + " Alice Smith <alice@example.com> 1700000000 +0900\tOn main: first\n" +
+		first + " " + second + " Alice Smith <alice@example.com> 1700000100 +0900\tWIP on main: 0123456 subject\n"
+
+	stashes := parseStashLog(data)
+	if len(stashes) != 2 {
+		t.Fatalf("parseStashLog() returned %d stashes", len(stashes))
+	}
+	want := []Stash{
+		{Name: "stash@{0}", Hash: second, Branch: "main", Message: "0123456 subject", Date: time.Unix(1700000100, 0)},
+		{Name: "stash@{1}", Hash: first, Branch: "main", Message: "first", Date: time.Unix(1700000000, 0)},
+	}
+	for i := range want {
+		if got := stashes[i]; got.Name != want[i].Name || got.Hash != want[i].Hash || got.Branch != want[i].Branch ||
+			got.Message != want[i].Message || !got.Date.Equal(want[i].Date) {
+			t.Errorf("stash %d = %+v; want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestIsOverwriteError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{errors.New("error: Your local changes to the following files would be overwritten by checkout:\n\ta.txt"), true},
+		{errors.New("error: The following untracked working tree files would be overwritten by checkout:\n\tb.txt"), true},
+		{errors.New("fatal: invalid reference: foo"), false},
+	}
+	for _, tt := range tests {
+		if got := isOverwriteError(tt.err); got != tt.want {
+			t.Errorf("isOverwriteError(%v) = %v; want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+)
+
+// スタッシュを一覧表示し、選択中のスタッシュの差分を下に表示するビュー
+type stashView struct {
+	*tview.Flex
+	list    *tview.TextView
+	preview *tview.TextView // 選択中のスタッシュの差分
+	footer  *tview.TextView // 操作の結果や問い合わせを表示する2行の領域
+	repo    Repository
+	queue   func(func()) // 別のゴルーチンの結果をUIのゴルーチンで処理する（app.QueueUpdateDraw）
+
+	stashes           []Stash
+	current           int           // 選択中の行
+	scrollOffset      int           // 先頭に表示している行
+	loaded            bool          // 一度でも読み込んだかどうか
+	generation        int           // 読み込み直すたびに増やし、古い読み込み結果を捨てる
+	previewHash       string        // 差分を表示しているスタッシュ
+	previewGeneration int           // 選択を変えるたびに増やし、古い差分の読み込み結果を捨てる
+	running           bool          // 操作を実行中かどうか
+	prompt            *statusPrompt // 確認中の問い合わせ
+	message           string        // 操作の結果
+
+	closeFunc     func()            // ビューを閉じるときに呼ぶ関数
+	diffFunc      func(stash Stash) // スタッシュの差分を全画面で表示する関数
+	operationFunc func() Operation  // 途中で止まっているマージやリベースなどを返す関数
+}
+
+// スタッシュの一覧を作成
+func newStashView(repo Repository, queue func(func())) *stashView {
+	v := &stashView{
+		list: tview.NewTextView().
+			SetDynamicColors(true).
+			SetScrollable(true).
+			SetWrap(false),
+		preview: tview.NewTextView().
+			SetDynamicColors(true).
+			SetScrollable(true).
+			SetWrap(false),
+		footer: tview.NewTextView().
+			SetDynamicColors(true),
+		repo:  repo,
+		queue: queue,
+	}
+	v.list.SetBorder(true).SetTitle(" Stashes ")
+	v.preview.SetBorder(true)
+	v.Flex = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(v.list, 0, 1, true).
+		AddItem(v.preview, 0, 2, false).
+		AddItem(v.footer, 2, 0, false)
+	v.list.SetInputCapture(v.handleKey)
+	return v
+}
+
+// ビューを閉じるときに呼ぶ関数を設定
+func (v *stashView) SetCloseFunc(handler func()) *stashView {
+	v.closeFunc = handler
+	return v
+}
+
+// スタッシュの差分を全画面で表示する関数を設定
+func (v *stashView) SetDiffFunc(handler func(stash Stash)) *stashView {
+	v.diffFunc = handler
+	return v
+}
+
+// 途中で止まっているマージやリベースなどを返す関数を設定
+func (v *stashView) SetOperationFunc(handler func() Operation) *stashView {
+	v.operationFunc = handler
+	return v
+}
+
+// 途中で止まっている操作（関数が設定されていなければ操作の途中ではないとみなす）
+func (v *stashView) operation() Operation {
+	if v.operationFunc == nil {
+		return Operation{}
+	}
+	return v.operationFunc()
+}
+
+// スタッシュを適用する（popなら適用したスタッシュを削除する）
+// 操作の途中では衝突を解決している作業ツリーに変更が混ざるので、確認してから適用する
+func (v *stashView) apply(stash Stash, pop bool) {
+	failure, success := "Apply failed", fmt.Sprintf("Applied %s", stash.Name)
+	if pop {
+		failure, success = "Pop failed", fmt.Sprintf("Applied and dropped %s", stash.Name)
+	}
+	run := func() {
+		v.run(failure, success, func() (string, error) {
+			return v.repo.ApplyStash(stash.Name, pop)
+		})
+	}
+	operation := v.operation()
+	if operation.Kind == operationNone {
+		run()
+		return
+	}
+	v.prompt = newConfirmPrompt(fmt.Sprintf("A %s is in progress. Apply %s anyway?", operation.Kind, stash.Name), run).
+		SetWarning(fmt.Sprintf("The stashed changes will be mixed into the %s", operation.Kind))
+}
+
+// 選択中のスタッシュを取得（一覧が空ならfalse）
+func (v *stashView) selected() (Stash, bool) {
+	if v.current < 0 || v.current >= len(v.stashes) {
+		return Stash{}, false
+	}
+	return v.stashes[v.current], true
+}
+
+// スタッシュの一覧を別のゴルーチンで読み込み直す
+// 選択していたスタッシュが残っていれば選択したままにする（削除すると名前の番号がずれるのでハッシュで探す）
+func (v *stashView) Refresh() {
+	v.generation++
+	generation := v.generation
+	go func() {
+		stashes, err := v.repo.Stashes()
+		v.queue(func() {
+			if generation != v.generation {
+				return
+			}
+			if err != nil {
+				v.message = fmt.Sprintf("[red]Failed to read stashes: %s[-]", tview.Escape(formatMessage(err.Error())))
+				v.render()
+				return
+			}
+			old, ok := v.selected()
+			v.stashes = stashes
+			v.loaded = true
+			if ok {
+				for i, stash := range v.stashes {
+					if stash.Hash == old.Hash {
+						v.current = i
+						break
+					}
+				}
+			}
+			v.current = max(min(v.current, len(v.stashes)-1), 0)
+			v.render()
+		})
+	}()
+}
+
+// 操作を別のゴルーチンで行い、終わったら結果を表示して一覧を読み込み直す
+func (v *stashView) run(failure, success string, action func() (string, error)) {
+	v.running = true
+	v.message = "Running…"
+	go func() {
+		_, err := action()
+		v.queue(func() {
+			v.running = false
+			if err != nil {
+				v.message = fmt.Sprintf("[red]%s: %s[-]", failure, tview.Escape(formatMessage(err.Error())))
+			} else {
+				v.message = tview.Escape(success)
+			}
+			v.Refresh()
+			v.render()
+		})
+	}()
+}
+
+// 選択中のスタッシュの差分を別のゴルーチンで読み込んで表示する（選択が変わったときだけ読み込む）
+// スタッシュのコミットは最初の親（スタッシュしたときのHEAD）との差分が作業ツリーの変更になる
+func (v *stashView) loadPreview() {
+	stash, ok := v.selected()
+	if !ok {
+		v.previewHash = ""
+		v.preview.SetTitle("")
+		v.preview.SetText("")
+		return
+	}
+	if stash.Hash == v.previewHash {
+		return
+	}
+	v.previewHash = stash.Hash
+	v.previewGeneration++
+	generation := v.previewGeneration
+	v.preview.SetTitle(fmt.Sprintf(" %s on %s at %s ", stash.Name, stash.Branch, shortHash(stash.Base)))
+	v.preview.SetText("Loading...")
+	v.preview.ScrollToBeginning()
+	go func() {
+		text, err := v.repo.CommitDiff(stash.Hash)
+		v.queue(func() {
+			if generation != v.previewGeneration {
+				return
+			}
+			if err != nil {
+				v.preview.SetText(fmt.Sprintf("[red]Failed to load the diff: %s[-]", tview.Escape(formatMessage(err.Error()))))
+				return
+			}
+			v.preview.SetText(renderStashPreview(stash, parseDiff(text)))
+		})
+	}()
+}
+
+// スタッシュの差分を色付きの1列で描画する
+func renderStashPreview(stash Stash, files []diffFile) string {
+	var b strings.Builder
+	if stash.Untracked {
+		b.WriteString("[gray]Untracked files are also stashed (not shown below)[-]\n")
+	}
+	if len(files) == 0 {
+		b.WriteString("No changes to tracked files\n")
+	}
+	for _, file := range files {
+		for _, line := range file.Header {
+			fmt.Fprintf(&b, "[yellow::b]%s[-::-]\n", tview.Escape(line))
+		}
+		for _, hunk := range file.Hunks {
+			fmt.Fprintf(&b, "[aqua]%s[-]\n", tview.Escape(hunk.Header))
+			for _, line := range hunk.Lines {
+				b.WriteString(formatDiffLine(line) + "\n")
+			}
+		}
+	}
+	return b.String()
+}
+
+// ハッシュの先頭7文字（短ければそのまま）
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// 一覧を描画する
+func (v *stashView) render() {
+	_, _, _, height := v.list.GetInnerRect()
+
+	// 名前とブランチの列の幅をそろえる
+	nameWidth, branchWidth := 0, 0
+	for _, stash := range v.stashes {
+		nameWidth = max(nameWidth, runewidth.StringWidth(stash.Name))
+		branchWidth = max(branchWidth, runewidth.StringWidth(stash.Branch))
+	}
+
+	var b strings.Builder
+	if len(v.stashes) == 0 {
+		if v.loaded {
+			b.WriteString("No stashes\n")
+		} else {
+			b.WriteString("Loading...\n")
+		}
+	}
+	for i, stash := range v.stashes {
+		line := fmt.Sprintf("%s  %s  %s  %s  %s", runewidth.FillRight(stash.Name, nameWidth), stash.Date.Format("2006-01-02 15:04"),
+			runewidth.FillRight(stash.Branch, branchWidth), shortHash(stash.Base), stash.Message)
+		if stash.Untracked {
+			line += "  (+untracked)"
+		}
+		if i == v.current {
+			fmt.Fprintf(&b, "[black:white]%s[-:-]\n", tview.Escape(line))
+		} else {
+			fmt.Fprintf(&b, "%s\n", tview.Escape(line))
+		}
+	}
+	v.list.SetText(b.String())
+
+	// 選択中の行が画面に表示されるようにスクロールする
+	if v.current < v.scrollOffset {
+		v.scrollOffset = v.current
+	} else if height > 0 && v.current >= v.scrollOffset+height {
+		v.scrollOffset = v.current - height + 1
+	}
+	v.list.ScrollTo(v.scrollOffset, 0)
+
+	v.loadPreview()
+
+	// 問い合わせ中はその内容、そうでなければ操作の結果と使えるキーを表示する
+	v.footer.Clear()
+	if v.prompt != nil {
+		v.footer.Write([]byte(v.prompt.Render()))
+		return
+	}
+	v.footer.Write([]byte(fmt.Sprintf("Total stashes: %d", len(v.stashes))))
+	if v.message != "" {
+		v.footer.Write([]byte("  " + v.message))
+	}
+	v.footer.Write([]byte("\n[gray]a: apply  p: pop  d: drop  b: branch  Enter/v: diff  J/K: scroll diff  R: reload  q: close[-]"))
+}
+
+// キー入力のハンドリング
+func (v *stashView) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	// 問い合わせ中はその入力として扱う
+	if v.prompt != nil {
+		switch v.prompt.HandleKey(event) {
+		case inputAccepted:
+			prompt := v.prompt
+			v.prompt = nil
+			prompt.Accept()
+		case inputCanceled:
+			v.prompt = nil
+		}
+		v.render()
+		return nil
+	}
+
+	_, _, _, height := v.list.GetInnerRect()
+	stash, ok := v.selected()
+	// 実行中は結果が出るまで次の操作を受け付けない
+	action := ok && !v.running
+	if !v.running {
+		v.message = ""
+	}
+
+	switch event.Key() {
+	case tcell.KeyUp:
+		if v.current > 0 {
+			v.current--
+		}
+	case tcell.KeyDown:
+		if v.current < len(v.stashes)-1 {
+			v.current++
+		}
+	case tcell.KeyPgUp:
+		v.current = max(v.current-max(height-1, 1), 0)
+	case tcell.KeyPgDn:
+		v.current = max(min(v.current+max(height-1, 1), len(v.stashes)-1), 0)
+	case tcell.KeyEnter:
+		if ok && v.diffFunc != nil {
+			v.diffFunc(stash)
+		}
+		return nil
+	case tcell.KeyEscape:
+		if v.closeFunc != nil {
+			v.closeFunc()
+		}
+		return nil
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case 'v':
+			if ok && v.diffFunc != nil {
+				v.diffFunc(stash)
+			}
+			return nil
+		case 'J', 'K':
+			// 差分の表示をスクロールする
+			row, _ := v.preview.GetScrollOffset()
+			if event.Rune() == 'J' {
+				row++
+			} else {
+				row = max(row-1, 0)
+			}
+			v.preview.ScrollTo(row, 0)
+		case 'a', 'p':
+			if action {
+				v.apply(stash, event.Rune() == 'p')
+			}
+		case 'd':
+			// 削除したスタッシュは一覧から取り戻せないので確認する
+			if action {
+				v.prompt = newConfirmPrompt(fmt.Sprintf("Drop %s (%s)?", stash.Name, stash.Message), func() {
+					v.run("Drop failed", fmt.Sprintf("Dropped %s", stash.Name), func() (string, error) {
+						return v.repo.DropStash(stash.Name)
+					})
+				}).SetHint("The stashed changes will be lost")
+			}
+		case 'b':
+			// git stash branchはブランチを切り替えるので、操作の途中ではチェックアウトと同じく行わない
+			if operation := v.operation(); action && operation.Kind != operationNone {
+				v.message = fmt.Sprintf("[yellow]Cannot create a branch from a stash while a %s is in progress; press o in the commit list to continue or abort it[-]", operation.Kind)
+			} else if action {
+				v.prompt = newInputPrompt(fmt.Sprintf("New branch from %s at %s: ", stash.Name, shortHash(stash.Base)), "", func(branch string) {
+					branch = strings.TrimSpace(branch)
+					if branch == "" {
+						return
+					}
+					v.run("Branch failed", fmt.Sprintf("Switched to a new branch '%s' with %s applied", branch, stash.Name), func() (string, error) {
+						return v.repo.StashBranch(stash.Name, branch)
+					})
+				}).SetHint("Creates the branch at the stash's base commit, switches to it and pops the stash")
+			}
+		case 'R':
+			v.Refresh()
+		case 'q':
+			if v.closeFunc != nil {
+				v.closeFunc()
+			}
+			return nil
+		default:
+			return event
+		}
+	default:
+		return event
+	}
+	v.render()
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// 未コミットのファイルの状態
+type FileStatus struct {
+	Path       string
+	OrigPath   string // 名前を変更した場合の元の名前
+	Index      byte   // インデックスの状態（'.'は変更なし、'M'、'A'、'D'、'R'など）
+	Worktree   byte   // 作業ツリーの状態（'.'は変更なし）
+	Untracked  bool   // 追跡していないファイル
+	Conflicted bool   // マージの衝突が解決されていないファイル
+}
+
+// インデックスに変更があるかどうか
+func (f FileStatus) IsStaged() bool {
+	return !f.Untracked && !f.Conflicted && f.Index != '.'
+}
+
+// 作業ツリーにインデックスへ追加していない変更があるかどうか
+func (f FileStatus) IsUnstaged() bool {
+	return !f.Untracked && (f.Conflicted || f.Worktree != '.')
+}
+
+// 表示用のパス（名前を変更した場合は元の名前も含める）
+func (f FileStatus) DisplayPath() string {
+	if f.OrigPath != "" {
+		return f.OrigPath + " -> " + f.Path
+	}
+	return f.Path
+}
+
+// git status --porcelain=v2 -zの出力を解析する
+func parseStatusV2(output string) []FileStatus {
+	var files []FileStatus
+	fields := strings.Split(output, "\x00")
+	for i := 0; i < len(fields); i++ {
+		entry := fields[i]
+		if len(entry) < 2 {
+			continue
+		}
+		switch entry[0] {
+		case '1':
+			// 1 XY sub mH mI mW hH hI path
+			parts := strings.SplitN(entry, " ", 9)
+			if len(parts) == 9 && len(parts[1]) == 2 {
+				files = append(files, FileStatus{Path: parts[8], Index: parts[1][0], Worktree: parts[1][1]})
+			}
+		case '2':
+			// 2 XY sub mH mI mW hH hI Xscore path（続くフィールドが元の名前）
+			parts := strings.SplitN(entry, " ", 10)
+			if len(parts) == 10 && len(parts[1]) == 2 && i+1 < len(fields) {
+				i++
+				files = append(files, FileStatus{Path: parts[9], OrigPath: fields[i], Index: parts[1][0], Worktree: parts[1][1]})
+			}
+		case 'u':
+			// u XY sub m1 m2 m3 mW h1 h2 h3 path
+			parts := strings.SplitN(entry, " ", 11)
+			if len(parts) == 11 && len(parts[1]) == 2 {
+				files = append(files, FileStatus{Path: parts[10], Index: parts[1][0], Worktree: parts[1][1], Conflicted: true})
+			}
+		case '?':
+			files = append(files, FileStatus{Path: entry[2:], Index: '?', Worktree: '?', Untracked: true})
+		}
+	}
+	return files
+}
+
+// 未コミットの変更の区分
+type statusSection int
+
+const (
+	sectionStaged    statusSection = iota // インデックスに追加した変更
+	sectionUnstaged                       // インデックスに追加していない変更
+	sectionUntracked                      // 追跡していないファイル
+)
+
+// 区分の見出し
+func (s statusSection) String() string {
+	switch s {
+	case sectionStaged:
+		return "Staged changes"
+	case sectionUnstaged:
+		return "Unstaged changes"
+	}
+	return "Untracked files"
+}
+
+// 一覧に表示する1行（インデックスと作業ツリーの両方に変更があるファイルは2回現れる）
+type statusEntry struct {
+	Section statusSection
+	File    FileStatus
+}
+
+// 区分での状態を表す文字
+func (e statusEntry) Code() byte {
+	switch {
+	case e.Section == sectionStaged:
+		return e.File.Index
+	case e.File.Conflicted:
+		return 'U'
+	case e.Section == sectionUnstaged:
+		return e.File.Worktree
+	}
+	return '?'
+}
+
+// ファイルの状態を区分ごとにパスの順に並べる
+func groupStatus(files []FileStatus) []statusEntry {
+	sorted := append([]FileStatus(nil), files...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Path < sorted[j].Path })
+
+	var entries []statusEntry
+	for _, section := range []statusSection{sectionStaged, sectionUnstaged, sectionUntracked} {
+		for _, file := range sorted {
+			if (section == sectionStaged && file.IsStaged()) ||
+				(section == sectionUnstaged && file.IsUnstaged()) ||
+				(section == sectionUntracked && file.Untracked) {
+				entries = append(entries, statusEntry{Section: section, File: file})
+			}
+		}
+	}
+	return entries
+}
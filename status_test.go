@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStatusV2(t *testing.T) {
+	output := strings.Join([]string{
+		"1 .M N... 100644 100644 100644 abc abc a.txt",
+		"1 A. N... 000000 100644 100644 000 def dir/with space.txt",
+		"2 R. N... 100644 100644 100644 abc abc R100 new.txt", "old.txt",
+		"u UU N... 100644 100644 100644 100644 a b c conflict.txt",
+		"? untracked.txt",
+		"! ignored.txt",
+		"",
+	}, "\x00")
+
+	want := []FileStatus{
+		{Path: "a.txt", Index: '.', Worktree: 'M'},
+		{Path: "dir/with space.txt", Index: 'A', Worktree: '.'},
+		{Path: "new.txt", OrigPath: "old.txt", Index: 'R', Worktree: '.'},
+		{Path: "conflict.txt", Index: 'U', Worktree: 'U', Conflicted: true},
+		{Path: "untracked.txt", Index: '?', Worktree: '?', Untracked: true},
+	}
+	if got := parseStatusV2(output); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseStatusV2() =\n  %+v\nwant\n  %+v", got, want)
+	}
+}
+
+func TestGroupStatus(t *testing.T) {
+	files := []FileStatus{
+		{Path: "z.txt", Index: '?', Worktree: '?', Untracked: true},
+		{Path: "b.txt", Index: 'M', Worktree: 'M'},
+		{Path: "a.txt", Index: '.', Worktree: 'D'},
+		{Path: "c.txt", Index: 'U', Worktree: 'U', Conflicted: true},
+	}
+
+	var got []string
+	for _, entry := range groupStatus(files) {
+		got = append(got, fmt.Sprintf("%d %c %s", entry.Section, entry.Code(), entry.File.Path))
+	}
+	want := []string{
+		"0 M b.txt",
+		"1 D a.txt",
+		"1 M b.txt",
+		"1 U c.txt",
+		"2 ? z.txt",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("groupStatus() = %q; want %q", got, want)
+	}
+}
+
+func TestFindStatusEntry(t *testing.T) {
+	entries := groupStatus([]FileStatus{
+		{Path: "a.txt", Index: 'M', Worktree: '.'},
+		{Path: "b.txt", Index: '.', Worktree: 'M'},
+	})
+	staged := statusEntry{Section: sectionStaged, File: FileStatus{Path: "a.txt"}}
+	unstaged := statusEntry{Section: sectionUnstaged, File: FileStatus{Path: "a.txt"}}
+	gone := statusEntry{Section: sectionUnstaged, File: FileStatus{Path: "gone.txt"}}
+
+	if got := findStatusEntry(entries, staged, 1); got != 0 {
+		t.Errorf("same section: got %d; want 0", got)
+	}
+	// ステージングした後も同じファイルを選択したままにする
+	if got := findStatusEntry(entries, unstaged, 1); got != 0 {
+		t.Errorf("moved section: got %d; want 0", got)
+	}
+	if got := findStatusEntry(entries, gone, 1); got != 1 {
+		t.Errorf("removed file: got %d; want 1", got)
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// 未コミットの変更をファイルごとに一覧表示し、ステージングなどを行うビュー
+type statusView struct {
+	*tview.Flex
+	list   *tview.TextView
+	footer *tview.TextView // 操作の結果や問い合わせを表示する2行の領域
+	repo   Repository
+	queue  func(func()) // 別のゴルーチンの結果をUIのゴルーチンで処理する（app.QueueUpdateDraw）
+
+	entries      []statusEntry
+	current      int           // 選択中の行
+	scrollOffset int           // 先頭に表示している行
+	loaded       bool          // 一度でも読み込んだかどうか
+	generation   int           // 読み込み直すたびに増やし、古い読み込み結果を捨てる
+	prompt       *statusPrompt // 確認中の問い合わせ
+	message      string        // 操作の結果
+
+	closeFunc  func()                  // ビューを閉じるときに呼ぶ関数
+	diffFunc   func(entry statusEntry) // ファイルの差分を表示する関数
+	commitFunc func()                  // コミットメッセージの入力欄を開く関数
+}
+
+// 未コミットの変更の一覧を作成
+func newStatusView(repo Repository, queue func(func())) *statusView {
+	v := &statusView{
+		list: tview.NewTextView().
+			SetDynamicColors(true).
+			SetScrollable(true).
+			SetWrap(false),
+		footer: tview.NewTextView().
+			SetDynamicColors(true),
+		repo:  repo,
+		queue: queue,
+	}
+	v.list.SetBorder(true).SetTitle(" Uncommitted Changes ")
+	v.Flex = tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(v.list, 0, 1, true).
+		AddItem(v.footer, 2, 0, false)
+	v.list.SetInputCapture(v.handleKey)
+	return v
+}
+
+// ビューを閉じるときに呼ぶ関数を設定
+func (v *statusView) SetCloseFunc(handler func()) *statusView {
+	v.closeFunc = handler
+	return v
+}
+
+// ファイルの差分を表示する関数を設定
+func (v *statusView) SetDiffFunc(handler func(entry statusEntry)) *statusView {
+	v.diffFunc = handler
+	return v
+}
+
+// コミットメッセージの入力欄を開く関数を設定
+func (v *statusView) SetCommitFunc(handler func()) *statusView {
+	v.commitFunc = handler
+	return v
+}
+
+// 選択中の行を取得（一覧が空ならfalse）
+func (v *statusView) selected() (statusEntry, bool) {
+	if v.current < 0 || v.current >= len(v.entries) {
+		return statusEntry{}, false
+	}
+	return v.entries[v.current], true
+}
+
+// ファイルの状態を別のゴルーチンで読み込み直す
+// 選択していたファイルが残っていれば選択したままにする
+func (v *statusView) Refresh() {
+	v.generation++
+	generation := v.generation
+	go func() {
+		files, err := v.repo.Status()
+		v.queue(func() {
+			if generation != v.generation {
+				return
+			}
+			if err != nil {
+				v.message = fmt.Sprintf("[red]Failed to read status: %s[-]", tview.Escape(formatMessage(err.Error())))
+				v.render()
+				return
+			}
+			old, ok := v.selected()
+			v.entries = groupStatus(files)
+			v.loaded = true
+			if ok {
+				v.current = findStatusEntry(v.entries, old, v.current)
+			}
+			v.current = max(min(v.current, len(v.entries)-1), 0)
+			v.render()
+		})
+	}()
+}
+
+// 読み込み直した一覧から前に選択していた行を探す
+// 同じ区分になければ（ステージングした場合など）別の区分の同じファイル、それもなければ同じ位置にする
+func findStatusEntry(entries []statusEntry, old statusEntry, oldIndex int) int {
+	other := -1
+	for i, entry := range entries {
+		if entry.File.Path != old.File.Path {
+			continue
+		}
+		if entry.Section == old.Section {
+			return i
+		}
+		if other < 0 {
+			other = i
+		}
+	}
+	if other >= 0 {
+		return other
+	}
+	return oldIndex
+}
+
+// 操作を別のゴルーチンで行い、終わったら一覧を読み込み直す
+func (v *statusView) run(failure string, operation func() error) {
+	go func() {
+		err := operation()
+		v.queue(func() {
+			v.message = ""
+			if err != nil {
+				v.message = fmt.Sprintf("[red]%s: %s[-]", failure, tview.Escape(formatMessage(err.Error())))
+			}
+			v.Refresh()
+			v.render()
+		})
+	}()
+}
+
+// 一覧を描画する
+func (v *statusView) render() {
+	_, _, _, height := v.list.GetInnerRect()
+
+	var b strings.Builder
+	row, selectedRow := 0, 0
+	writeLine := func(format string, args ...interface{}) {
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString("\n")
+		row++
+	}
+
+	if len(v.entries) == 0 {
+		if v.loaded {
+			writeLine("No uncommitted changes")
+		} else {
+			writeLine("Loading...")
+		}
+	}
+	for i, entry := range v.entries {
+		if i == 0 || entry.Section != v.entries[i-1].Section {
+			// 区分の見出し
+			if i > 0 {
+				writeLine("")
+			}
+			count := 0
+			for _, e := range v.entries {
+				if e.Section == entry.Section {
+					count++
+				}
+			}
+			writeLine("[yellow::b]%s (%d)[-::-]", entry.Section, count)
+		}
+
+		line := fmt.Sprintf("  %c  %s", entry.Code(), entry.File.DisplayPath())
+		switch {
+		case i == v.current:
+			selectedRow = row
+			writeLine("[black:white]%s[-:-]", tview.Escape(line))
+		case entry.File.Conflicted:
+			writeLine("[red]%s[-]", tview.Escape(line))
+		case entry.Section == sectionStaged:
+			writeLine("[green]%s[-]", tview.Escape(line))
+		default:
+			writeLine("%s", tview.Escape(line))
+		}
+	}
+	v.list.SetText(b.String())
+
+	// 選択中の行が画面に表示されるようにスクロールする（区分の先頭では見出しも表示する）
+	top := selectedRow
+	if v.current >= 0 && v.current < len(v.entries) && (v.current == 0 || v.entries[v.current-1].Section != v.entries[v.current].Section) {
+		top = max(selectedRow-1, 0)
+	}
+	if top < v.scrollOffset {
+		v.scrollOffset = top
+	} else if height > 0 && selectedRow >= v.scrollOffset+height {
+		v.scrollOffset = selectedRow - height + 1
+	}
+	v.list.ScrollTo(v.scrollOffset, 0)
+
+	// 問い合わせ中はその内容、そうでなければ操作の結果と使えるキーを表示する
+	v.footer.Clear()
+	if v.prompt != nil {
+		v.footer.Write([]byte(v.prompt.Render()))
+		return
+	}
+	if v.message != "" {
+		v.footer.Write([]byte(v.message))
+	}
+	v.footer.Write([]byte("\n[gray]s/u/Space: stage/unstage  x: discard  Enter/v: diff  c: commit  R: reload  q: close[-]"))
+}
+
+// ファイルの変更をインデックスに追加する、または取り消す
+func (v *statusView) toggleStage(entry statusEntry) {
+	file := entry.File
+	if entry.Section == sectionStaged {
+		v.run("Unstage failed", func() error { return v.repo.UnstageFile(file) })
+	} else {
+		v.run("Stage failed", func() error { return v.repo.StageFile(file) })
+	}
+}
+
+// キー入力のハンドリング
+func (v *statusView) handleKey(event *tcell.EventKey) *tcell.EventKey {
+	// 問い合わせ中はその入力として扱う
+	if v.prompt != nil {
+		switch v.prompt.HandleKey(event) {
+		case inputAccepted:
+			prompt := v.prompt
+			v.prompt = nil
+			prompt.Accept()
+		case inputCanceled:
+			v.prompt = nil
+		}
+		v.render()
+		return nil
+	}
+
+	v.message = ""
+	entry, ok := v.selected()
+
+	switch event.Key() {
+	case tcell.KeyUp:
+		if v.current > 0 {
+			v.current--
+		}
+	case tcell.KeyDown:
+		if v.current < len(v.entries)-1 {
+			v.current++
+		}
+	case tcell.KeyEnter:
+		if ok && v.diffFunc != nil {
+			v.diffFunc(entry)
+		}
+	case tcell.KeyEscape:
+		if v.closeFunc != nil {
+			v.closeFunc()
+		}
+		return nil
+	case tcell.KeyRune:
+		switch event.Rune() {
+		case 'v':
+			if ok && v.diffFunc != nil {
+				v.diffFunc(entry)
+			}
+		case ' ':
+			if ok {
+				v.toggleStage(entry)
+			}
+		case 's':
+			if ok && entry.Section != sectionStaged {
+				v.toggleStage(entry)
+			}
+		case 'u':
+			if ok && entry.Section == sectionStaged {
+				v.toggleStage(entry)
+			}
+		case 'x':
+			// 作業ツリーの変更は戻せないので確認する
+			if !ok {
+				break
+			}
+			file := entry.File
+			switch {
+			case entry.Section == sectionStaged:
+				v.message = "[yellow]Unstage the file before discarding its changes[-]"
+			case file.Conflicted:
+				v.message = "[yellow]Resolve the conflict before discarding its changes[-]"
+			case file.Untracked:
+				v.prompt = newConfirmPrompt(fmt.Sprintf("Delete untracked file '%s'?", file.Path), func() {
+					v.run("Discard failed", func() error { return v.repo.DiscardFile(file) })
+				})
+			default:
+				v.prompt = newConfirmPrompt(fmt.Sprintf("Discard unstaged changes to '%s'?", file.Path), func() {
+					v.run("Discard failed", func() error { return v.repo.DiscardFile(file) })
+				})
+			}
+		case 'c':
+			if v.commitFunc != nil {
+				v.commitFunc()
+			}
+			return nil
+		case 'R':
+			v.Refresh()
+		case 'q':
+			if v.closeFunc != nil {
+				v.closeFunc()
+			}
+			return nil
+		default:
+			return event
+		}
+	default:
+		return event
+	}
+	v.render()
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"slices"
+	"time"
+)
+
+// 監視で検出した変更の種類（複数の変更をまとめて送るためビットで表す）
+type watchEvent int
+
+const (
+	watchRefs       watchEvent = 1 << iota // HEAD、refs、packed-refs、途中で止まっている操作の変更
+	watchWorktree                          // インデックスや作業ツリーの変更
+	watchIncomplete                        // 監視数の上限などで監視できないディレクトリがある
+)
+
+// 最初の変更を検出してから、続く変更をまとめて送るまでの待ち時間
+const watchDebounce = 200 * time.Millisecond
+
+// Gitディレクトリ直下のファイル名から変更の種類を判定する
+func classifyGitDirFile(name string) watchEvent {
+	switch name {
+	case "HEAD", "packed-refs":
+		return watchRefs
+	case "index":
+		return watchWorktree
+	}
+	// マージやリベースなどの開始と終了
+	if slices.Contains(operationFiles, name) {
+		return watchRefs
+	}
+	return 0
+}
+
+// 続けて届く変更をまとめ、最初の変更からwatchDebounce後にoutへ送る
+func debounceWatchEvents(in <-chan watchEvent, out chan<- watchEvent) {
+	var pending watchEvent
+	var timer <-chan time.Time
+	for {
+		select {
+		case event, ok := <-in:
+			if !ok {
+				close(out)
+				return
+			}
+			pending |= event
+			if timer == nil {
+				timer = time.After(watchDebounce)
+			}
+		case <-timer:
+			out <- pending
+			pending = 0
+			timer = nil
+		}
+	}
+}
+
+// リポジトリの変更の監視を開始し、まとめた変更を受け取るチャネルを返す
+func watchRepository(gitDir, workDir string) (<-chan watchEvent, error) {
+	raw := make(chan watchEvent, 64)
+	if err := startWatcher(gitDir, workDir, raw); err != nil {
+		return nil, err
+	}
+	events := make(chan watchEvent)
+	go debounceWatchEvents(raw, events)
+	return events, nil
+}
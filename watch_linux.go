@@ -0,0 +1,204 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"unsafe"
+
+	"github.com/go-git/go-billy/v5/osfs"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"golang.org/x/sys/unix"
+)
+
+// 監視しているディレクトリの種類
+type watchDirKind int
+
+const (
+	watchGitDir      watchDirKind = iota // Gitディレクトリ直下（HEAD、packed-refs、index）
+	watchRefsDir                         // refs以下
+	watchLogsDir                         // logs/refs（スタッシュのreflog）
+	watchWorktreeDir                     // 作業ツリー
+)
+
+// 監視しているディレクトリ
+type watchedDir struct {
+	path string
+	kind watchDirKind
+}
+
+// inotifyで検出する変更
+const inotifyMask = unix.IN_CREATE | unix.IN_DELETE | unix.IN_MODIFY | unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+
+// inotifyでディレクトリを監視する
+type inotifyWatcher struct {
+	fd      int
+	file    *os.File // 読み込み用（Fdを呼ぶとブロッキングに戻るので、監視の追加にはfdを使う）
+	gitDir  string
+	workDir string
+	ignore  gitignore.Matcher  // .gitignoreで無視するファイル（ビルドの出力などは監視しない）
+	dirs    map[int]watchedDir // watch descriptor -> ディレクトリ
+	failed  int                // 監視を追加できなかったディレクトリの数
+	out     chan<- watchEvent
+}
+
+// inotifyで監視を開始する
+func startWatcher(gitDir, workDir string, out chan<- watchEvent) error {
+	// ノンブロッキングにしておくとos.FileのReadがランタイムのポーラーで待機できる
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	w := &inotifyWatcher{
+		fd:      fd,
+		file:    os.NewFile(uintptr(fd), "inotify"),
+		gitDir:  filepath.Clean(gitDir),
+		workDir: filepath.Clean(workDir),
+		dirs:    make(map[int]watchedDir),
+		out:     out,
+	}
+	w.loadIgnore()
+
+	if err := w.add(w.gitDir, watchGitDir); err != nil {
+		w.file.Close()
+		return err
+	}
+	w.addTree(filepath.Join(w.gitDir, "refs"), watchRefsDir)
+	// 古いスタッシュを削除してもrefs/stashは変わらないので、スタッシュのreflogも監視する
+	// reflogのディレクトリがまだなければ監視しない
+	w.add(filepath.Join(w.gitDir, "logs", "refs"), watchLogsDir)
+	w.addTree(w.workDir, watchWorktreeDir)
+	if w.failed > 0 {
+		out <- watchIncomplete
+	}
+
+	go w.run()
+	return nil
+}
+
+// .gitignore、.git/info/exclude、core.excludesfileから無視するパターンを読み込む
+func (w *inotifyWatcher) loadIgnore() {
+	patterns, _ := gitignore.ReadPatterns(osfs.New(w.workDir), nil)
+	if global, err := gitignore.LoadGlobalPatterns(osfs.New("/")); err == nil {
+		patterns = append(global, patterns...)
+	}
+	w.ignore = gitignore.NewMatcher(patterns)
+}
+
+// 作業ツリーのパスが無視されるファイルかどうか
+func (w *inotifyWatcher) ignored(path string, isDir bool) bool {
+	rel, err := filepath.Rel(w.workDir, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	return w.ignore.Match(strings.Split(filepath.ToSlash(rel), "/"), isDir)
+}
+
+// ディレクトリを監視対象に追加する
+func (w *inotifyWatcher) add(path string, kind watchDirKind) error {
+	wd, err := unix.InotifyAddWatch(w.fd, path, inotifyMask)
+	if err != nil {
+		return err
+	}
+	w.dirs[wd] = watchedDir{path: path, kind: kind}
+	return nil
+}
+
+// ディレクトリとその下のディレクトリをすべて監視対象に追加する
+// 作業ツリーでは無視されるディレクトリ（node_modulesやビルドの出力など）は監視しない
+// 監視数の上限に達した場合などは、追加できなかった数をfailedに数えて続行する
+func (w *inotifyWatcher) addTree(root string, kind watchDirKind) {
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if kind == watchWorktreeDir {
+			if filepath.Clean(path) == w.gitDir || w.ignored(path, true) {
+				return filepath.SkipDir
+			}
+		}
+		if err := w.add(path, kind); err != nil {
+			w.failed++
+		}
+		return nil
+	})
+}
+
+// 監視しているディレクトリ内のファイルの変更から、変更の種類を判定する
+func (w *inotifyWatcher) classify(dir watchedDir, name string) watchEvent {
+	switch dir.kind {
+	case watchGitDir:
+		return classifyGitDirFile(name)
+	case watchRefsDir:
+		// 書き込み中のロックファイルは無視し、リネームされたときに検出する
+		if strings.HasSuffix(name, ".lock") {
+			return 0
+		}
+		return watchRefs
+	case watchLogsDir:
+		if name == filepath.Base(stashRef) {
+			return watchRefs
+		}
+		return 0
+	}
+	if w.ignored(filepath.Join(dir.path, name), false) {
+		return 0
+	}
+	return watchWorktree
+}
+
+// inotifyのイベントを読み続ける
+func (w *inotifyWatcher) run() {
+	defer close(w.out)
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + unix.SizeofInotifyEvent
+			name := string(bytes.TrimRight(buf[nameStart:nameStart+int(raw.Len)], "\x00"))
+			offset = nameStart + int(raw.Len)
+
+			if raw.Mask&unix.IN_Q_OVERFLOW != 0 {
+				// イベントを取りこぼした場合はすべて読み込み直す
+				w.out <- watchRefs | watchWorktree
+				continue
+			}
+			dir, ok := w.dirs[int(raw.Wd)]
+			if !ok {
+				continue
+			}
+
+			// 新しく作られたディレクトリも監視する（refs/heads/feature/のような階層など）
+			if raw.Mask&unix.IN_ISDIR != 0 && raw.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 && (dir.kind == watchRefsDir || dir.kind == watchWorktreeDir) {
+				failed := w.failed
+				w.addTree(filepath.Join(dir.path, name), dir.kind)
+				if w.failed > failed {
+					w.out <- watchIncomplete
+				}
+			}
+			if dir.kind == watchWorktreeDir && name == ".gitignore" {
+				// 無視するパターンが変わった
+				w.loadIgnore()
+			}
+			if raw.Mask&unix.IN_IGNORED != 0 {
+				// 削除されたディレクトリの監視は自動的に解除される
+				delete(w.dirs, int(raw.Wd))
+				continue
+			}
+
+			if event := w.classify(dir, name); event != 0 {
+				w.out <- event
+			}
+		}
+	}
+}
@@ -0,0 +1,74 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInotifyWatcherSkipsIgnoredFiles(t *testing.T) {
+	dir := t.TempDir()
+	gitDir := filepath.Join(dir, ".git")
+	for _, path := range []string{filepath.Join(gitDir, "refs", "heads"), filepath.Join(gitDir, "logs", "refs", "heads"), filepath.Join(dir, "src"), filepath.Join(dir, "build", "obj")} {
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("build/\n*.o\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := make(chan watchEvent, 64)
+	if err := startWatcher(gitDir, dir, out); err != nil {
+		t.Fatal(err)
+	}
+
+	// 届いたイベントをまとめて受け取る
+	receive := func() watchEvent {
+		var events watchEvent
+		timeout := time.After(300 * time.Millisecond)
+		for {
+			select {
+			case event := <-out:
+				events |= event
+			case <-timeout:
+				return events
+			}
+		}
+	}
+	write := func(path string) {
+		if err := os.WriteFile(filepath.Join(dir, path), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 無視されるディレクトリの中や無視されるファイルの変更は検出しない
+	write("build/obj/main.o")
+	write("src/main.o")
+	if events := receive(); events != 0 {
+		t.Errorf("ignored files reported as %v", events)
+	}
+
+	write("src/main.c")
+	if events := receive(); events != watchWorktree {
+		t.Errorf("worktree change reported as %v", events)
+	}
+
+	write(".git/refs/heads/main")
+	if events := receive(); events != watchRefs {
+		t.Errorf("ref change reported as %v", events)
+	}
+
+	// スタッシュのreflogの変更はrefの変更として扱い、ブランチのreflogは無視する
+	write(".git/logs/refs/stash")
+	if events := receive(); events != watchRefs {
+		t.Errorf("stash reflog change reported as %v", events)
+	}
+	write(".git/logs/refs/heads/main")
+	if events := receive(); events != 0 {
+		t.Errorf("branch reflog change reported as %v", events)
+	}
+}
@@ -0,0 +1,69 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// inotifyが使えない環境では、定期的にファイルの更新日時を確認して変更を検出する
+// 作業ツリーのファイルは数が多いので確認せず、インデックスの更新だけを検出する
+func startWatcher(gitDir, workDir string, out chan<- watchEvent) error {
+	go func() {
+		refs := refsStamp(gitDir)
+		index := fileStamp(filepath.Join(gitDir, "index"))
+		ticker := time.NewTicker(500 * time.Millisecond)
+		for range ticker.C {
+			if stamp := refsStamp(gitDir); stamp != refs {
+				refs = stamp
+				out <- watchRefs
+			}
+			if stamp := fileStamp(filepath.Join(gitDir, "index")); stamp != index {
+				index = stamp
+				out <- watchWorktree
+			}
+		}
+	}()
+	return nil
+}
+
+// ファイルの更新日時とサイズを変更の検出に使う文字列にする
+func fileStamp(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%d %d", info.ModTime().UnixNano(), info.Size())
+}
+
+// refに関係するファイル（HEAD、packed-refs、refs以下）と、途中で止まっている操作のファイルの
+// 更新日時とサイズから変更を検出するための文字列を作る
+func refsStamp(gitDir string) string {
+	var b strings.Builder
+	add := func(path string, info fs.FileInfo) {
+		fmt.Fprintf(&b, "%s %d %d\n", path, info.ModTime().UnixNano(), info.Size())
+	}
+
+	// 古いスタッシュを削除してもrefs/stashは変わらないので、スタッシュのreflogも見る
+	for _, name := range append([]string{"HEAD", "packed-refs", filepath.Join("logs", stashRef)}, operationFiles...) {
+		if info, err := os.Stat(filepath.Join(gitDir, name)); err == nil {
+			add(name, info)
+		}
+	}
+	filepath.WalkDir(filepath.Join(gitDir, "refs"), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			add(path, info)
+		}
+		return nil
+	})
+
+	return b.String()
+}